@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// OfflineAlerter posts to a webhook when a watched validator has not attested
+// for offlineEpochs. Validators are watched once they appear in a validator
+// response; the watched set is capped at maxWatched, evicting the validator
+// least recently requested. Only watched validators are ever alerted on.
+type OfflineAlerter struct {
+	client        *http.Client
+	webhookURL    string
+	cache         *LastAttestCache
+	tracker       *AttestationTracker
+	offlineEpochs uint64
+	maxWatched    int
+	epochDuration time.Duration
+	slotsPerEpoch uint64
+	log           logrus.FieldLogger
+
+	mu      sync.Mutex
+	ll      *list.List // of uint64 validator indices, front = most recent
+	watched map[uint64]*list.Element
+	alerted map[uint64]bool // alerted and not yet seen attesting again
+}
+
+func NewOfflineAlerter(client *http.Client, cfg *proxyConfig, cache *LastAttestCache, tracker *AttestationTracker, log logrus.FieldLogger) *OfflineAlerter {
+	return &OfflineAlerter{
+		client:        client,
+		webhookURL:    cfg.AlertWebhookURL,
+		cache:         cache,
+		tracker:       tracker,
+		offlineEpochs: cfg.AlertOfflineEpochs,
+		epochDuration: time.Duration(cfg.SecondsPerSlot*cfg.SlotsPerEpoch) * time.Second,
+		slotsPerEpoch: cfg.SlotsPerEpoch,
+		maxWatched:    cfg.AlertMaxWatched,
+		log:           log,
+		ll:            list.New(),
+		watched:       make(map[uint64]*list.Element),
+		alerted:       make(map[uint64]bool),
+	}
+}
+
+// Watch marks index as recently requested, adding it to the watched set and
+// evicting the least recently requested validator when over the cap.
+func (a *OfflineAlerter) Watch(index uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if el, ok := a.watched[index]; ok {
+		a.ll.MoveToFront(el)
+		return
+	}
+	a.watched[index] = a.ll.PushFront(index)
+	for a.maxWatched > 0 && a.ll.Len() > a.maxWatched {
+		oldest := a.ll.Back()
+		vi := oldest.Value.(uint64)
+		a.ll.Remove(oldest)
+		delete(a.watched, vi)
+		delete(a.alerted, vi)
+	}
+}
+
+// Watched returns the number of watched validators.
+func (a *OfflineAlerter) Watched() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.ll.Len()
+}
+
+type offlineValidator struct {
+	Index               uint64 `json:"index"`
+	LastAttestationSlot uint64 `json:"lastattestationslot"`
+}
+
+type offlineAlert struct {
+	HeadSlot   uint64             `json:"head_slot"`
+	Validators []offlineValidator `json:"validators"`
+}
+
+// Start checks the watched set once per epoch in the background.
+func (a *OfflineAlerter) Start() {
+	go func() {
+		ticker := time.NewTicker(a.epochDuration)
+		defer ticker.Stop()
+		a.log.WithField("max_watched", a.maxWatched).Info("offline alerter started")
+		for range ticker.C {
+			headSlot, ok := a.tracker.HeadSlot()
+			if !ok {
+				continue
+			}
+			offline := a.collectOffline(headSlot)
+			if len(offline) == 0 {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := a.post(ctx, offlineAlert{HeadSlot: headSlot, Validators: offline}); err != nil {
+				a.log.WithError(err).Warn("failed to post offline alert")
+			}
+			cancel()
+		}
+	}()
+}
+
+// collectOffline returns watched validators newly found offline at headSlot.
+// Validators without a known attestation are skipped: the scanner may simply
+// not have covered them yet.
+func (a *OfflineAlerter) collectOffline(headSlot uint64) []offlineValidator {
+	threshold := a.offlineEpochs * a.slotsPerEpoch
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var offline []offlineValidator
+	for vi := range a.watched {
+		last := a.cache.Get(vi)
+		if last == 0 {
+			continue
+		}
+		if headSlot <= last || headSlot-last <= threshold {
+			delete(a.alerted, vi)
+			continue
+		}
+		if a.alerted[vi] {
+			continue
+		}
+		a.alerted[vi] = true
+		offline = append(offline, offlineValidator{Index: vi, LastAttestationSlot: last})
+	}
+	return offline
+}
+
+func (a *OfflineAlerter) post(ctx context.Context, alert offlineAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func newTestAlerter(t *testing.T, maxWatched int) *OfflineAlerter {
+	t.Helper()
+	cfg := newTestConfig(t)
+	cfg.AlertOfflineEpochs = 2
+	cfg.AlertMaxWatched = maxWatched
+	return NewOfflineAlerter(http.DefaultClient, cfg, NewLastAttestCache(), nil, newTestLogger())
+}
+
+// Over the cap, the validator least recently requested is evicted.
+func TestOfflineAlerterEvictsLeastRecentlyWatched(t *testing.T) {
+	a := newTestAlerter(t, 2)
+	a.Watch(1)
+	a.Watch(2)
+	a.Watch(1) // 2 is now the least recent
+	a.Watch(3)
+
+	if n := a.Watched(); n != 2 {
+		t.Fatalf("Watched = %d, want 2", n)
+	}
+	for vi, want := range map[uint64]bool{1: true, 2: false, 3: true} {
+		if _, ok := a.watched[vi]; ok != want {
+			t.Errorf("validator %d watched = %v, want %v", vi, ok, want)
+		}
+	}
+}
+
+func TestOfflineAlerterUncapped(t *testing.T) {
+	a := newTestAlerter(t, 0)
+	for vi := uint64(0); vi < 100; vi++ {
+		a.Watch(vi)
+	}
+	if n := a.Watched(); n != 100 {
+		t.Fatalf("Watched = %d, want 100", n)
+	}
+}
+
+// Only watched validators are alerted on, and each only once while offline.
+func TestCollectOffline(t *testing.T) {
+	a := newTestAlerter(t, 10)
+	a.cache.SetIfGreater(1, 10)
+	a.cache.SetIfGreater(2, 10)
+	a.cache.SetIfGreater(3, 100)
+	a.Watch(1)
+	a.Watch(3)
+	a.Watch(4) // never seen attesting
+
+	offline := a.collectOffline(100)
+	if len(offline) != 1 || offline[0] != (offlineValidator{Index: 1, LastAttestationSlot: 10}) {
+		t.Fatalf("offline = %+v, want only validator 1", offline)
+	}
+	if again := a.collectOffline(101); len(again) != 0 {
+		t.Fatalf("validator alerted again: %+v", again)
+	}
+}
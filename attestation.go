@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,16 +16,21 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 )
 
+// Mainnet slot timing, used when the consensus spec can't be fetched and
+// the timing isn't configured.
 const (
-	secondsPerSlot = 12
-	slotsPerEpoch  = 32
+	defaultSecondsPerSlot = 12
+	defaultSlotsPerEpoch  = 32
 )
 
 type LastAttestCache struct {
 	mu sync.RWMutex
 	m  map[uint64]uint64 // validatorIndex -> lastAttestSlot
+
+	hits, misses atomic.Uint64 // GetOK lookups
 }
 
 func NewLastAttestCache() *LastAttestCache {
@@ -35,6 +43,51 @@ func (c *LastAttestCache) Get(index uint64) uint64 {
 	return c.m[index]
 }
 
+// GetOK is like Get but reports whether the validator has been seen at all,
+// telling an unknown validator apart from one that attested in slot 0.
+func (c *LastAttestCache) GetOK(index uint64) (uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	slot, ok := c.m[index]
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return slot, ok
+}
+
+// Snapshot returns a copy of the cached slots of validators from..to
+// (inclusive).
+func (c *LastAttestCache) Snapshot(from, to uint64) map[uint64]uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[uint64]uint64)
+	for idx, slot := range c.m {
+		if idx >= from && idx <= to {
+			out[idx] = slot
+		}
+	}
+	return out
+}
+
+// Reset empties the cache and returns how many validators it held.
+func (c *LastAttestCache) Reset() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.m)
+	c.m = make(map[uint64]uint64)
+	return n
+}
+
+// Stats reports the cache size and lookup hit rate. Entries never expire.
+func (c *LastAttestCache) Stats() cacheStats {
+	c.mu.RLock()
+	n := len(c.m)
+	c.mu.RUnlock()
+	return cacheStats{Name: "last_attestation", Entries: n, Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
 func (c *LastAttestCache) SetIfGreater(index uint64, slot uint64) bool {
 	c.mu.Lock()
 	updated := false
@@ -47,18 +100,133 @@ func (c *LastAttestCache) SetIfGreater(index uint64, slot uint64) bool {
 }
 
 type AttestationTracker struct {
-	client       *http.Client
-	consensusAPI string
-	cache        *LastAttestCache
-	log          logrus.FieldLogger
+	client     *http.Client
+	consensus  *ConsensusNodes
+	source     string // attestationSourceBitlist or attestationSourceRewards
+	cache      *LastAttestCache
+	committees *CommitteeCache // nil when committee caching is disabled
+	blocks     *BlockCache     // nil when block caching is disabled
+	log        logrus.FieldLogger
+
+	// committeeFlights collapses concurrent committee fetches for a slot
+	committeeFlights singleflight.Group
+
+	// Network slot timing
+	slotsPerEpoch  uint64
+	secondsPerSlot uint64
+
+	// Block fetch retries: attempts per slot and the base backoff
+	blockAttempts int
+	blockBackoff  time.Duration
+	// maxSlotsPerTick caps the slots the live scanner covers per tick (0 = no cap)
+	maxSlotsPerTick uint64
+	// startMode picks the live scanner's first slot: scanStartHead or
+	// scanStartContinue
+	startMode string
 
 	mu               sync.Mutex
 	lastScannedEpoch uint64
 	lastScannedSlot  uint64
+	headSlot         uint64 // latest head seen, 0 until the first fetch
+	backfillSlot     uint64 // head slot the latest backfill covers up to, 0 if none
+	backfilling      bool   // a backfill is running
+	scanFrom         uint64 // first slot scanned, 0 until known
+
+	// votes collects the voters of each attested slot across the blocks
+	// including them, until the slot's inclusion window has been scanned.
+	votesMu sync.Mutex
+	votes   map[uint64]*slotVotes
+
+	// Scanner health: consecutive failed ticks and the last good one.
+	failedTicks  int
+	lastGoodTick time.Time
+
+	// During a concurrent warm-up, slots claimed by either the backfill or the
+	// live scanner are skipped by the other.
+	warmupMu sync.Mutex
+	warmup   bool
+	claimed  map[uint64]struct{}
+}
+
+func NewAttestationTracker(client *http.Client, consensus *ConsensusNodes, cfg *proxyConfig, cache *LastAttestCache, log logrus.FieldLogger) *AttestationTracker {
+	t := &AttestationTracker{
+		client:    client,
+		consensus: consensus,
+		source:    cfg.AttestationSource,
+		cache:     cache,
+		log:       log,
+
+		slotsPerEpoch:  cfg.SlotsPerEpoch,
+		secondsPerSlot: cfg.SecondsPerSlot,
+
+		blockAttempts:   cfg.BlockFetchAttempts,
+		blockBackoff:    cfg.BlockFetchBackoff,
+		maxSlotsPerTick: cfg.ScanMaxSlotsPerTick,
+		startMode:       cfg.ScanStartMode,
+
+		votes: make(map[uint64]*slotVotes),
+	}
+	if t.blockAttempts < 1 {
+		t.blockAttempts = 1
+	}
+	if cfg.CommitteeCacheEpochs > 0 {
+		t.committees = NewCommitteeCache(cfg.CommitteeCacheEpochs, cfg.SlotsPerEpoch, cfg.SecondsPerSlot)
+	}
+	if cfg.BlockCacheSize > 0 {
+		t.blocks = NewBlockCache(cfg.BlockCacheTTL, cfg.BlockCacheSize)
+	}
+	return t
+}
+
+// Blocks returns the tracker's block cache, nil when disabled. The slot
+// routes share it to avoid refetching blocks the scanner just fetched.
+func (t *AttestationTracker) Blocks() *BlockCache {
+	return t.blocks
 }
 
-func NewAttestationTracker(client *http.Client, consensusAPI string, cache *LastAttestCache, log logrus.FieldLogger) *AttestationTracker {
-	return &AttestationTracker{client: client, consensusAPI: consensusAPI, cache: cache, log: log}
+// Committees returns the tracker's committee cache, nil when disabled.
+func (t *AttestationTracker) Committees() *CommitteeCache {
+	return t.committees
+}
+
+// BeginWarmup enables slot claiming so Backfill and the live scanner can run
+// concurrently without scanning the same slot twice.
+func (t *AttestationTracker) BeginWarmup() {
+	t.warmupMu.Lock()
+	t.warmup = true
+	t.claimed = make(map[uint64]struct{})
+	t.warmupMu.Unlock()
+}
+
+// EndWarmup disables slot claiming once Backfill has finished.
+func (t *AttestationTracker) EndWarmup() {
+	t.warmupMu.Lock()
+	t.warmup = false
+	t.claimed = nil
+	t.warmupMu.Unlock()
+}
+
+// claimSlot reports whether the caller should scan slot. Outside of a warm-up
+// every slot may be scanned. A slot after the latest head seen is never
+// claimed: it has no block yet, and the live scanner must still pick it up
+// once one is produced.
+func (t *AttestationTracker) claimSlot(slot uint64) bool {
+	t.mu.Lock()
+	afterHead := slot > t.headSlot
+	t.mu.Unlock()
+	if afterHead {
+		return true
+	}
+	t.warmupMu.Lock()
+	defer t.warmupMu.Unlock()
+	if !t.warmup {
+		return true
+	}
+	if _, taken := t.claimed[slot]; taken {
+		return false
+	}
+	t.claimed[slot] = struct{}{}
+	return true
 }
 
 // Start begins a background goroutine that scans the most recently completed epoch
@@ -66,60 +234,217 @@ func NewAttestationTracker(client *http.Client, consensusAPI string, cache *Last
 func (t *AttestationTracker) Start() {
 	go func() {
 		// 每个slot扫描一次
-		ticker := time.NewTicker(time.Duration(secondsPerSlot) * time.Second)
+		ticker := time.NewTicker(time.Duration(t.secondsPerSlot) * time.Second)
 		defer ticker.Stop()
-		t.log.Info("attestation slot scanner started")
+		t.log.WithField("source", t.source).Info("attestation slot scanner started")
+		// While head can't be fetched, ticks are skipped with exponential
+		// backoff so an unreachable node isn't polled every slot
+		headFailures, skipTicks := 0, 0
 		for range ticker.C {
+			if t.source == attestationSourceRewards {
+				t.scanRewardsTick()
+				continue
+			}
+			if skipTicks > 0 {
+				skipTicks--
+				continue
+			}
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			headSlot, err := t.getHeadSlot(ctx)
 			cancel()
 			if err != nil {
-				t.log.WithError(err).Warn("failed to get head slot for slot scan")
+				headFailures++
+				skipTicks = headBackoffTicks(headFailures, t.secondsPerSlot)
+				t.log.WithError(err).WithFields(logrus.Fields{"failures": headFailures, "retry_in": time.Duration(uint64(skipTicks+1)*t.secondsPerSlot) * time.Second}).Warn("failed to get head slot for slot scan")
+				t.recordTick(false)
 				continue
 			}
+			headFailures = 0
+			t.scanNewSlots(headSlot)
+		}
+	}()
+}
 
-			t.mu.Lock()
-			start := t.lastScannedSlot + 1
-			if t.lastScannedSlot == 0 { // first run: only current head
-				start = headSlot
-			}
-			already := start > headSlot
-			t.mu.Unlock()
-			if already {
-				continue
-			}
+// scanNewSlots scans the slots after the last scanned one up to headSlot,
+// at most maxSlotsPerTick of them.
+func (t *AttestationTracker) scanNewSlots(headSlot uint64) {
+	t.mu.Lock()
+	start := t.lastScannedSlot + 1
+	if t.lastScannedSlot == 0 { // first run: current head, or on from backfill
+		start = t.firstScanSlot(headSlot)
+		t.setScanFrom(start)
+	}
+	already := start > headSlot
+	t.mu.Unlock()
+	if already {
+		t.recordTick(true)
+		return
+	}
 
-			count := (headSlot - start + 1)
-			t.log.WithFields(logrus.Fields{"from": start, "to": headSlot, "count": count}).Info("scanning new slots")
-
-			ctx2, cancel2 := context.WithTimeout(context.Background(), 90*time.Second)
-			var slots uint64
-			var updates uint64
-			aborted := false
-		slotsLoop:
-			for s := start; s <= headSlot; s++ {
-				select {
-				case <-ctx2.Done():
-					aborted = true
-					break slotsLoop
-				default:
-				}
-				slots++
-				updates += t.processSlot(ctx2, s)
-			}
-			cancel2()
+	// Cap the work per tick; the rest is picked up by the next ticks
+	end := headSlot
+	if t.maxSlotsPerTick > 0 && headSlot-start+1 > t.maxSlotsPerTick {
+		end = start + t.maxSlotsPerTick - 1
+	}
+	count := (end - start + 1)
+	t.log.WithFields(logrus.Fields{"from": start, "to": end, "count": count, "remaining": headSlot - end}).Info("scanning new slots")
 
-			t.mu.Lock()
-			t.lastScannedSlot = headSlot
-			t.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	emptyBefore := emptyAggregationBits.Value()
+	var slots, updates, missed, present uint64
+	aborted := false
+slotsLoop:
+	for s := start; s <= end; s++ {
+		select {
+		case <-ctx.Done():
+			aborted = true
+			break slotsLoop
+		default:
+		}
+		if !t.claimSlot(s) {
+			continue
+		}
+		slots++
+		u, outcome := t.processSlot(ctx, s)
+		updates += u
+		switch outcome {
+		case slotMissed:
+			missed++
+		case slotPresent:
+			present++
+		}
+	}
+	cancel()
 
-			if aborted {
-				t.log.WithFields(logrus.Fields{"from": start, "to": headSlot, "slots": slots, "updates": updates}).Warn("slot scan aborted (timeout)")
-			} else {
-				t.log.WithFields(logrus.Fields{"from": start, "to": headSlot, "slots": slots, "updates": updates}).Info("slot scan finished")
-			}
+	t.mu.Lock()
+	t.lastScannedSlot = end
+	t.mu.Unlock()
+	t.observeClosedSlots(end)
+
+	// includes anomalies seen by a concurrent backfill, if any
+	fields := logrus.Fields{"from": start, "to": end, "slots": slots, "updates": updates, "missed": missed, "present": present, "empty_aggregation_bits": emptyAggregationBits.Value() - emptyBefore}
+	t.recordTick(!aborted)
+	if aborted {
+		t.log.WithFields(fields).Warn("slot scan aborted (timeout)")
+	} else {
+		t.log.WithFields(fields).Info("slot scan finished")
+	}
+}
+
+// recordTick updates the scanner health and lag after a scan tick.
+func (t *AttestationTracker) recordTick(ok bool) {
+	t.mu.Lock()
+	if ok {
+		t.failedTicks = 0
+		t.lastGoodTick = time.Now()
+	} else {
+		t.failedTicks++
+	}
+	t.mu.Unlock()
+	if lag, known := t.ScanLag(); known {
+		scanLagSlots.Set(float64(lag))
+	}
+}
+
+// Live scanner start modes, see PROXY_SCAN_START_MODE.
+const (
+	scanStartHead     = "head"
+	scanStartContinue = "continue-from-backfill"
+)
+
+// maxHeadBackoff caps the wait between head fetches while they fail.
+const maxHeadBackoff = 5 * time.Minute
+
+// headBackoffTicks returns how many slot ticks to skip after the given
+// number of consecutive head fetch failures: 0, 1, 3, 7, ... so the interval
+// doubles each time, up to maxHeadBackoff.
+func headBackoffTicks(failures int, secondsPerSlot uint64) int {
+	maxTicks := 1
+	if secondsPerSlot > 0 {
+		maxTicks = max(int(maxHeadBackoff/(time.Duration(secondsPerSlot)*time.Second)), 1)
+	}
+	ticks := 1
+	for i := 1; i < failures && ticks < maxTicks; i++ {
+		ticks *= 2
+	}
+	if ticks > maxTicks {
+		ticks = maxTicks
+	}
+	return ticks - 1
+}
+
+// scanLagSlots is how far the last scanned slot trails head.
+var scanLagSlots = defaultRegistry.NewGauge(
+	"dora_proxy_scan_lag_slots",
+	"Slots between the latest head and the last slot the scanner covered.",
+)
+
+// Backfill progress, for the scan currently or last run
+var (
+	backfillSlotsScanned = defaultRegistry.NewGauge(
+		"dora_proxy_backfill_slots_scanned",
+		"Slots the backfill has scanned so far.",
+	)
+	backfillSlotsTotal = defaultRegistry.NewGauge(
+		"dora_proxy_backfill_slots_total",
+		"Slots the backfill covers.",
+	)
+)
+
+// ScanLag returns how many slots the scanner trails the latest head seen,
+// once both are known. With the rewards source the last slot of the newest
+// scanned epoch counts as covered.
+func (t *AttestationTracker) ScanLag() (uint64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	covered := t.lastScannedSlot
+	if t.source == attestationSourceRewards {
+		covered = 0
+		if t.lastScannedEpoch != 0 {
+			covered = (t.lastScannedEpoch+1)*t.slotsPerEpoch - 1
 		}
-	}()
+	}
+	if t.headSlot == 0 || covered == 0 {
+		return 0, false
+	}
+	if covered >= t.headSlot {
+		return 0, true
+	}
+	return t.headSlot - covered, true
+}
+
+// scannerHealth is the scanner state reported by /readyz.
+type scannerHealth struct {
+	Healthy     bool       `json:"healthy"`
+	InGrace     bool       `json:"in_grace"`
+	FailedTicks int        `json:"failed_ticks"`
+	LastGoodAt  *time.Time `json:"last_good_at,omitempty"`
+}
+
+// Health reports whether the scanner is keeping up: it is unhealthy after
+// maxFailedTicks consecutive failed ticks. While inGrace (startup warm-up)
+// failures are reported but never make it unhealthy.
+func (t *AttestationTracker) Health(maxFailedTicks int, inGrace bool) scannerHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := scannerHealth{InGrace: inGrace, FailedTicks: t.failedTicks}
+	if !t.lastGoodTick.IsZero() {
+		at := t.lastGoodTick.UTC()
+		h.LastGoodAt = &at
+	}
+	h.Healthy = inGrace || maxFailedTicks == 0 || t.failedTicks < maxFailedTicks
+	return h
+}
+
+// firstScanSlot returns the slot the live scanner's first run starts from.
+// In scanStartContinue mode that is the slot after the head the backfill
+// covers, so no slot between the two is skipped; otherwise, or when no
+// backfill has resolved its head yet, it is head. Callers hold t.mu.
+func (t *AttestationTracker) firstScanSlot(headSlot uint64) uint64 {
+	if t.startMode == scanStartContinue && t.backfillSlot > 0 && t.backfillSlot < headSlot {
+		return t.backfillSlot + 1
+	}
+	return headSlot
 }
 
 // Backfill scans only the most recent 3 epochs starting from head,
@@ -129,15 +454,27 @@ func (t *AttestationTracker) Backfill(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	headEpoch := headSlot / slotsPerEpoch
+	if t.source == attestationSourceRewards {
+		return t.backfillRewards(ctx, headSlot)
+	}
+	headEpoch := headSlot / t.slotsPerEpoch
 	var end uint64
 	if headEpoch >= 2 {
 		end = headEpoch - 2
 	} else {
 		end = 0
 	}
+	t.mu.Lock()
+	t.backfillSlot = headSlot
+	t.backfilling = true
+	t.setScanFrom(end * t.slotsPerEpoch)
+	t.mu.Unlock()
 	t.log.WithFields(logrus.Fields{"from": headEpoch, "to": end}).Info("backfill scanning epochs range")
-	slots, updates, err := t.scanEpochRange(ctx, headEpoch, end)
+	slots, updates, err := t.scanEpochRange(ctx, headEpoch, end, headSlot)
+	t.mu.Lock()
+	t.backfilling = false
+	t.mu.Unlock()
+	t.observeClosedSlots(headSlot)
 	if err != nil {
 		t.log.WithError(err).Warn("backfill encountered error")
 		return err
@@ -147,14 +484,7 @@ func (t *AttestationTracker) Backfill(ctx context.Context) error {
 }
 
 func (t *AttestationTracker) getHeadSlot(ctx context.Context) (uint64, error) {
-	base := strings.TrimRight(t.consensusAPI, "/")
-	url := base + "/eth/v2/beacon/blocks/head"
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return 0, err
-	}
-	req.Header.Set("Accept", "application/json")
-	resp, err := t.client.Do(req)
+	resp, err := t.consensus.Get(ctx, t.client, "/eth/v2/beacon/blocks/head")
 	if err != nil {
 		return 0, err
 	}
@@ -180,20 +510,39 @@ func (t *AttestationTracker) getHeadSlot(ctx context.Context) (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
+	t.mu.Lock()
+	advanced := n > t.headSlot
+	if advanced {
+		t.headSlot = n
+	}
+	t.mu.Unlock()
+	if advanced && t.committees != nil {
+		t.committees.Prune(n)
+	}
 	return n, nil
 }
 
-func (t *AttestationTracker) scanEpochRange(ctx context.Context, startEpoch, endEpoch uint64) (uint64, uint64, error) {
+// HeadSlot returns the most recent head slot seen by the tracker.
+func (t *AttestationTracker) HeadSlot() (uint64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.headSlot, t.headSlot != 0
+}
+
+// scanEpochRange scans the slots of epochs startEpoch down to endEpoch, up
+// to headSlot: later slots have no block yet and are left to the live
+// scanner.
+func (t *AttestationTracker) scanEpochRange(ctx context.Context, startEpoch, endEpoch, headSlot uint64) (uint64, uint64, error) {
 	// iterate newest to oldest, process with bounded concurrency via semaphore
 	const maxConcurrency = 16
 	var slotsScanned uint64
 	var updates uint64
 
 	// collect slots (newest -> oldest)
-	slotsToScan := make([]uint64, 0, (startEpoch-endEpoch+1)*slotsPerEpoch)
+	slotsToScan := make([]uint64, 0, (startEpoch-endEpoch+1)*t.slotsPerEpoch)
 	for epoch := startEpoch; ; epoch-- {
-		startSlot := epoch * slotsPerEpoch
-		endSlot := startSlot + (slotsPerEpoch - 1)
+		startSlot := epoch * t.slotsPerEpoch
+		endSlot := min(startSlot+(t.slotsPerEpoch-1), headSlot)
 		for slot := endSlot; ; slot-- {
 			slotsToScan = append(slotsToScan, slot)
 			if slot == startSlot {
@@ -205,6 +554,12 @@ func (t *AttestationTracker) scanEpochRange(ctx context.Context, startEpoch, end
 		}
 	}
 
+	// Progress: slots claimed by the live scanner count as done
+	var done atomic.Uint64
+	backfillSlotsTotal.Set(float64(len(slotsToScan)))
+	backfillSlotsScanned.Set(0)
+	advance := func() { backfillSlotsScanned.Set(float64(done.Add(1))) }
+
 	sem := make(chan struct{}, maxConcurrency)
 	var wg sync.WaitGroup
 
@@ -220,6 +575,11 @@ func (t *AttestationTracker) scanEpochRange(ctx context.Context, startEpoch, end
 		if aborted {
 			break
 		}
+		if !t.claimSlot(slot) {
+			<-sem
+			advance()
+			continue
+		}
 		wg.Add(1)
 		go func(s uint64) {
 			defer wg.Done()
@@ -227,9 +587,10 @@ func (t *AttestationTracker) scanEpochRange(ctx context.Context, startEpoch, end
 			if ctx.Err() != nil {
 				return
 			}
-			u := t.processSlot(ctx, s)
+			u, _ := t.processSlot(ctx, s)
 			atomic.AddUint64(&slotsScanned, 1)
 			atomic.AddUint64(&updates, u)
+			advance()
 		}(slot)
 	}
 
@@ -240,130 +601,353 @@ func (t *AttestationTracker) scanEpochRange(ctx context.Context, startEpoch, end
 	return atomic.LoadUint64(&slotsScanned), atomic.LoadUint64(&updates), nil
 }
 
-func (t *AttestationTracker) processSlot(ctx context.Context, slot uint64) uint64 {
-	base := strings.TrimRight(t.consensusAPI, "/")
-	url := base + "/eth/v2/beacon/blocks/" + strconv.FormatUint(slot, 10)
+// fetchBlockMessage fetches a beacon block by ID (slot, root or "head") and
+// returns its message, trying up to maxAttempts times on transient failures.
+// A nil message with a nil error means the block does not exist (404); that
+// is answered at once without retrying.
+func (t *AttestationTracker) fetchBlockMessage(ctx context.Context, blockID string, maxAttempts int) (map[string]interface{}, error) {
+	return t.fetchBlockMessageWith(ctx, t.client, blockID, maxAttempts)
+}
 
-	// Retry fetching the block a few times on transient failures
-	const maxAttempts = 3
+// fetchBlockMessageWith is fetchBlockMessage over client, for request paths
+// that must not use the scanner's client.
+func (t *AttestationTracker) fetchBlockMessageWith(ctx context.Context, client *http.Client, blockID string, maxAttempts int) (map[string]interface{}, error) {
+	if data, ok := t.blocks.Get(blockID); ok {
+		message, _ := data["message"].(map[string]interface{})
+		return message, nil
+	}
 	var resp *http.Response
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-		if err != nil {
-			t.log.WithError(err).Debug("build request for block failed")
-			return 0
-		}
-		req.Header.Set("Accept", "application/json")
-
-		r, err := t.client.Do(req)
-		if err == nil && r != nil && r.StatusCode == http.StatusOK {
+		r, err := t.consensus.Get(ctx, client, "/eth/v2/beacon/blocks/"+blockID)
+		if err == nil && r.StatusCode == http.StatusOK {
 			resp = r
 			break
 		}
+		if err == nil && r.StatusCode == http.StatusNotFound {
+			// No block: a missed slot, not worth retrying
+			io.Copy(io.Discard, r.Body)
+			r.Body.Close()
+			t.log.WithField("block", blockID).Debug("no block (missed slot)")
+			return nil, nil
+		}
 
 		if err != nil {
-			t.log.WithFields(logrus.Fields{"slot": slot, "attempt": attempt, "max": maxAttempts}).WithError(err).Debug("fetch block failed, will retry")
-		} else if r != nil {
-			t.log.WithFields(logrus.Fields{"slot": slot, "status": r.StatusCode, "attempt": attempt, "max": maxAttempts}).Debug("block request non-200, will retry")
+			t.log.WithFields(logrus.Fields{"block": blockID, "attempt": attempt, "max": maxAttempts}).WithError(err).Debug("fetch block failed, will retry")
+		} else {
+			t.log.WithFields(logrus.Fields{"block": blockID, "status": r.StatusCode, "attempt": attempt, "max": maxAttempts}).Debug("block request non-200, will retry")
 			// drain and close before retrying
 			io.Copy(io.Discard, r.Body)
 			r.Body.Close()
+			err = fmt.Errorf("block request returned status %d", r.StatusCode)
 		}
 
 		if attempt == maxAttempts {
-			return 0
+			return nil, err
 		}
 
-		backoff := time.Duration(attempt*100) * time.Millisecond
 		select {
 		case <-ctx.Done():
-			return 0
-		case <-time.After(backoff):
+			return nil, ctx.Err()
+		case <-time.After(jitterBackoff(attempt, t.blockBackoff)):
 		}
 	}
-	if resp == nil {
-		return 0
-	}
 	defer resp.Body.Close()
 	var payload map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		t.log.WithError(err).Debug("decode block JSON failed")
-		return 0
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode block JSON: %w", err)
 	}
 	data, _ := payload["data"].(map[string]interface{})
-	if data == nil {
-		return 0
-	}
 	message, _ := data["message"].(map[string]interface{})
 	if message == nil {
+		return nil, errors.New("block response has no message")
+	}
+	t.blocks.Set(blockID, data)
+	return message, nil
+}
+
+// slotOutcome is what scanning a slot found.
+type slotOutcome int
+
+const (
+	slotFailed  slotOutcome = iota // block could not be fetched
+	slotMissed                     // no block was proposed
+	slotPresent                    // block fetched
+)
+
+var (
+	scanMissedSlots = defaultRegistry.NewCounter(
+		"dora_proxy_scan_missed_slots_total",
+		"Scanned slots without a block.",
+	)
+	scanPresentSlots = defaultRegistry.NewCounter(
+		"dora_proxy_scan_present_slots_total",
+		"Scanned slots with a block.",
+	)
+)
+
+// jitterBackoff returns the delay before retry attempt+1: a random duration
+// up to attempt*base ("full jitter"), so workers retrying a flapping node
+// don't retry in lockstep.
+func jitterBackoff(attempt int, base time.Duration) time.Duration {
+	max := time.Duration(attempt) * base
+	if max <= 0 {
 		return 0
 	}
+	return rand.N(max + 1)
+}
+
+// processSlot scans the block at slot and records its attestations,
+// returning the number of cache updates and what was found.
+func (t *AttestationTracker) processSlot(ctx context.Context, slot uint64) (uint64, slotOutcome) {
+	// Retry fetching the block a few times on transient failures
+	message, err := t.fetchBlockMessage(ctx, strconv.FormatUint(slot, 10), t.blockAttempts)
+	if err != nil {
+		t.log.WithField("slot", slot).WithError(err).Debug("fetch block failed")
+		return 0, slotFailed
+	}
+	if message == nil {
+		scanMissedSlots.Inc()
+		return 0, slotMissed
+	}
+	scanPresentSlots.Inc()
 	body, _ := message["body"].(map[string]interface{})
 	if body == nil {
-		return 0
+		return 0, slotPresent
 	}
 	attestations, _ := body["attestations"].([]interface{})
 	if len(attestations) == 0 {
-		return 0
+		return 0, slotPresent
 	}
-	// fetch committees for this slot once
-	idxToValidators := t.fetchCommitteesForSlot(ctx, slot)
+	// Attestations vote for an earlier slot (data.slot) than the block that
+	// includes them, so committees are resolved per attested slot.
+	committeesBySlot := make(map[uint64]map[uint64][]uint64)
+	votersBySlot := make(map[uint64]map[uint64]struct{})
 	var updated uint64
 	for _, a := range attestations {
 		att, _ := a.(map[string]interface{})
 		if att == nil {
 			continue
 		}
+		attSlot, ok := attestationSlot(att)
+		if !ok {
+			attSlot = slot
+		}
+		idxToValidators, fetched := committeesBySlot[attSlot]
+		if !fetched {
+			idxToValidators = t.fetchCommitteesForSlot(ctx, t.client, attSlot)
+			committeesBySlot[attSlot] = idxToValidators
+		}
+		aggBits, _ := att["aggregation_bits"].(string)
+		if bitlistEmpty(hexBitlist(aggBits)) {
+			emptyAggregationBits.Inc()
+			t.log.WithFields(logrus.Fields{"slot": slot, "attested_slot": attSlot}).Debug("attestation with empty aggregation_bits")
+		}
 		voters := t.validatorsForAttestation(att, idxToValidators)
+		if votersBySlot[attSlot] == nil {
+			votersBySlot[attSlot] = make(map[uint64]struct{})
+		}
 		for _, vi := range voters {
+			votersBySlot[attSlot][vi] = struct{}{}
 			if t.cache.SetIfGreater(vi, slot) {
 				updated++
 			}
 		}
 	}
-	return updated
+	t.addVotes(committeesBySlot, votersBySlot)
+	return updated, slotPresent
 }
 
-func (t *AttestationTracker) fetchCommitteesForSlot(ctx context.Context, slot uint64) map[uint64][]uint64 {
-	base := strings.TrimRight(t.consensusAPI, "/")
-	stateID := strconv.FormatUint(slot, 10)
-	url := base + "/eth/v1/beacon/states/" + stateID + "/committees?slot=" + strconv.FormatUint(slot, 10)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		t.log.WithError(err).Debug("build request for committees failed")
-		return nil
+// attestationSlot returns the slot an attestation votes for (data.slot).
+func attestationSlot(att map[string]interface{}) (uint64, bool) {
+	data, _ := att["data"].(map[string]interface{})
+	if data == nil {
+		return 0, false
 	}
-	req.Header.Set("Accept", "application/json")
-	resp, err := t.client.Do(req)
-	if err != nil {
-		t.log.WithError(err).Debug("fetch committees failed")
-		return nil
+	return parseUint64FromInterface(data["slot"])
+}
+
+// slotParticipation is the share of an attested slot's committee members
+// that voted for it, over all blocks including its attestations.
+var slotParticipation = defaultRegistry.NewHistogram(
+	"dora_proxy_slot_attestation_participation",
+	"Distinct attesters over expected committee members per attested slot, once its inclusion window is scanned.",
+	[]float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 0.95, 0.99, 1},
+)
+
+// slotVotes is what the scanned blocks so far say about one attested slot.
+type slotVotes struct {
+	expected int // committee members of the slot
+	voters   map[uint64]struct{}
+}
+
+// addVotes merges the voters a block holds for each attested slot. Slots
+// before the first scanned slot are left out: their earlier votes were in
+// blocks that weren't scanned.
+func (t *AttestationTracker) addVotes(committeesBySlot map[uint64]map[uint64][]uint64, votersBySlot map[uint64]map[uint64]struct{}) {
+	t.mu.Lock()
+	from := t.scanFrom
+	t.mu.Unlock()
+	t.votesMu.Lock()
+	defer t.votesMu.Unlock()
+	for attSlot, voters := range votersBySlot {
+		if attSlot < from {
+			continue
+		}
+		v := t.votes[attSlot]
+		if v == nil {
+			expected := 0
+			for _, members := range committeesBySlot[attSlot] {
+				expected += len(members)
+			}
+			if expected == 0 {
+				continue
+			}
+			v = &slotVotes{expected: expected, voters: make(map[uint64]struct{})}
+			t.votes[attSlot] = v
+		}
+		for vi := range voters {
+			v.voters[vi] = struct{}{}
+		}
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		t.log.WithFields(logrus.Fields{"slot": slot, "status": resp.StatusCode}).Debug("committees request non-200")
-		return nil
+}
+
+// inclusionWindowEnd returns the last slot whose block may include
+// attestations for slot: the end of the following epoch.
+func (t *AttestationTracker) inclusionWindowEnd(slot uint64) uint64 {
+	return (slot/t.slotsPerEpoch+2)*t.slotsPerEpoch - 1
+}
+
+// observeClosedSlots observes the participation of every attested slot whose
+// inclusion window ends by through, and forgets it. Nothing is observed while
+// a backfill runs, as it scans out of order.
+func (t *AttestationTracker) observeClosedSlots(through uint64) {
+	t.mu.Lock()
+	backfilling := t.backfilling
+	t.mu.Unlock()
+	if backfilling {
+		return
+	}
+	t.votesMu.Lock()
+	defer t.votesMu.Unlock()
+	for attSlot, v := range t.votes {
+		if t.inclusionWindowEnd(attSlot) > through {
+			continue
+		}
+		slotParticipation.Observe(float64(len(v.voters)) / float64(v.expected))
+		delete(t.votes, attSlot)
 	}
+}
+
+// setScanFrom lowers scanFrom to slot. Callers hold t.mu.
+func (t *AttestationTracker) setScanFrom(slot uint64) {
+	if t.scanFrom == 0 || slot < t.scanFrom {
+		t.scanFrom = slot
+	}
+}
+
+// SlotCommittees returns the committees (committee index -> validator
+// indices) of slot, fetched over client, nil when the consensus node has
+// none for it.
+func (t *AttestationTracker) SlotCommittees(ctx context.Context, client *http.Client, slot uint64) map[uint64][]uint64 {
+	return t.fetchCommitteesForSlot(ctx, client, slot)
+}
+
+// BlockSlot returns the slot of the block blockID (root or "head"), fetched
+// over client, reporting false when there is no such block.
+func (t *AttestationTracker) BlockSlot(ctx context.Context, client *http.Client, blockID string) (uint64, bool, error) {
+	message, err := t.fetchBlockMessageWith(ctx, client, blockID, 1)
+	if err != nil || message == nil {
+		return 0, false, err
+	}
+	slot, ok := parseUint64FromInterface(message["slot"])
+	return slot, ok, nil
+}
+
+// fetchCommitteesForSlot returns the committees of slot from the cache or
+// the consensus node over client.
+func (t *AttestationTracker) fetchCommitteesForSlot(ctx context.Context, client *http.Client, slot uint64) map[uint64][]uint64 {
+	if t.committees != nil {
+		if committees, ok := t.committees.Get(slot); ok {
+			return committees
+		}
+	}
+	// Fetched on behalf of every waiting caller, so one canceling doesn't
+	// fail the others
+	v, _, _ := t.committeeFlights.Do(strconv.FormatUint(slot, 10), func() (interface{}, error) {
+		committees := t.fetchCommittees(context.WithoutCancel(ctx), client, slot)
+		if committees != nil && t.committees != nil {
+			t.committees.Set(slot, committees)
+		}
+		return committees, nil
+	})
+	committees, _ := v.(map[uint64][]uint64)
+	return committees
+}
+
+// fetchCommittees fetches the committees of slot from the slot's own state.
+// Nodes that don't keep that state (404) are asked for the epoch's boundary
+// state and then for head, which serve the same committees while the slot's
+// epoch is within their lookahead.
+func (t *AttestationTracker) fetchCommittees(ctx context.Context, client *http.Client, slot uint64) map[uint64][]uint64 {
+	stateIDs := []string{strconv.FormatUint(slot, 10)}
+	if boundary := slot - slot%t.slotsPerEpoch; boundary != slot {
+		stateIDs = append(stateIDs, strconv.FormatUint(boundary, 10))
+	}
+	stateIDs = append(stateIDs, "head")
+
+	for _, stateID := range stateIDs {
+		path := "/eth/v1/beacon/states/" + stateID + "/committees?slot=" + strconv.FormatUint(slot, 10)
+		resp, err := t.consensus.Get(ctx, client, path)
+		if err != nil {
+			t.log.WithError(err).Debug("fetch committees failed")
+			return nil
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			t.log.WithFields(logrus.Fields{"slot": slot, "state": stateID}).Debug("committees state not found, trying next")
+			continue
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.log.WithFields(logrus.Fields{"slot": slot, "state": stateID, "status": resp.StatusCode}).Debug("committees request non-200")
+			return nil
+		}
+		return t.decodeCommittees(resp.Body)
+	}
+	return nil
+}
+
+// decodeCommittees decodes a /committees response into committee index ->
+// validator indices. Some clients send the indices as JSON numbers rather
+// than strings, so both are accepted; a committee without a usable index is
+// skipped.
+func (t *AttestationTracker) decodeCommittees(body io.Reader) map[uint64][]uint64 {
 	var payload struct {
 		Data []struct {
-			Index      string   `json:"index"`
-			Validators []string `json:"validators"`
+			Index      interface{}   `json:"index"`
+			Validators []interface{} `json:"validators"`
 		} `json:"data"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
 		t.log.WithError(err).Debug("decode committees JSON failed")
 		return nil
 	}
 	res := make(map[uint64][]uint64, len(payload.Data))
 	for _, c := range payload.Data {
-		idx, err := strconv.ParseUint(c.Index, 10, 64)
-		if err != nil {
+		idx, ok := parseUint64FromInterface(c.Index)
+		if !ok {
+			t.log.WithField("index", c.Index).Debug("skipping committee without a valid index")
 			continue
 		}
 		vals := make([]uint64, 0, len(c.Validators))
-		for _, s := range c.Validators {
-			vi, err := strconv.ParseUint(s, 10, 64)
-			if err != nil {
+		for _, v := range c.Validators {
+			vi, ok := parseUint64FromInterface(v)
+			if !ok {
 				continue
 			}
 			vals = append(vals, vi)
@@ -375,29 +959,183 @@ func (t *AttestationTracker) fetchCommitteesForSlot(ctx context.Context, slot ui
 
 func (t *AttestationTracker) validatorsForAttestation(att map[string]interface{}, idxToValidators map[uint64][]uint64) []uint64 {
 	var voters []uint64
+	for _, c := range committeeVoters(att, idxToValidators) {
+		voters = append(voters, c.Validators...)
+	}
+	return voters
+}
+
+// attestationCommittee is the set of validators of one committee that took
+// part in an attestation.
+type attestationCommittee struct {
+	CommitteeIndex uint64   `json:"committee_index"`
+	Validators     []uint64 `json:"validators"`
+}
+
+// committeeVoters decodes the participants of att per committee.
+// Electra attestations name their committees in committee_bits with
+// aggregation_bits spanning all of them in order; earlier ones cover the
+// single committee given by data.index.
+func committeeVoters(att map[string]interface{}, idxToValidators map[uint64][]uint64) []attestationCommittee {
 	aggBitsStr, _ := att["aggregation_bits"].(string)
 	aggBits := hexBitlist(aggBitsStr)
-	// Electra multi-committee path
+
+	var included []uint64
 	if cbitsStr, ok := att["committee_bits"].(string); ok && cbitsStr != "" {
-		cbits := hexBitlist(cbitsStr)
-		included := make([]uint64, 0, len(cbits))
-		for i, b := range cbits {
+		for i, b := range hexBitlist(cbitsStr) {
 			if b {
 				included = append(included, uint64(i))
 			}
 		}
-		var concat []uint64
+	} else {
+		data, _ := att["data"].(map[string]interface{})
+		if data == nil {
+			return nil
+		}
+		ci, ok := parseUint64FromInterface(data["index"])
+		if !ok {
+			return nil
+		}
+		included = []uint64{ci}
+	}
+	if len(included) == 0 {
+		return nil
+	}
+	// aggregation_bits run over the included committees in order, so one
+	// the slot doesn't have leaves every later bit unattributable
+	if len(idxToValidators) > 0 {
 		for _, ci := range included {
-			concat = append(concat, idxToValidators[ci]...)
+			if _, ok := idxToValidators[ci]; !ok {
+				unknownCommitteeBits.Inc()
+				return nil
+			}
 		}
-		for i, b := range aggBits {
-			if b && i < len(concat) {
-				voters = append(voters, concat[i])
+	}
+
+	out := make([]attestationCommittee, 0, len(included))
+	offset := 0
+	for _, ci := range included {
+		members := idxToValidators[ci]
+		c := attestationCommittee{CommitteeIndex: ci, Validators: []uint64{}}
+		for j, vi := range members {
+			if offset+j < len(aggBits) && aggBits[offset+j] {
+				c.Validators = append(c.Validators, vi)
 			}
 		}
-		return voters
+		offset += len(members)
+		out = append(out, c)
 	}
-	return voters
+	return out
+}
+
+// blockAttestations is the /api/v1/slot/{id}/attestations response.
+type blockAttestations struct {
+	Slot         uint64                   `json:"slot"`
+	Attestations []blockAttestationDetail `json:"attestations"`
+}
+
+type blockAttestationDetail struct {
+	attestationCommittee
+	// AttestedSlot is the slot voted for (data.slot), usually before Slot.
+	AttestedSlot uint64 `json:"attested_slot"`
+}
+
+// BlockAttestations decodes the participants of every attestation in the
+// block identified by blockID, one entry per attestation and committee,
+// fetching over client. It returns nil without error when the block does not
+// exist.
+func (t *AttestationTracker) BlockAttestations(ctx context.Context, client *http.Client, blockID string) (*blockAttestations, error) {
+	message, err := t.fetchBlockMessageWith(ctx, client, blockID, 1)
+	if err != nil || message == nil {
+		return nil, err
+	}
+	slot, _ := parseUint64FromInterface(message["slot"])
+	res := &blockAttestations{Slot: slot, Attestations: []blockAttestationDetail{}}
+	body, _ := message["body"].(map[string]interface{})
+	attestations, _ := body["attestations"].([]interface{})
+	committeesBySlot := make(map[uint64]map[uint64][]uint64)
+	for _, a := range attestations {
+		att, _ := a.(map[string]interface{})
+		if att == nil {
+			continue
+		}
+		attSlot, ok := attestationSlot(att)
+		if !ok {
+			attSlot = slot
+		}
+		committees, fetched := committeesBySlot[attSlot]
+		if !fetched {
+			committees = t.fetchCommitteesForSlot(ctx, client, attSlot)
+			committeesBySlot[attSlot] = committees
+		}
+		for _, c := range committeeVoters(att, committees) {
+			res.Attestations = append(res.Attestations, blockAttestationDetail{attestationCommittee: c, AttestedSlot: attSlot})
+		}
+	}
+	return res, nil
+}
+
+// EpochSlots probes every slot of epoch on the consensus node over client,
+// once each, and reports whether a block was proposed in it. Slots after the
+// known head are not probed. Callers make sure the epoch's slots fit in a
+// uint64.
+func (t *AttestationTracker) EpochSlots(ctx context.Context, client *http.Client, epoch uint64) *epochSlots {
+	const maxConcurrency = 8
+	head, headKnown := t.HeadSlot()
+	res := &epochSlots{Epoch: epoch, Slots: make([]epochSlot, t.slotsPerEpoch)}
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i := range res.Slots {
+		slot := epoch*t.slotsPerEpoch + uint64(i)
+		res.Slots[i] = epochSlot{Slot: slot, Status: "scheduled"}
+		if headKnown && slot > head {
+			continue
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(es *epochSlot) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			message, err := t.fetchBlockMessageWith(ctx, client, strconv.FormatUint(es.Slot, 10), 1)
+			switch {
+			case err != nil:
+				es.Status = "unknown"
+			case message == nil:
+				es.Status = "missed"
+			default:
+				es.Status = "present"
+			}
+		}(&res.Slots[i])
+	}
+	wg.Wait()
+	return res
+}
+
+// emptyAggregationBits counts included attestations without a single
+// participant. Valid blocks never contain them, so a rising count points at
+// a decoding problem.
+var emptyAggregationBits = defaultRegistry.NewCounter(
+	"dora_proxy_empty_aggregation_bits_total",
+	"Scanned attestations whose aggregation_bits have no participant set.",
+)
+
+// unknownCommitteeBits counts attestations naming a committee (committee_bits
+// or data.index) the attested slot does not have; they are skipped.
+var unknownCommitteeBits = defaultRegistry.NewCounter(
+	"dora_proxy_unknown_committee_attestations_total",
+	"Attestations naming a committee index the attested slot does not have.",
+)
+
+// bitlistEmpty reports whether an SSZ bitlist has no bit set besides its
+// trailing length delimiter.
+func bitlistEmpty(bits []bool) bool {
+	set := 0
+	for _, b := range bits {
+		if b {
+			set++
+		}
+	}
+	return set <= 1
 }
 
 func hexBitlist(hexstr string) []bool {
@@ -419,22 +1157,61 @@ func hexBitlist(hexstr string) []bool {
 }
 
 // attachLastAttestSlot recursively injects lastattestslot into any object that appears
-// to represent a validator (has index or validator_index field).
-func attachLastAttestSlot(v interface{}, cache *LastAttestCache) {
+// to represent a validator (has index or validator_index field). A value already
+// provided by upstream is only replaced by a known, greater cached slot. When
+// resolve is non-nil, objects carrying only a pubkey are resolved to an index
+// through it.
+func attachLastAttestSlot(v interface{}, cache *LastAttestCache, resolve func(pubkey string) (uint64, bool)) {
 	switch m := v.(type) {
 	case map[string]interface{}:
-		if val, has := m["validatorindex"]; has {
-			if idx, ok := parseUint64FromInterface(val); ok {
-				m["lastattestationslot"] = cache.Get(idx)
+		if idx, ok := validatorIndexOf(m, resolve); ok {
+			cached, known := cache.GetOK(idx)
+			if cur, has := m["lastattestationslot"]; has && cur != nil {
+				upstreamSlot, _ := parseUint64FromInterface(cur)
+				if known && cached > upstreamSlot {
+					m["lastattestationslot"] = cached
+				}
+			} else {
+				// keep the field present for Beacon compatibility
+				m["lastattestationslot"] = cached
 			}
 		}
 		// Recurse on nested objects/arrays
 		for _, val := range m {
-			attachLastAttestSlot(val, cache)
+			attachLastAttestSlot(val, cache, resolve)
 		}
 	case []interface{}:
 		for _, it := range m {
-			attachLastAttestSlot(it, cache)
+			attachLastAttestSlot(it, cache, resolve)
 		}
 	}
 }
+
+// markRecentlyActivated sets recently_activated on a validator object whose
+// activationepoch lies within window epochs before headEpoch, so a missing
+// lastattestationslot is not mistaken for an offline validator.
+func markRecentlyActivated(m map[string]interface{}, headEpoch, window uint64) {
+	activation, ok := parseUint64FromInterface(m["activationepoch"])
+	if !ok || activation > headEpoch {
+		return
+	}
+	if headEpoch-activation < window {
+		m["recently_activated"] = true
+	}
+}
+
+// validatorIndexOf returns the validator index of m, from its validatorindex
+// field or, failing that, by resolving its pubkey.
+func validatorIndexOf(m map[string]interface{}, resolve func(pubkey string) (uint64, bool)) (uint64, bool) {
+	if val, has := m["validatorindex"]; has {
+		return parseUint64FromInterface(val)
+	}
+	if resolve == nil {
+		return 0, false
+	}
+	pk, _ := m["pubkey"].(string)
+	if pk == "" {
+		return 0, false
+	}
+	return resolve(pk)
+}
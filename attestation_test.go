@@ -0,0 +1,899 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockJSON is a /eth/v2/beacon/blocks response for slot with the given
+// block body members.
+func blockJSON(slot uint64, body string) string {
+	if body == "" {
+		body = `"attestations":[]`
+	}
+	return fmt.Sprintf(`{"data":{"message":{"slot":"%d","proposer_index":"7","body":{%s}},"signature":"0xsig"}}`, slot, body)
+}
+
+// blockCounter serves a block for every slot up to head and counts the
+// fetches per block ID.
+type blockCounter struct {
+	head uint64
+
+	mu      sync.Mutex
+	fetches map[string]int
+}
+
+func (b *blockCounter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	id, ok := strings.CutPrefix(req.URL.Path, "/eth/v2/beacon/blocks/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	b.mu.Lock()
+	b.fetches[id]++
+	head := b.head
+	b.mu.Unlock()
+	slot := head
+	if id != "head" {
+		fmt.Sscan(id, &slot)
+	}
+	if slot > head {
+		http.NotFound(w, req)
+		return
+	}
+	jsonHandler(http.StatusOK, blockJSON(slot, ""))(w, req)
+}
+
+func (b *blockCounter) count(id string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.fetches[id]
+}
+
+// During a concurrent warm-up the backfill and the live scanner share the
+// work: every slot is scanned, none twice.
+func TestConcurrentWarmupScansEachSlotOnce(t *testing.T) {
+	const head = 100 // epoch 3, slot 4 of it
+	blocks := &blockCounter{head: head, fetches: make(map[string]int)}
+	consensus := newTestServer(t, blocks.ServeHTTP)
+	cfg := newTestConfig(t)
+	cfg.BlockCacheSize = 0
+	tr := newTestTracker(t, cfg, consensus.URL)
+
+	tr.BeginWarmup()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := tr.Backfill(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}()
+	go func() {
+		// the live scanner's tick over a gap reaching into backfill's range
+		defer wg.Done()
+		if _, err := tr.getHeadSlot(context.Background()); err != nil {
+			t.Error(err)
+		}
+		for s := uint64(60); s <= head+2; s++ {
+			if tr.claimSlot(s) {
+				tr.processSlot(context.Background(), s)
+			}
+		}
+	}()
+	wg.Wait()
+	tr.EndWarmup()
+
+	// backfill covers epochs 1 to 3, up to head
+	for s := uint64(32); s <= head; s++ {
+		if n := blocks.count(fmt.Sprint(s)); n != 1 {
+			t.Errorf("slot %d fetched %d times, want 1", s, n)
+		}
+	}
+	// slots after head were never claimed, so they stay with the scanner
+	for s := uint64(head + 1); s <= head+2; s++ {
+		if !tr.claimSlot(s) {
+			t.Errorf("slot %d after head is claimed", s)
+		}
+	}
+	if got := backfillSlotsTotal.Value(); got != head-32+1 {
+		t.Errorf("backfill slots total = %v, want %d", got, head-32+1)
+	}
+}
+
+func TestClaimSlotOutsideWarmup(t *testing.T) {
+	tr := newTestTracker(t, newTestConfig(t), "http://127.0.0.1:1")
+	setHeadSlot(tr, 50)
+	for i := 0; i < 2; i++ {
+		if !tr.claimSlot(40) {
+			t.Fatal("slot refused outside a warm-up")
+		}
+	}
+	tr.BeginWarmup()
+	if !tr.claimSlot(40) || tr.claimSlot(40) {
+		t.Fatal("during a warm-up a slot must be claimed exactly once")
+	}
+	tr.EndWarmup()
+	if !tr.claimSlot(40) {
+		t.Fatal("slot refused after the warm-up ended")
+	}
+}
+
+// histogramSum returns the sum of the observations in h.
+func histogramSum(h *Histogram) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sum
+}
+
+// Distinct attesters over the committee size of the attested slot are
+// collected across the blocks including its votes and observed once, when
+// the slot's inclusion window has been scanned.
+func TestParticipationObservedPerAttestedSlot(t *testing.T) {
+	// block 10 covers validators 10 and 11 of slot 9's four-member
+	// committee 0, block 11 repeats 11 and adds 12
+	blockAtts := map[string]string{
+		"10": `"attestations":[` +
+			`{"aggregation_bits":"0x03","committee_bits":"0x01","data":{"slot":"9"}},` +
+			`{"aggregation_bits":"0x02","committee_bits":"0x01","data":{"slot":"9"}}]`,
+		"11": `"attestations":[{"aggregation_bits":"0x06","committee_bits":"0x01","data":{"slot":"9"}}]`,
+	}
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		id, isBlock := strings.CutPrefix(req.URL.Path, "/eth/v2/beacon/blocks/")
+		switch {
+		case isBlock && blockAtts[id] != "":
+			slot, _ := strconv.ParseUint(id, 10, 64)
+			jsonHandler(http.StatusOK, blockJSON(slot, blockAtts[id]))(w, req)
+		case strings.HasSuffix(req.URL.Path, "/committees") && req.URL.Query().Get("slot") == "9":
+			jsonHandler(http.StatusOK, `{"data":[{"index":"0","slot":"9","validators":["10","11","12","13"]}]}`)(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	tr := newTestTracker(t, newTestConfig(t), consensus.URL)
+
+	count, sum := slotParticipation.Count(), histogramSum(slotParticipation)
+	updated, outcome := tr.processSlot(context.Background(), 10)
+	if outcome != slotPresent || updated != 2 {
+		t.Fatalf("processSlot = %d, %v; want 2 validators updated", updated, outcome)
+	}
+	tr.processSlot(context.Background(), 11)
+	tr.observeClosedSlots(62) // slot 9's window runs to the end of epoch 1
+	if got := slotParticipation.Count() - count; got != 0 {
+		t.Fatalf("%d participation observations inside the inclusion window, want 0", got)
+	}
+	tr.observeClosedSlots(63)
+	if got := slotParticipation.Count() - count; got != 1 {
+		t.Fatalf("%d participation observations, want 1", got)
+	}
+	if got := histogramSum(slotParticipation) - sum; got != 0.75 {
+		t.Fatalf("participation = %v, want 0.75", got)
+	}
+	tr.observeClosedSlots(100)
+	if got := slotParticipation.Count() - count; got != 1 {
+		t.Fatalf("%d participation observations, want slot 9 observed once", got)
+	}
+}
+
+// Votes for slots before the first scanned one are incomplete and not
+// collected, and nothing is observed while a backfill runs.
+func TestParticipationScanEdges(t *testing.T) {
+	tr := newTestTracker(t, newTestConfig(t), "http://127.0.0.1:1")
+	committees := map[uint64]map[uint64][]uint64{
+		9:  {0: {1, 2}},
+		20: {0: {3, 4}},
+	}
+	voters := map[uint64]map[uint64]struct{}{
+		9:  {1: {}},
+		20: {3: {}, 4: {}},
+	}
+	tr.mu.Lock()
+	tr.setScanFrom(10)
+	tr.backfilling = true
+	tr.mu.Unlock()
+	tr.addVotes(committees, voters)
+
+	count := slotParticipation.Count()
+	tr.observeClosedSlots(200)
+	if got := slotParticipation.Count() - count; got != 0 {
+		t.Fatalf("%d observations during a backfill, want 0", got)
+	}
+	tr.mu.Lock()
+	tr.backfilling = false
+	tr.mu.Unlock()
+	tr.observeClosedSlots(200)
+	if got := slotParticipation.Count() - count; got != 1 {
+		t.Fatalf("%d observations, want slot 20 only", got)
+	}
+}
+
+// Committees are looked up for the slot an attestation votes for, not the
+// slot of the block including it.
+func TestProcessSlotUsesAttestedSlotCommittees(t *testing.T) {
+	att := `"attestations":[{"aggregation_bits":"0x03","committee_bits":"0x01","data":{"slot":"9"}}]`
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/eth/v2/beacon/blocks/10":
+			jsonHandler(http.StatusOK, blockJSON(10, att))(w, req)
+		case strings.HasSuffix(req.URL.Path, "/committees") && req.URL.Query().Get("slot") == "9":
+			jsonHandler(http.StatusOK, `{"data":[{"index":"0","slot":"9","validators":["5","6"]}]}`)(w, req)
+		case strings.HasSuffix(req.URL.Path, "/committees"):
+			jsonHandler(http.StatusOK, `{"data":[{"index":"0","validators":["7","8"]}]}`)(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	tr := newTestTracker(t, newTestConfig(t), consensus.URL)
+
+	if updated, _ := tr.processSlot(context.Background(), 10); updated != 2 {
+		t.Fatalf("processSlot updated %d validators, want 2", updated)
+	}
+	for _, vi := range []uint64{5, 6} {
+		if got := tr.cache.Get(vi); got != 10 {
+			t.Errorf("validator %d last attested at %d, want 10", vi, got)
+		}
+	}
+	if got := tr.cache.Get(7); got != 0 {
+		t.Errorf("validator 7 of the including slot's committee marked at %d", got)
+	}
+}
+
+func TestLastAttestCacheGetOK(t *testing.T) {
+	c := NewLastAttestCache()
+	c.SetIfGreater(1, 0)
+	if slot, ok := c.GetOK(1); !ok || slot != 0 {
+		t.Errorf("GetOK(1) = %d, %v; want slot 0 known", slot, ok)
+	}
+	if _, ok := c.GetOK(2); ok {
+		t.Error("GetOK(2) reports an unknown validator as known")
+	}
+}
+
+func TestAttachLastAttestSlot(t *testing.T) {
+	cache := NewLastAttestCache()
+	cache.SetIfGreater(1, 500)
+	cache.SetIfGreater(2, 100)
+	tests := []struct {
+		name string
+		in   map[string]interface{}
+		want interface{}
+	}{
+		{"unknown index keeps upstream value", map[string]interface{}{"validatorindex": json.Number("9"), "lastattestationslot": json.Number("300")}, json.Number("300")},
+		{"unknown index without upstream value", map[string]interface{}{"validatorindex": json.Number("9")}, uint64(0)},
+		{"greater cached slot", map[string]interface{}{"validatorindex": json.Number("1"), "lastattestationslot": json.Number("300")}, uint64(500)},
+		{"smaller cached slot", map[string]interface{}{"validatorindex": json.Number("2"), "lastattestationslot": json.Number("300")}, json.Number("300")},
+		{"null upstream value", map[string]interface{}{"validatorindex": json.Number("2"), "lastattestationslot": nil}, uint64(100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachLastAttestSlot(tt.in, cache, nil)
+			if got := tt.in["lastattestationslot"]; got != tt.want {
+				t.Fatalf("lastattestationslot = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// twoAttestationConsensus serves block 10 (also as head, root 0xhead) with
+// two attestations for slot 9 and that slot's committees.
+func twoAttestationConsensus(t *testing.T) string {
+	t.Helper()
+	atts := `"attestations":[` +
+		`{"aggregation_bits":"0x0b","data":{"slot":"9","index":"0"}},` +
+		`{"aggregation_bits":"0x0c","data":{"slot":"9","index":"1"}}]`
+	return newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/eth/v1/beacon/headers/head":
+			jsonHandler(http.StatusOK, `{"data":{"root":"0xhead","header":{"message":{"slot":"10"}}}}`)(w, req)
+		case req.URL.Path == "/eth/v2/beacon/blocks/10" || req.URL.Path == "/eth/v2/beacon/blocks/0xhead":
+			jsonHandler(http.StatusOK, blockJSON(10, atts))(w, req)
+		case strings.HasSuffix(req.URL.Path, "/committees") && req.URL.Query().Get("slot") == "9":
+			jsonHandler(http.StatusOK, `{"data":[{"index":"0","slot":"9","validators":["1","2","3"]},{"index":"1","slot":"9","validators":["4","5","6"]}]}`)(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	}).URL
+}
+
+func TestSlotAttestationsRoute(t *testing.T) {
+	d := newTestDeps(t, newTestConfig(t), newTestServer(t, http.NotFound).URL, twoAttestationConsensus(t))
+	h := buildRouter(d)
+	const want = `{"slot":10,"attestations":[` +
+		`{"committee_index":0,"validators":[1,2],"attested_slot":9},` +
+		`{"committee_index":1,"validators":[6],"attested_slot":9}]}`
+	for _, id := range []string{"10", "head"} {
+		rec := serve(h, http.MethodGet, "/api/v1/slot/"+id+"/attestations", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", id, rec.Code, rec.Body.String())
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != want {
+			t.Errorf("%s: body = %s, want %s", id, got, want)
+		}
+	}
+	if rec := serve(h, http.MethodGet, "/api/v1/slot/11/attestations", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("missing block: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestMarkRecentlyActivated(t *testing.T) {
+	tests := []struct {
+		activation interface{}
+		want       bool
+	}{
+		{json.Number("100"), true}, // this epoch
+		{json.Number("99"), true},
+		{json.Number("98"), false},  // window of 2 epochs passed
+		{json.Number("101"), false}, // pending activation
+		{json.Number("18446744073709551615"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		m := map[string]interface{}{"activationepoch": tt.activation}
+		markRecentlyActivated(m, 100, 2)
+		if _, got := m["recently_activated"]; got != tt.want {
+			t.Errorf("activation epoch %v: recently_activated = %v, want %v", tt.activation, got, tt.want)
+		}
+	}
+}
+
+func TestValidatorRecentlyActivatedFlag(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":[`+
+		`{"validatorindex":1,"status":"active_ongoing","activationepoch":3},`+
+		`{"validatorindex":2,"status":"active_ongoing","activationepoch":0}]}`))
+	d := newTestDeps(t, newTestConfig(t), dora.URL, "")
+	setHeadSlot(d.tracker, 4*32+5) // epoch 4
+
+	rec := serve(buildRouter(d), http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"1,2"}`)
+	data, _ := decodeJSON(t, rec)["data"].([]interface{})
+	if len(data) != 2 {
+		t.Fatalf("body = %s", rec.Body.String())
+	}
+	for i, want := range []interface{}{true, nil} {
+		v, _ := data[i].(map[string]interface{})
+		if v["recently_activated"] != want {
+			t.Errorf("validator %v: recently_activated = %v, want %v", v["validatorindex"], v["recently_activated"], want)
+		}
+	}
+}
+
+// countingTransport counts the requests sent through it.
+type countingTransport struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// The scanner sends its requests through the client it was given, not the
+// one serving user requests.
+func TestTrackerUsesItsOwnClient(t *testing.T) {
+	blocks := &blockCounter{head: 10, fetches: make(map[string]int)}
+	consensus := newTestServer(t, blocks.ServeHTTP)
+	cfg := newTestConfig(t)
+	d := newTestDeps(t, cfg, newTestServer(t, http.NotFound).URL, consensus.URL)
+	proxyTransport := &countingTransport{}
+	d.client.Transport = proxyTransport
+	scannerTransport := &countingTransport{}
+	tr := NewAttestationTracker(&http.Client{Transport: scannerTransport}, d.consensus, cfg, d.cache, d.log)
+
+	if _, outcome := tr.processSlot(context.Background(), 10); outcome != slotPresent {
+		t.Fatalf("outcome = %v, want the block found", outcome)
+	}
+	if scannerTransport.n == 0 || proxyTransport.n != 0 {
+		t.Fatalf("scanner client sent %d requests, proxy client %d; want only the scanner's used", scannerTransport.n, proxyTransport.n)
+	}
+}
+
+// Request routes reading blocks and committees use the proxy client, so a
+// scan backlog on the scanner client doesn't hold them up.
+func TestRequestRoutesUseProxyClient(t *testing.T) {
+	blocks := &blockCounter{head: 10, fetches: make(map[string]int)}
+	consensus := newTestServer(t, blocks.ServeHTTP)
+	cfg := newTestConfig(t)
+	cfg.BlockCacheSize = 0
+	d := newTestDeps(t, cfg, newTestServer(t, http.NotFound).URL, consensus.URL)
+	proxyTransport := &countingTransport{}
+	d.client.Transport = proxyTransport
+	scannerTransport := &countingTransport{}
+	d.tracker = NewAttestationTracker(&http.Client{Transport: scannerTransport}, d.consensus, cfg, d.cache, d.log)
+	h := buildRouter(d)
+
+	for _, target := range []string{"/api/v1/slot/10/attestations", "/api/v1/slot/head/committees", "/api/v1/slot/byblock/1"} {
+		before := proxyTransport.n
+		serve(h, http.MethodGet, target, "")
+		if proxyTransport.n == before {
+			t.Errorf("%s: no request on the proxy client", target)
+		}
+	}
+	if scannerTransport.n != 0 {
+		t.Fatalf("scanner client sent %d requests for user routes, want 0", scannerTransport.n)
+	}
+}
+
+func TestBitlistEmpty(t *testing.T) {
+	tests := []struct {
+		hex  string
+		want bool
+	}{
+		{"0x01", true},    // length marker only
+		{"0x0100", true},  // marker in the first byte, padding after
+		{"0x00", true},    // no marker at all
+		{"", true},        // missing
+		{"0x03", false},   // one participant
+		{"0x0001", true},  // marker in the second byte
+		{"0x0101", false}, // participant 0, marker at bit 8
+		{"not hex", true},
+	}
+	for _, tt := range tests {
+		if got := bitlistEmpty(hexBitlist(tt.hex)); got != tt.want {
+			t.Errorf("bitlistEmpty(%q) = %v, want %v", tt.hex, got, tt.want)
+		}
+	}
+}
+
+// An included attestation without participants is counted as an anomaly.
+func TestProcessSlotCountsEmptyAggregationBits(t *testing.T) {
+	atts := `"attestations":[` +
+		`{"aggregation_bits":"0x08","data":{"slot":"9","index":"0"}},` +
+		`{"aggregation_bits":"0x09","data":{"slot":"9","index":"0"}}]`
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/eth/v2/beacon/blocks/10":
+			jsonHandler(http.StatusOK, blockJSON(10, atts))(w, req)
+		case strings.HasSuffix(req.URL.Path, "/committees"):
+			jsonHandler(http.StatusOK, `{"data":[{"index":"0","slot":"9","validators":["1","2","3"]}]}`)(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	tr := newTestTracker(t, newTestConfig(t), consensus.URL)
+
+	before := emptyAggregationBits.Value()
+	updated, _ := tr.processSlot(context.Background(), 10)
+	if got := emptyAggregationBits.Value() - before; got != 1 {
+		t.Fatalf("empty aggregation bits counted %v times, want 1", got)
+	}
+	if updated != 1 {
+		t.Fatalf("%d validators updated, want only the participant of the other attestation", updated)
+	}
+}
+
+// statusConsensus answers every block request with status and counts them.
+func statusConsensus(t *testing.T, status int, calls *int) string {
+	t.Helper()
+	return newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		*calls++
+		jsonHandler(status, `{"code":`+fmt.Sprint(status)+`,"message":"error"}`)(w, req)
+	}).URL
+}
+
+func TestProcessSlotMissedNotRetried(t *testing.T) {
+	calls := 0
+	cfg := newTestConfig(t)
+	cfg.BlockFetchAttempts = 3
+	cfg.BlockFetchBackoff = time.Millisecond
+	tr := newTestTracker(t, cfg, statusConsensus(t, http.StatusNotFound, &calls))
+
+	if _, outcome := tr.processSlot(context.Background(), 10); outcome != slotMissed {
+		t.Fatalf("outcome = %v, want slotMissed", outcome)
+	}
+	if calls != 1 {
+		t.Fatalf("%d block requests for a missed slot, want 1", calls)
+	}
+}
+
+func TestProcessSlotRetriesServerErrors(t *testing.T) {
+	calls := 0
+	cfg := newTestConfig(t)
+	cfg.BlockFetchAttempts = 3
+	cfg.BlockFetchBackoff = time.Millisecond
+	tr := newTestTracker(t, cfg, statusConsensus(t, http.StatusServiceUnavailable, &calls))
+
+	if _, outcome := tr.processSlot(context.Background(), 10); outcome != slotFailed {
+		t.Fatalf("outcome = %v, want slotFailed", outcome)
+	}
+	if calls != 3 {
+		t.Fatalf("%d block requests, want 3 attempts", calls)
+	}
+}
+
+func TestScanSlotCounters(t *testing.T) {
+	blocks := &blockCounter{head: 10, fetches: make(map[string]int)}
+	tr := newTestTracker(t, newTestConfig(t), newTestServer(t, blocks.ServeHTTP).URL)
+
+	missed, present := scanMissedSlots.Value(), scanPresentSlots.Value()
+	tr.processSlot(context.Background(), 11) // after head: 404
+	if got := scanMissedSlots.Value() - missed; got != 1 {
+		t.Errorf("missed counter advanced by %v on a 404, want 1", got)
+	}
+	tr.processSlot(context.Background(), 10)
+	if got := scanPresentSlots.Value() - present; got != 1 {
+		t.Errorf("present counter advanced by %v, want 1", got)
+	}
+	if got := scanMissedSlots.Value() - missed; got != 1 {
+		t.Errorf("missed counter advanced by %v in total, want 1", got)
+	}
+}
+
+func TestJitterBackoffBounds(t *testing.T) {
+	const base = 100 * time.Millisecond
+	for attempt := 1; attempt <= 3; attempt++ {
+		max := time.Duration(attempt) * base
+		distinct := make(map[time.Duration]bool)
+		for i := 0; i < 200; i++ {
+			d := jitterBackoff(attempt, base)
+			if d < 0 || d > max {
+				t.Fatalf("jitterBackoff(%d, %v) = %v, want within [0, %v]", attempt, base, d, max)
+			}
+			distinct[d] = true
+		}
+		if len(distinct) < 2 {
+			t.Errorf("attempt %d: no jitter across 200 draws", attempt)
+		}
+	}
+	if d := jitterBackoff(1, 0); d != 0 {
+		t.Errorf("jitterBackoff with no base = %v, want 0", d)
+	}
+}
+
+// A block fetch recovers once a transient failure passes.
+func TestFetchBlockRetriesThenSucceeds(t *testing.T) {
+	calls := 0
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		jsonHandler(http.StatusOK, blockJSON(10, ""))(w, req)
+	})
+	cfg := newTestConfig(t)
+	cfg.BlockFetchAttempts = 3
+	cfg.BlockFetchBackoff = time.Millisecond
+	tr := newTestTracker(t, cfg, consensus.URL)
+	if _, outcome := tr.processSlot(context.Background(), 10); outcome != slotPresent {
+		t.Fatalf("outcome = %v after %d calls, want the block found on the third", outcome, calls)
+	}
+}
+
+func TestScanLag(t *testing.T) {
+	tr := newTestTracker(t, newTestConfig(t), newTestServer(t, http.NotFound).URL)
+	if _, ok := tr.ScanLag(); ok {
+		t.Fatal("lag known before anything was scanned")
+	}
+	tr.mu.Lock()
+	tr.headSlot, tr.lastScannedSlot = 1000, 990
+	tr.mu.Unlock()
+	if lag, ok := tr.ScanLag(); !ok || lag != 10 {
+		t.Fatalf("ScanLag = %d, %v; want 10", lag, ok)
+	}
+	tr.recordTick(true)
+	if got := scanLagSlots.Value(); got != 10 {
+		t.Errorf("scan lag gauge = %v, want 10", got)
+	}
+
+	d := newTestDeps(t, newTestConfig(t), newTestServer(t, http.NotFound).URL, "")
+	d.tracker = tr
+	m := decodeJSON(t, serve(buildRouter(d), http.MethodGet, "/api/v1/internal/status", ""))
+	if m["scan_lag_slots"] != float64(10) {
+		t.Errorf("status scan_lag_slots = %v, want 10", m["scan_lag_slots"])
+	}
+
+	tr.mu.Lock()
+	tr.lastScannedSlot = 1001 // scanned past a stale head
+	tr.mu.Unlock()
+	if lag, ok := tr.ScanLag(); !ok || lag != 0 {
+		t.Fatalf("ScanLag = %d, %v; want 0", lag, ok)
+	}
+}
+
+func TestScanLagRewardsSource(t *testing.T) {
+	tr := newTestTracker(t, newTestConfig(t), newTestServer(t, http.NotFound).URL)
+	tr.mu.Lock()
+	tr.source = attestationSourceRewards
+	tr.headSlot, tr.lastScannedEpoch = 100, 2 // covers up to slot 95
+	tr.mu.Unlock()
+	if lag, ok := tr.ScanLag(); !ok || lag != 5 {
+		t.Fatalf("ScanLag = %d, %v; want 5", lag, ok)
+	}
+}
+
+// A gap larger than the per-tick cap is scanned over several ticks.
+func TestScanNewSlotsChunksLargeGap(t *testing.T) {
+	const head = 100
+	blocks := &blockCounter{head: head, fetches: make(map[string]int)}
+	cfg := newTestConfig(t)
+	cfg.BlockCacheSize = 0
+	cfg.ScanMaxSlotsPerTick = 10
+	tr := newTestTracker(t, cfg, newTestServer(t, blocks.ServeHTTP).URL)
+	tr.mu.Lock()
+	tr.lastScannedSlot = 75
+	tr.mu.Unlock()
+
+	tr.scanNewSlots(head)
+	for s := uint64(76); s <= head; s++ {
+		want := 0
+		if s <= 85 {
+			want = 1
+		}
+		if n := blocks.count(fmt.Sprint(s)); n != want {
+			t.Errorf("slot %d fetched %d times after one tick, want %d", s, n, want)
+		}
+	}
+	tr.mu.Lock()
+	last := tr.lastScannedSlot
+	tr.mu.Unlock()
+	if last != 85 {
+		t.Fatalf("last scanned slot = %d after one tick, want 85", last)
+	}
+
+	for _, want := range []uint64{95, head, head} {
+		tr.scanNewSlots(head)
+		tr.mu.Lock()
+		last = tr.lastScannedSlot
+		tr.mu.Unlock()
+		if last != want {
+			t.Fatalf("last scanned slot = %d, want %d", last, want)
+		}
+	}
+	for s := uint64(76); s <= head; s++ {
+		if n := blocks.count(fmt.Sprint(s)); n != 1 {
+			t.Errorf("slot %d fetched %d times, want 1", s, n)
+		}
+	}
+}
+
+func TestLastAttestCacheSnapshotAndReset(t *testing.T) {
+	c := NewLastAttestCache()
+	for idx, slot := range map[uint64]uint64{1: 100, 5: 500, 9: 900} {
+		c.SetIfGreater(idx, slot)
+	}
+	if got := c.Snapshot(2, 9); !reflect.DeepEqual(got, map[uint64]uint64{5: 500, 9: 900}) {
+		t.Errorf("Snapshot(2, 9) = %v", got)
+	}
+	if n := c.Reset(); n != 3 {
+		t.Errorf("Reset cleared %d entries, want 3", n)
+	}
+	if got := c.Snapshot(0, math.MaxUint64); len(got) != 0 {
+		t.Errorf("Snapshot after Reset = %v, want empty", got)
+	}
+}
+
+func TestConcurrentCommitteeFetchesCoalesce(t *testing.T) {
+	var requests atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if requests.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		jsonHandler(http.StatusOK, `{"data":[{"index":"0","slot":"9","validators":["1","2","3"]}]}`)(w, req)
+	})
+	tr := newTestTracker(t, newTestConfig(t), consensus.URL)
+
+	const callers = 10
+	results := make([]map[uint64][]uint64, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = tr.fetchCommitteesForSlot(context.Background(), tr.client, 9)
+		}(i)
+	}
+	<-started
+	time.Sleep(100 * time.Millisecond) // let the other callers join the flight
+	close(release)
+	wg.Wait()
+
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("%d committee requests for %d concurrent callers, want 1", n, callers)
+	}
+	want := map[uint64][]uint64{0: {1, 2, 3}}
+	for i, got := range results {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("caller %d got %v, want %v", i, got, want)
+		}
+	}
+}
+
+// A caller giving up does not fail the callers sharing its fetch.
+func TestCommitteeFetchSurvivesCanceledCaller(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		once.Do(func() { close(started) })
+		<-release
+		jsonHandler(http.StatusOK, `{"data":[{"index":"0","slot":"9","validators":["1"]}]}`)(w, req)
+	})
+	tr := newTestTracker(t, newTestConfig(t), consensus.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan map[uint64][]uint64)
+	go func() { first <- tr.fetchCommitteesForSlot(ctx, tr.client, 9) }()
+	<-started
+	second := make(chan map[uint64][]uint64)
+	go func() { second <- tr.fetchCommitteesForSlot(context.Background(), tr.client, 9) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	close(release)
+	<-first
+	if got := <-second; len(got[0]) != 1 {
+		t.Fatalf("second caller got %v, want the committee", got)
+	}
+}
+
+func TestBackfillProgressGauges(t *testing.T) {
+	const head = 40
+	blocks := &blockCounter{head: head, fetches: make(map[string]int)}
+	var mu sync.Mutex
+	var seen []float64 // scanned gauge at each block fetch
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		seen = append(seen, backfillSlotsScanned.Value())
+		mu.Unlock()
+		blocks.ServeHTTP(w, req)
+	})
+	cfg := newTestConfig(t)
+	cfg.BlockCacheSize = 0
+	tr := newTestTracker(t, cfg, consensus.URL)
+
+	scanned, _, err := tr.scanEpochRange(context.Background(), 1, 0, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanned != head+1 {
+		t.Fatalf("scanned %d slots, want %d", scanned, head+1)
+	}
+	if got := backfillSlotsTotal.Value(); got != head+1 {
+		t.Errorf("total gauge = %v, want %d", got, head+1)
+	}
+	if got := backfillSlotsScanned.Value(); got != head+1 {
+		t.Errorf("scanned gauge = %v after the scan, want %d", got, head+1)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	advanced := false
+	for _, v := range seen {
+		if v > 0 && v < head+1 {
+			advanced = true
+		}
+	}
+	if !advanced {
+		t.Errorf("scanned gauge never moved during the scan: %v", seen)
+	}
+}
+
+func TestCommitteeVoters(t *testing.T) {
+	committees := map[uint64][]uint64{0: {10, 11, 12}, 1: {20, 21}}
+	tests := []struct {
+		name        string
+		att         map[string]interface{}
+		want        []attestationCommittee
+		wantUnknown uint64
+	}{
+		{"data.index", map[string]interface{}{
+			"aggregation_bits": "0x0d", // 1,0,1 + delimiter
+			"data":             map[string]interface{}{"index": "0"},
+		}, []attestationCommittee{{CommitteeIndex: 0, Validators: []uint64{10, 12}}}, 0},
+		{"committee_bits over two committees", map[string]interface{}{
+			"aggregation_bits": "0x32", // 0,1,0 | 0,1 + delimiter
+			"committee_bits":   "0x03",
+		}, []attestationCommittee{
+			{CommitteeIndex: 0, Validators: []uint64{11}},
+			{CommitteeIndex: 1, Validators: []uint64{21}},
+		}, 0},
+		{"no committee bit set", map[string]interface{}{
+			"aggregation_bits": "0x0f",
+			"committee_bits":   "0x00",
+		}, nil, 0},
+		{"committee bit out of range", map[string]interface{}{
+			"aggregation_bits": "0x3f",
+			"committee_bits":   "0x05", // committees 0 and 2; the slot has 0 and 1
+		}, nil, 1},
+		{"data.index out of range", map[string]interface{}{
+			"aggregation_bits": "0x0f",
+			"data":             map[string]interface{}{"index": "7"},
+		}, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := unknownCommitteeBits.Value()
+			got := committeeVoters(tt.att, committees)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("committeeVoters = %v, want %v", got, tt.want)
+			}
+			if d := unknownCommitteeBits.Value() - before; d != tt.wantUnknown {
+				t.Errorf("unknown committee counter advanced by %v, want %v", d, tt.wantUnknown)
+			}
+		})
+	}
+}
+
+func TestHeadBackoffTicks(t *testing.T) {
+	want := []int{0, 1, 3, 7, 15, 24, 24, 24}
+	var got []int
+	prev := time.Duration(0)
+	for failures := 1; failures <= len(want); failures++ {
+		skip := headBackoffTicks(failures, 12)
+		got = append(got, skip)
+		interval := time.Duration(skip+1) * 12 * time.Second
+		if interval > maxHeadBackoff {
+			t.Errorf("failure %d: interval %v over the cap", failures, interval)
+		}
+		if interval < prev {
+			t.Errorf("failure %d: interval %v shrank from %v", failures, interval, prev)
+		}
+		prev = interval
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ticks skipped = %v, want %v", got, want)
+	}
+	if got := headBackoffTicks(1000, 12); got != 24 {
+		t.Errorf("after many failures: %d ticks, want the 5m cap of 24", got)
+	}
+	if got := headBackoffTicks(5, 0); got != 0 {
+		t.Errorf("without slot timing: %d ticks, want 0", got)
+	}
+}
+
+func TestScanStartMode(t *testing.T) {
+	for _, mode := range []string{scanStartHead, scanStartContinue} {
+		t.Run(mode, func(t *testing.T) {
+			blocks := &blockCounter{head: 40, fetches: make(map[string]int)}
+			cfg := newTestConfig(t)
+			cfg.BlockCacheSize = 0
+			cfg.ScanStartMode = mode
+			tr := newTestTracker(t, cfg, newTestServer(t, blocks.ServeHTTP).URL)
+			if err := tr.Backfill(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			// head moved on while the backfill ran
+			blocks.mu.Lock()
+			blocks.head = 45
+			blocks.mu.Unlock()
+			tr.scanNewSlots(45)
+
+			for s := uint64(41); s <= 45; s++ {
+				want := 1
+				if mode == scanStartHead && s < 45 {
+					want = 0
+				}
+				if n := blocks.count(fmt.Sprint(s)); n != want {
+					t.Errorf("slot %d fetched %d times, want %d", s, n, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigScanStartMode(t *testing.T) {
+	t.Setenv("PROXY_SCAN_START_MODE", "Continue-From-Backfill")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ScanStartMode != scanStartContinue {
+		t.Errorf("ScanStartMode = %q, want %q", cfg.ScanStartMode, scanStartContinue)
+	}
+	t.Setenv("PROXY_SCAN_START_MODE", "tail")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "PROXY_SCAN_START_MODE") {
+		t.Errorf("loadConfig error = %v, want one naming PROXY_SCAN_START_MODE", err)
+	}
+}
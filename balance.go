@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// BalanceCache remembers the balances seen for each validator in validator
+// responses, so a response can report how a balance moved since the previous
+// different value was seen.
+type BalanceCache struct {
+	mu sync.Mutex
+	m  map[uint64]balanceSnapshot
+
+	hits, misses atomic.Uint64
+}
+
+type balanceSnapshot struct {
+	cur, prev uint64
+	hasPrev   bool
+}
+
+func NewBalanceCache() *BalanceCache {
+	return &BalanceCache{m: make(map[uint64]balanceSnapshot)}
+}
+
+// Observe records balance for index and returns the change from the
+// previous distinct balance. ok is false while only one balance is known.
+// Seeing the same balance again reports the last change again.
+func (c *BalanceCache) Observe(index, balance uint64) (change int64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, seen := c.m[index]
+	if !seen {
+		c.misses.Add(1)
+		c.m[index] = balanceSnapshot{cur: balance}
+		return 0, false
+	}
+	c.hits.Add(1)
+	if balance != s.cur {
+		s = balanceSnapshot{cur: balance, prev: s.cur, hasPrev: true}
+		c.m[index] = s
+	}
+	if !s.hasPrev {
+		return 0, false
+	}
+	return int64(s.cur) - int64(s.prev), true
+}
+
+func (c *BalanceCache) Stats() cacheStats {
+	c.mu.Lock()
+	n := len(c.m)
+	c.mu.Unlock()
+	return cacheStats{Name: "balance", Entries: n, Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// attachBalanceChange sets balance_change on a validator object: the change
+// in gwei since the previous balance seen for it, or null when none is known.
+func attachBalanceChange(m map[string]interface{}, balances *BalanceCache, resolve func(pubkey string) (uint64, bool)) {
+	m["balance_change"] = nil
+	idx, ok := validatorIndexOf(m, resolve)
+	if !ok {
+		return
+	}
+	balance, ok := parseUint64FromInterface(m["balance"])
+	if !ok {
+		return
+	}
+	if change, ok := balances.Observe(idx, balance); ok {
+		m["balance_change"] = change
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestBalanceCacheObserve(t *testing.T) {
+	c := NewBalanceCache()
+	steps := []struct {
+		balance uint64
+		change  int64
+		ok      bool
+	}{
+		{32000000000, 0, false}, // first seen
+		{32000000000, 0, false}, // unchanged, still no previous value
+		{32000001000, 1000, true},
+		{32000001000, 1000, true}, // same balance reports the last change
+		{31999999000, -2000, true},
+	}
+	for i, s := range steps {
+		change, ok := c.Observe(7, s.balance)
+		if change != s.change || ok != s.ok {
+			t.Errorf("step %d: Observe(%d) = %d, %v; want %d, %v", i, s.balance, change, ok, s.change, s.ok)
+		}
+	}
+	if st := c.Stats(); st.Entries != 1 || st.Misses != 1 || st.Hits != 4 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestValidatorBalanceChange(t *testing.T) {
+	balances := []uint64{32000000000, 31999990000}
+	calls := 0
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		body := fmt.Sprintf(`{"status":"OK","data":[{"validatorindex":1,"status":"active_ongoing","balance":%d}]}`, balances[calls])
+		calls++
+		jsonHandler(http.StatusOK, body)(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, ""))
+
+	for i, want := range []interface{}{nil, float64(-10000)} {
+		rec := serve(h, http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"1"}`)
+		data, _ := decodeJSON(t, rec)["data"].([]interface{})
+		if len(data) != 1 {
+			t.Fatalf("response %d: %s", i, rec.Body.String())
+		}
+		v, _ := data[0].(map[string]interface{})
+		change, has := v["balance_change"]
+		if !has || change != want {
+			t.Errorf("response %d: balance_change = %v (present %v), want %v", i, change, has, want)
+		}
+	}
+}
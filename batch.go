@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ValidatorBatcher coalesces POST /api/v1/validator requests arriving within
+// a short window into one upstream request for the union of their
+// validators, then hands each caller the subset it asked for. Only requests
+// with the same query string and the same headers to forward share a batch,
+// and the upstream request carries them.
+type ValidatorBatcher struct {
+	proxy        *UpstreamProxy
+	upstreamPath string
+	window       time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*validatorBatch // by batchGroup
+}
+
+// validatorBatch is one coalesced upstream request. Its result fields are
+// written once by flush and read by callers after done is closed.
+type validatorBatch struct {
+	group    string
+	rawQuery string
+	header   http.Header
+	keys     []string
+	seen     map[string]struct{}
+	done     chan struct{}
+
+	err      error
+	status   int
+	raw      []byte                     // upstream body, when not a data array
+	root     map[string]json.RawMessage // envelope members other than data
+	data     []json.RawMessage
+	elemKeys [][]string // lowercase index and pubkey of each data element
+}
+
+func NewValidatorBatcher(proxy *UpstreamProxy, upstreamPath string, window time.Duration) *ValidatorBatcher {
+	return &ValidatorBatcher{proxy: proxy, upstreamPath: upstreamPath, window: window, pending: make(map[string]*validatorBatch)}
+}
+
+// batchHeader returns the headers a batched upstream request for req
+// carries: those the unbatched request would forward, less Content-Length,
+// which belongs to the caller's own body.
+func (b *ValidatorBatcher) batchHeader(req *http.Request) http.Header {
+	header := make(http.Header)
+	b.proxy.headers.copy(header, b.proxy.requestHeader(req, false))
+	header.Del("Content-Length")
+	header.Set("Content-Type", "application/json")
+	return header
+}
+
+// batchGroup identifies the requests that may share a batch: the query
+// string and the headers to forward, names sorted.
+func batchGroup(rawQuery string, header http.Header) string {
+	names := make([]string, 0, len(header))
+	for k := range header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString(rawQuery)
+	for _, k := range names {
+		sb.WriteString("\n")
+		sb.WriteString(k)
+		sb.WriteByte(':')
+		sb.WriteString(strings.Join(header[k], "\x00"))
+	}
+	return sb.String()
+}
+
+// validatorBatchKeys returns the validators requested by body when it only
+// carries an indicesOrPubkey list; other bodies are not batched.
+func validatorBatchKeys(body []byte) ([]string, bool) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(body, &m); err != nil || len(m) != 1 {
+		return nil, false
+	}
+	var list string
+	if err := json.Unmarshal(m["indicesOrPubkey"], &list); err != nil {
+		return nil, false
+	}
+	var keys []string
+	for _, p := range strings.Split(list, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if idx, err := strconv.ParseUint(p, 10, 64); err == nil {
+			p = strconv.FormatUint(idx, 10) // match validatorindex as upstream renders it
+		}
+		if p != "" {
+			keys = append(keys, p)
+		}
+	}
+	return keys, len(keys) > 0
+}
+
+// Fetch adds the keys requested by req to the pending batch for its query
+// string and headers, starting one if needed, and waits for its upstream
+// response.
+func (b *ValidatorBatcher) Fetch(req *http.Request, keys []string) (*validatorBatch, error) {
+	header := b.batchHeader(req)
+	group := batchGroup(req.URL.RawQuery, header)
+	b.mu.Lock()
+	batch := b.pending[group]
+	if batch == nil {
+		batch = &validatorBatch{
+			group:    group,
+			rawQuery: req.URL.RawQuery,
+			header:   header,
+			seen:     make(map[string]struct{}),
+			done:     make(chan struct{}),
+		}
+		b.pending[group] = batch
+		time.AfterFunc(b.window, func() { b.flush(batch) })
+	}
+	for _, k := range keys {
+		if _, ok := batch.seen[k]; !ok {
+			batch.seen[k] = struct{}{}
+			batch.keys = append(batch.keys, k)
+		}
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-batch.done:
+		return batch, batch.err
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+func (b *ValidatorBatcher) flush(batch *validatorBatch) {
+	defer close(batch.done)
+	b.mu.Lock()
+	if b.pending[batch.group] == batch {
+		delete(b.pending, batch.group)
+	}
+	b.mu.Unlock()
+
+	body, _ := json.Marshal(map[string]string{"indicesOrPubkey": strings.Join(batch.keys, ",")})
+	// Not bound to any one caller: a caller giving up must not fail the others
+	resp, err := b.proxy.do(context.Background(), http.MethodPost, b.upstreamPath, batch.rawQuery, batch.header, body)
+	if err != nil {
+		batch.err = &ErrUpstreamUnreachable{Err: err}
+		return
+	}
+	defer resp.Body.Close()
+	batch.status = resp.StatusCode
+	batch.raw, err = io.ReadAll(b.proxy.limitBody(resp.Body))
+	if err != nil {
+		batch.err = b.proxy.readError(b.upstreamPath, resp.StatusCode, err)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	var root map[string]json.RawMessage
+	if json.Unmarshal(batch.raw, &root) != nil {
+		return
+	}
+	var data []json.RawMessage
+	if json.Unmarshal(root["data"], &data) != nil {
+		return
+	}
+	delete(root, "data")
+	batch.root = root
+	batch.data = data
+	batch.elemKeys = make([][]string, len(data))
+	for i, el := range data {
+		var v struct {
+			ValidatorIndex interface{} `json:"validatorindex"`
+			Pubkey         string      `json:"pubkey"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(el))
+		dec.UseNumber()
+		if dec.Decode(&v) != nil {
+			continue
+		}
+		if idx, ok := parseUint64FromInterface(v.ValidatorIndex); ok {
+			batch.elemKeys[i] = append(batch.elemKeys[i], strconv.FormatUint(idx, 10))
+		}
+		if v.Pubkey != "" {
+			batch.elemKeys[i] = append(batch.elemKeys[i], strings.ToLower(v.Pubkey))
+		}
+	}
+}
+
+// Respond writes the part of batch requested by keys, applying transform to
+// a private copy of each validator object. A non-nil page limits the
+// validators returned.
+func (batch *validatorBatch) Respond(w http.ResponseWriter, keys []string, transform func(interface{}), page *dataPage) {
+	w.Header().Set("Content-Type", "application/json")
+	if batch.data == nil && batch.status == http.StatusOK {
+		// Can't pick this caller's validators out of it
+		writeError(w, http.StatusBadGateway, "unexpected upstream validator response")
+		return
+	}
+	if batch.data == nil {
+		// Upstream error: every caller gets the upstream body
+		w.WriteHeader(batch.status)
+		w.Write(batch.raw)
+		return
+	}
+
+	// Walk the batch in upstream order, as the unbatched path streams it, so
+	// paging doesn't depend on how the caller ordered its keys
+	want := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		want[k] = struct{}{}
+	}
+	out := make([]interface{}, 0, len(keys))
+	total := 0
+	for i, el := range batch.data {
+		if !batch.requested(i, want) {
+			continue
+		}
+		total++
+		if !page.includes(total - 1) {
+			continue
+		}
+		dec := json.NewDecoder(bytes.NewReader(el))
+		dec.UseNumber()
+		var v interface{}
+		if dec.Decode(&v) != nil {
+			continue
+		}
+		transform(v)
+		out = append(out, v)
+	}
+
+	resp := make(map[string]interface{}, len(batch.root)+1)
+	for k, v := range batch.root {
+		resp[k] = v
+	}
+	resp["data"] = out
+	if _, has := resp["status"]; !has {
+		resp["status"] = "OK"
+	}
+	if page != nil {
+		resp["total"] = total
+		if next := page.offset + page.limit; next < total {
+			resp["next_offset"] = next
+		} else {
+			resp["next_offset"] = nil
+		}
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to marshal response")
+		return
+	}
+	w.WriteHeader(batch.status)
+	w.Write(body)
+}
+
+// requested reports whether data element i is one of the wanted validators,
+// by index or by pubkey.
+func (batch *validatorBatch) requested(i int, want map[string]struct{}) bool {
+	for _, k := range batch.elemKeys[i] {
+		if _, ok := want[k]; ok {
+			return true
+		}
+	}
+	return false
+}
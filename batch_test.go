@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// validatorDora answers validator POSTs with the requested indices in
+// ascending order, as Dora does, recording each request.
+type validatorDora struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+func (d *validatorDora) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		IndicesOrPubkey string `json:"indicesOrPubkey"`
+	}
+	json.NewDecoder(req.Body).Decode(&body)
+	d.mu.Lock()
+	d.requests = append(d.requests, req)
+	d.bodies = append(d.bodies, body.IndicesOrPubkey)
+	d.mu.Unlock()
+
+	var indices []int
+	for _, p := range strings.Split(body.IndicesOrPubkey, ",") {
+		if n, err := strconv.Atoi(p); err == nil {
+			indices = append(indices, n)
+		}
+	}
+	sort.Ints(indices)
+	els := make([]string, len(indices))
+	for i, n := range indices {
+		els[i] = fmt.Sprintf(`{"validatorindex":%d,"pubkey":"0x%02x","status":"active_ongoing"}`, n, n)
+	}
+	jsonHandler(http.StatusOK, `{"status":"OK","data":[`+strings.Join(els, ",")+`]}`)(w, req)
+}
+
+func (d *validatorDora) calls() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.requests)
+}
+
+// newBatchingRouter returns a router batching validator POSTs within 50ms.
+func newBatchingRouter(t *testing.T, dora *validatorDora) http.Handler {
+	t.Helper()
+	cfg := newTestConfig(t)
+	cfg.ValidatorBatchWindow = 50 * time.Millisecond
+	return buildRouter(newTestDeps(t, cfg, newTestServer(t, dora.ServeHTTP).URL, ""))
+}
+
+// validatorIndices returns the validatorindex of each element of data.
+func validatorIndices(t *testing.T, rec *httptest.ResponseRecorder) []string {
+	t.Helper()
+	data, ok := decodeJSON(t, rec)["data"].([]interface{})
+	if !ok {
+		t.Fatalf("no data array: %s", rec.Body.String())
+	}
+	var out []string
+	for _, el := range data {
+		v, _ := el.(map[string]interface{})
+		out = append(out, fmt.Sprint(v["validatorindex"]))
+	}
+	return out
+}
+
+// postConcurrently sends each request at once and returns the responses in
+// the same order.
+func postConcurrently(h http.Handler, reqs []*http.Request) []*httptest.ResponseRecorder {
+	recs := make([]*httptest.ResponseRecorder, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = httptest.NewRecorder()
+			h.ServeHTTP(recs[i], req)
+		}()
+	}
+	wg.Wait()
+	return recs
+}
+
+func validatorPost(target, list string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"indicesOrPubkey":"`+list+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// Overlapping concurrent requests share one upstream call and each caller
+// gets exactly the validators it asked for.
+func TestValidatorBatchCoalesces(t *testing.T) {
+	dora := &validatorDora{}
+	h := newBatchingRouter(t, dora)
+	lists := []string{"1,2", "2,3", "0x03"}
+	var reqs []*http.Request
+	for _, l := range lists {
+		reqs = append(reqs, validatorPost("/api/v1/validator", l))
+	}
+	recs := postConcurrently(h, reqs)
+
+	if n := dora.calls(); n != 1 {
+		t.Fatalf("%d upstream calls, want 1", n)
+	}
+	got := strings.Split(dora.bodies[0], ",")
+	sort.Strings(got)
+	if strings.Join(got, ",") != "0x03,1,2,3" {
+		t.Errorf("upstream asked for %q, want the union", dora.bodies[0])
+	}
+	for i, want := range []string{"1,2", "2,3", "3"} {
+		if recs[i].Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, recs[i].Code)
+		}
+		if got := strings.Join(validatorIndices(t, recs[i]), ","); got != want {
+			t.Errorf("request %s: validators %s, want %s", lists[i], got, want)
+		}
+	}
+}
+
+// Requests differing in query or forwarded headers are not batched
+// together, and each batch carries its own.
+func TestValidatorBatchGroups(t *testing.T) {
+	dora := &validatorDora{}
+	h := newBatchingRouter(t, dora)
+	a := validatorPost("/api/v1/validator", "1")
+	a.Header.Set("X-Tenant", "a")
+	b := validatorPost("/api/v1/validator", "2")
+	b.Header.Set("X-Tenant", "b")
+	c := validatorPost("/api/v1/validator?fields=x", "3")
+	c.Header.Set("X-Tenant", "a")
+	postConcurrently(h, []*http.Request{a, b, c})
+
+	if n := dora.calls(); n != 3 {
+		t.Fatalf("%d upstream calls, want one per group", n)
+	}
+	for i, req := range dora.requests {
+		want := map[string]string{"1": "a", "2": "b", "3": "a"}[dora.bodies[i]]
+		if got := req.Header.Get("X-Tenant"); got != want {
+			t.Errorf("batch %s: X-Tenant = %q, want %q", dora.bodies[i], got, want)
+		}
+		if dora.bodies[i] == "3" && req.URL.RawQuery != "fields=x" {
+			t.Errorf("batch 3: query = %q, want fields=x", req.URL.RawQuery)
+		}
+	}
+}
+
+// Pages follow upstream order, not the order the caller listed its keys.
+func TestValidatorBatchPaging(t *testing.T) {
+	dora := &validatorDora{}
+	h := newBatchingRouter(t, dora)
+	recs := postConcurrently(h, []*http.Request{
+		validatorPost("/api/v1/validator?limit=2", "5,1,3"),
+		validatorPost("/api/v1/validator?limit=2&offset=2", "5,1,3"),
+	})
+	for i, want := range []struct {
+		indices string
+		next    interface{}
+	}{{"1,3", float64(2)}, {"5", nil}} {
+		if got := strings.Join(validatorIndices(t, recs[i]), ","); got != want.indices {
+			t.Errorf("page %d: validators %s, want %s", i, got, want.indices)
+		}
+		m := decodeJSON(t, recs[i])
+		if m["total"] != float64(3) || m["next_offset"] != want.next {
+			t.Errorf("page %d: total %v, next_offset %v; want 3, %v", i, m["total"], m["next_offset"], want.next)
+		}
+	}
+	if n := dora.calls(); n != 1 {
+		t.Errorf("%d upstream calls, want the pages batched together", n)
+	}
+}
+
+func TestValidatorBatchKeys(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+		ok   bool
+	}{
+		{`{"indicesOrPubkey":"1, 007,0xAB"}`, "1,7,0xab", true},
+		{`{"indicesOrPubkey":""}`, "", false},
+		{`{"indicesOrPubkey":"1","extra":true}`, "", false},
+		{`not json`, "", false},
+	}
+	for _, tt := range tests {
+		keys, ok := validatorBatchKeys([]byte(tt.body))
+		if ok != tt.ok || strings.Join(keys, ",") != tt.want {
+			t.Errorf("validatorBatchKeys(%s) = %q, %v; want %q, %v", tt.body, keys, ok, tt.want, tt.ok)
+		}
+	}
+}
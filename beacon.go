@@ -3,37 +3,56 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// resolveHeadRoot queries the consensus REST API to resolve the head beacon block root.
-func resolveHeadRoot(ctx context.Context, client *http.Client, consensusAPI string) (string, error) {
-	base := strings.TrimRight(consensusAPI, "/")
-	url := base + "/eth/v1/beacon/headers/head"
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return "", err
+// resolveHeadRoot queries the consensus REST API to resolve the head beacon
+// block. It returns the block root, or the head slot number when only that is
+// available, trying the headers endpoint first, then the block root endpoint
+// and finally the full block; both IDs are accepted by Dora's slot route.
+func resolveHeadRoot(ctx context.Context, client *http.Client, consensus *ConsensusNodes) (string, error) {
+	id, err := resolveHeadFromHeader(ctx, client, consensus)
+	if err == nil {
+		return id, nil
+	}
+	id, rootErr := resolveHeadFromRoot(ctx, client, consensus)
+	if rootErr == nil {
+		return id, nil
+	}
+	id, fbErr := resolveHeadRootFallback(ctx, client, consensus)
+	if fbErr != nil {
+		return "", fmt.Errorf("headers: %v; root: %v; blocks: %w", err, rootErr, fbErr)
 	}
-	req.Header.Set("Accept", "application/json")
+	return id, nil
+}
 
-	resp, err := client.Do(req)
+func resolveHeadFromHeader(ctx context.Context, client *http.Client, consensus *ConsensusNodes) (string, error) {
+	resp, err := consensus.Get(ctx, client, "/eth/v1/beacon/headers/head")
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		// try v2 blocks endpoint as a fallback
-		return resolveHeadRootFallback(ctx, client, base)
+		return "", fmt.Errorf("status %d", resp.StatusCode)
 	}
 
 	var payload struct {
 		Data struct {
-			Root string `json:"root"`
+			Root   string `json:"root"`
+			Header struct {
+				Message struct {
+					Slot string `json:"slot"`
+				} `json:"message"`
+			} `json:"header"`
 		} `json:"data"`
 	}
 	dec := json.NewDecoder(resp.Body)
@@ -41,81 +60,155 @@ func resolveHeadRoot(ctx context.Context, client *http.Client, consensusAPI stri
 		return "", err
 	}
 
-	if payload.Data.Root == "" {
-		return resolveHeadRootFallback(ctx, client, base)
+	if payload.Data.Root != "" {
+		return payload.Data.Root, nil
 	}
-	return payload.Data.Root, nil
+	if slot, ok := parseUint64FromInterface(payload.Data.Header.Message.Slot); ok {
+		return strconv.FormatUint(slot, 10), nil
+	}
+	return "", errors.New("no root or slot in head header")
 }
 
-func resolveHeadRootFallback(ctx context.Context, client *http.Client, base string) (string, error) {
-	url := base + "/eth/v2/beacon/blocks/head"
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func resolveHeadFromRoot(ctx context.Context, client *http.Client, consensus *ConsensusNodes) (string, error) {
+	resp, err := consensus.Get(ctx, client, "/eth/v1/beacon/blocks/head/root")
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Accept", "application/json")
-	resp, err := client.Do(req)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Data struct {
+			Root string `json:"root"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", err
+	}
+	if payload.Data.Root == "" {
+		return "", errors.New("no root in head block root")
+	}
+	return payload.Data.Root, nil
+}
+
+func resolveHeadRootFallback(ctx context.Context, client *http.Client, consensus *ConsensusNodes) (string, error) {
+	resp, err := consensus.Get(ctx, client, "/eth/v2/beacon/blocks/head")
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
-	// best-effort parse: check top-level root, or data.root
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	// best-effort parse: check top-level root, or data.root, then fall back
+	// to the block's slot number
 	var m map[string]interface{}
 	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
 	if err := dec.Decode(&m); err != nil {
 		return "", err
 	}
 	if v, ok := m["root"].(string); ok && v != "" {
 		return v, nil
 	}
-	if data, ok := m["data"].(map[string]interface{}); ok {
+	data, _ := m["data"].(map[string]interface{})
+	if data != nil {
 		if v, ok := data["root"].(string); ok && v != "" {
 			return v, nil
 		}
+		message, _ := data["message"].(map[string]interface{})
+		if slot, ok := parseUint64FromInterface(message["slot"]); ok {
+			return strconv.FormatUint(slot, 10), nil
+		}
 	}
-	return "", io.EOF
+	return "", errors.New("no root or slot in head block")
 }
 
-// enrichSlotConsensus fetches the beacon block from the consensus REST API and fills
-// missing execution/eth1 fields in the provided slot data map.
-func enrichSlotConsensus(ctx context.Context, client *http.Client, consensusAPI string, blockID string, slotData map[string]interface{}) {
-	base := strings.TrimRight(consensusAPI, "/")
-	url := base + "/eth/v2/beacon/blocks/" + blockID
+// HeadResolver caches the result of resolveHeadRoot for a short TTL so bursts
+// of head requests share one consensus lookup.
+type HeadResolver struct {
+	client    *http.Client
+	consensus *ConsensusNodes
+	ttl       time.Duration
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return
-	}
-	req.Header.Set("Accept", "application/json")
+	// mu is held across the lookup so concurrent callers wait for it
+	// instead of issuing their own.
+	mu       sync.Mutex
+	id       string
+	resolved time.Time
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return
+	hits, misses atomic.Uint64
+}
+
+func NewHeadResolver(client *http.Client, consensus *ConsensusNodes, ttl time.Duration) *HeadResolver {
+	return &HeadResolver{client: client, consensus: consensus, ttl: ttl}
+}
+
+// Resolve returns the head block root (or slot number, see resolveHeadRoot),
+// reusing a result younger than the TTL. Failures are not cached.
+func (h *HeadResolver) Resolve(ctx context.Context) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.id != "" && time.Since(h.resolved) < h.ttl {
+		h.hits.Add(1)
+		return h.id, nil
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return
+	h.misses.Add(1)
+	id, err := resolveHeadRoot(ctx, h.client, h.consensus)
+	if err != nil {
+		return "", err
 	}
+	h.id, h.resolved = id, time.Now()
+	return id, nil
+}
 
-	var payload map[string]interface{}
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&payload); err != nil {
-		return
+// Stats reports the head cache TTL, the age of the cached head and the hit
+// rate.
+func (h *HeadResolver) Stats() cacheStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := cacheStats{Name: "head", TTLSeconds: h.ttl.Seconds(), Hits: h.hits.Load(), Misses: h.misses.Load()}
+	if h.id != "" && time.Since(h.resolved) < h.ttl {
+		st.Entries = 1
+		st.setAges(time.Now(), h.resolved, h.resolved)
 	}
+	return st
+}
 
-	data, _ := payload["data"].(map[string]interface{})
-	if data == nil {
-		return
+// enrichSlotConsensus fetches the beacon block from the consensus REST API and fills
+// missing execution/eth1 fields in the provided slot data map. A block found in
+// blocks is used instead of fetching it again. It returns an error when the
+// block could not be fetched; slotData is then left unchanged.
+func enrichSlotConsensus(ctx context.Context, client *http.Client, consensus *ConsensusNodes, blocks *BlockCache, blockID string, slotData map[string]interface{}) error {
+	data, ok := blocks.Get(blockID)
+	if !ok {
+		var err error
+		if data, err = fetchBlockData(ctx, client, consensus, blocks, blockID); err != nil {
+			return err
+		}
 	}
 	message, _ := data["message"].(map[string]interface{})
 	if message == nil {
-		return
+		return errors.New("consensus block response has no message")
 	}
 	body, _ := message["body"].(map[string]interface{})
 	if body == nil {
-		return
+		return errors.New("consensus block response has no body")
+	}
+
+	// Proposer: Dora may not have it yet for the newest slots, leaving 0. A
+	// zero from Dora is indistinguishable from validator 0 (e.g. at slot 0),
+	// so the consensus value decides in both cases.
+	if v, ok := parseUint64FromInterface(message["proposer_index"]); ok {
+		setUintIfZero(slotData, "proposer", v)
 	}
 
+	slotData["attester_slashings"] = parseAttesterSlashings(body)
+	slotData["proposer_slashings"] = parseProposerSlashings(body)
+	slotData["voluntary_exits"] = parseVoluntaryExits(body)
+	slotData["deposits"] = parseDeposits(body)
+
 	// Add dora missing fields: signature
 	if sig, ok := data["signature"].(string); ok && sig != "" {
 		setStringIfEmpty(slotData, "signature", sig)
@@ -172,7 +265,211 @@ func enrichSlotConsensus(ctx context.Context, client *http.Client, consensusAPI
 			setStringIfEmpty(slotData, "exec_timestamp", v)
 		}
 
+		// Withdrawals (gwei); a payload without any sums to 0
+		var total uint64
+		withdrawals, _ := exec["withdrawals"].([]interface{})
+		for _, w := range withdrawals {
+			if wm, ok := w.(map[string]interface{}); ok {
+				if n, ok := parseUint64FromInterface(wm["amount"]); ok {
+					total += n
+				}
+			}
+		}
+		slotData["withdrawal_total_amount"] = total
+	}
+	return nil
+}
+
+// fetchBlockData fetches /eth/v2/beacon/blocks/{blockID} and returns its
+// "data" object, storing it in blocks.
+func fetchBlockData(ctx context.Context, client *http.Client, consensus *ConsensusNodes, blocks *BlockCache, blockID string) (map[string]interface{}, error) {
+	resp, err := consensus.Get(ctx, client, "/eth/v2/beacon/blocks/"+blockID)
+	if err != nil {
+		// keep the consensus URL out of the error, it is shown to clients
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("consensus block request aborted: %w", ctx.Err())
+		}
+		return nil, errors.New("consensus node unreachable")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consensus block request returned status %d", resp.StatusCode)
+	}
+
+	var payload map[string]interface{}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode consensus block: %w", err)
 	}
+
+	data, _ := payload["data"].(map[string]interface{})
+	if data == nil {
+		return nil, errors.New("consensus block response has no data")
+	}
+	if message, _ := data["message"].(map[string]interface{}); message != nil {
+		blocks.Set(blockID, data)
+	}
+	return data, nil
+}
+
+// PubkeyResolver maps validator pubkeys to indices via the consensus node,
+// caching every successful lookup (the mapping never changes).
+type PubkeyResolver struct {
+	client    *http.Client
+	consensus *ConsensusNodes
+
+	mu sync.RWMutex
+	m  map[string]uint64 // lowercase 0x-pubkey -> validator index
+
+	hits, misses atomic.Uint64
+}
+
+func NewPubkeyResolver(client *http.Client, consensus *ConsensusNodes) *PubkeyResolver {
+	return &PubkeyResolver{client: client, consensus: consensus, m: make(map[string]uint64)}
+}
+
+// Resolve returns the index for pubkey. Unknown pubkeys and failed lookups
+// report false and are not cached.
+func (r *PubkeyResolver) Resolve(ctx context.Context, pubkey string) (uint64, bool) {
+	key := strings.ToLower(pubkey)
+	if !strings.HasPrefix(key, "0x") {
+		key = "0x" + key
+	}
+	r.mu.RLock()
+	idx, ok := r.m[key]
+	r.mu.RUnlock()
+	if ok {
+		r.hits.Add(1)
+		return idx, true
+	}
+	r.misses.Add(1)
+
+	resp, err := r.consensus.Get(ctx, r.client, "/eth/v1/beacon/states/head/validators/"+key)
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, false
+	}
+	var payload struct {
+		Data struct {
+			Index string `json:"index"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return 0, false
+	}
+	idx, err = strconv.ParseUint(payload.Data.Index, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	r.mu.Lock()
+	r.m[key] = idx
+	r.mu.Unlock()
+	return idx, true
+}
+
+// Len returns the number of cached pubkey mappings.
+func (r *PubkeyResolver) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.m)
+}
+
+// Stats reports the resolver cache contents and hit rate. Mappings never
+// expire.
+func (r *PubkeyResolver) Stats() cacheStats {
+	return cacheStats{Name: "pubkey", Entries: r.Len(), Hits: r.hits.Load(), Misses: r.misses.Load()}
+}
+
+// parseAttesterSlashings returns, per entry of body.attester_slashings, the
+// validator indices present in both attestations' attesting_indices.
+func parseAttesterSlashings(body map[string]interface{}) []attesterSlashing {
+	entries, _ := body["attester_slashings"].([]interface{})
+	out := make([]attesterSlashing, 0, len(entries))
+	for _, e := range entries {
+		em, _ := e.(map[string]interface{})
+		first := attestingIndices(em["attestation_1"])
+		validators := []uint64{}
+		for idx := range attestingIndices(em["attestation_2"]) {
+			if _, ok := first[idx]; ok {
+				validators = append(validators, idx)
+			}
+		}
+		sort.Slice(validators, func(i, j int) bool { return validators[i] < validators[j] })
+		out = append(out, attesterSlashing{Validators: validators})
+	}
+	return out
+}
+
+// parseProposerSlashings returns the proposer and the two conflicting header
+// slots of each entry of body.proposer_slashings.
+func parseProposerSlashings(body map[string]interface{}) []proposerSlashing {
+	entries, _ := body["proposer_slashings"].([]interface{})
+	out := make([]proposerSlashing, 0, len(entries))
+	for _, e := range entries {
+		em, _ := e.(map[string]interface{})
+		h1 := signedMessage(em["signed_header_1"])
+		h2 := signedMessage(em["signed_header_2"])
+		var ps proposerSlashing
+		ps.ProposerIndex, _ = parseUint64FromInterface(h1["proposer_index"])
+		ps.Header1Slot, _ = parseUint64FromInterface(h1["slot"])
+		ps.Header2Slot, _ = parseUint64FromInterface(h2["slot"])
+		out = append(out, ps)
+	}
+	return out
+}
+
+// parseVoluntaryExits returns the validator and exit epoch of each entry of
+// body.voluntary_exits.
+func parseVoluntaryExits(body map[string]interface{}) []voluntaryExit {
+	entries, _ := body["voluntary_exits"].([]interface{})
+	out := make([]voluntaryExit, 0, len(entries))
+	for _, e := range entries {
+		msg := signedMessage(e)
+		var ve voluntaryExit
+		ve.ValidatorIndex, _ = parseUint64FromInterface(msg["validator_index"])
+		ve.Epoch, _ = parseUint64FromInterface(msg["epoch"])
+		out = append(out, ve)
+	}
+	return out
+}
+
+// parseDeposits returns the pubkey, amount and withdrawal credentials of each
+// entry of body.deposits.
+func parseDeposits(body map[string]interface{}) []deposit {
+	entries, _ := body["deposits"].([]interface{})
+	out := make([]deposit, 0, len(entries))
+	for _, e := range entries {
+		em, _ := e.(map[string]interface{})
+		dm, _ := em["data"].(map[string]interface{})
+		var d deposit
+		d.Pubkey, _ = dm["pubkey"].(string)
+		d.Amount, _ = parseUint64FromInterface(dm["amount"])
+		d.WithdrawalCredentials, _ = dm["withdrawal_credentials"].(string)
+		out = append(out, d)
+	}
+	return out
+}
+
+func signedMessage(h interface{}) map[string]interface{} {
+	hm, _ := h.(map[string]interface{})
+	msg, _ := hm["message"].(map[string]interface{})
+	return msg
+}
+
+func attestingIndices(att interface{}) map[uint64]struct{} {
+	am, _ := att.(map[string]interface{})
+	list, _ := am["attesting_indices"].([]interface{})
+	set := make(map[uint64]struct{}, len(list))
+	for _, v := range list {
+		if n, ok := parseUint64FromInterface(v); ok {
+			set[n] = struct{}{}
+		}
+	}
+	return set
 }
 
 func parseUint64FromInterface(v interface{}) (uint64, bool) {
@@ -188,6 +485,12 @@ func parseUint64FromInterface(v interface{}) (uint64, bool) {
 		return n, true
 	case float64:
 		return uint64(t), true
+	case json.Number:
+		n, err := strconv.ParseUint(t.String(), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return n, true
 	default:
 		return 0, false
 	}
@@ -210,7 +513,7 @@ func setUintIfZero(m map[string]interface{}, key string, value uint64) {
 		return
 	}
 	if cur, ok := m[key]; ok {
-		if f, ok := cur.(float64); ok && f != 0 {
+		if n, ok := parseUint64FromInterface(cur); ok && n != 0 {
 			return
 		}
 	}
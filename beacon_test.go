@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testPubkey = "0xa1b2c3"
+
+func TestPubkeyResolverCaches(t *testing.T) {
+	calls := 0
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if req.URL.Path != "/eth/v1/beacon/states/head/validators/"+testPubkey {
+			http.NotFound(w, req)
+			return
+		}
+		jsonHandler(http.StatusOK, `{"data":{"index":"42","status":"active_ongoing"}}`)(w, req)
+	})
+	r := NewPubkeyResolver(http.DefaultClient, NewConsensusNodes([]string{consensus.URL}))
+
+	for _, pk := range []string{testPubkey, "A1B2C3"} {
+		if idx, ok := r.Resolve(context.Background(), pk); !ok || idx != 42 {
+			t.Fatalf("Resolve(%s) = %d, %v; want 42", pk, idx, ok)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("%d lookups, want the mapping cached after one", calls)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := r.Resolve(context.Background(), "0xffff"); ok {
+			t.Fatal("unknown pubkey resolved")
+		}
+	}
+	if calls != 3 || r.Len() != 1 {
+		t.Fatalf("%d lookups, %d cached; want unknown pubkeys not cached", calls, r.Len())
+	}
+}
+
+// A validator object carrying only a pubkey gets its last attestation slot
+// through the resolver.
+func TestAttachLastAttestSlotByPubkey(t *testing.T) {
+	consensus := newTestServer(t, jsonHandler(http.StatusOK, `{"data":{"index":"42"}}`))
+	r := NewPubkeyResolver(http.DefaultClient, NewConsensusNodes([]string{consensus.URL}))
+	cache := NewLastAttestCache()
+	cache.SetIfGreater(42, 900)
+	resolve := func(pk string) (uint64, bool) { return r.Resolve(context.Background(), pk) }
+
+	v := map[string]interface{}{"pubkey": testPubkey, "balance": "32000000000"}
+	attachLastAttestSlot(v, cache, resolve)
+	if got := v["lastattestationslot"]; got != uint64(900) {
+		t.Fatalf("lastattestationslot = %#v, want 900", got)
+	}
+
+	v = map[string]interface{}{"pubkey": testPubkey}
+	attachLastAttestSlot(v, cache, nil)
+	if _, has := v["lastattestationslot"]; has {
+		t.Fatal("pubkey-only object annotated with resolution disabled")
+	}
+}
+
+// headConsensus serves head block 10 from the endpoints whose paths are in
+// up and answers 500 everywhere else.
+func headConsensus(t *testing.T, up ...string) string {
+	t.Helper()
+	return newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		for _, p := range up {
+			if req.URL.Path != p {
+				continue
+			}
+			switch p {
+			case "/eth/v1/beacon/headers/head":
+				jsonHandler(http.StatusOK, `{"data":{"root":"0xheader","header":{"message":{"slot":"10"}}}}`)(w, req)
+			case "/eth/v1/beacon/blocks/head/root":
+				jsonHandler(http.StatusOK, `{"data":{"root":"0xroot"}}`)(w, req)
+			default:
+				jsonHandler(http.StatusOK, blockJSON(10, ""))(w, req)
+			}
+			return
+		}
+		jsonHandler(http.StatusInternalServerError, `{"code":500,"message":"internal error"}`)(w, req)
+	}).URL
+}
+
+func TestResolveHeadRoot(t *testing.T) {
+	tests := []struct {
+		name    string
+		up      []string
+		want    string
+		wantErr bool
+	}{
+		{"headers", []string{"/eth/v1/beacon/headers/head", "/eth/v1/beacon/blocks/head/root"}, "0xheader", false},
+		{"block root", []string{"/eth/v1/beacon/blocks/head/root"}, "0xroot", false},
+		{"block slot", []string{"/eth/v2/beacon/blocks/head"}, "10", false},
+		{"all down", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consensus := NewConsensusNodes([]string{headConsensus(t, tt.up...)})
+			got, err := resolveHeadRoot(context.Background(), http.DefaultClient, consensus)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("resolveHeadRoot = %q, %v; want %q (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Each endpoint's response shapes, as served by different clients; an
+// answer without a usable root moves on to the next tier.
+func TestResolveHeadRootShapes(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses map[string]string
+		want      string
+	}{
+		{"header with root", map[string]string{
+			"/eth/v1/beacon/headers/head": `{"data":{"root":"0xaa","canonical":true,"header":{"message":{"slot":"10"}}}}`,
+		}, "0xaa"},
+		{"header with slot only", map[string]string{
+			"/eth/v1/beacon/headers/head": `{"data":{"header":{"message":{"slot":"10"}}}}`,
+		}, "10"},
+		{"empty header, block root", map[string]string{
+			"/eth/v1/beacon/headers/head":     `{"data":{}}`,
+			"/eth/v1/beacon/blocks/head/root": `{"execution_optimistic":false,"data":{"root":"0xbb"}}`,
+		}, "0xbb"},
+		{"empty block root, top-level block root", map[string]string{
+			"/eth/v1/beacon/blocks/head/root": `{"data":{"root":""}}`,
+			"/eth/v2/beacon/blocks/head":      `{"root":"0xcc","data":{"message":{"slot":"10"}}}`,
+		}, "0xcc"},
+		{"block with data root", map[string]string{
+			"/eth/v2/beacon/blocks/head": `{"version":"deneb","data":{"root":"0xdd","message":{"slot":"10"}}}`,
+		}, "0xdd"},
+		{"block with slot only", map[string]string{
+			"/eth/v2/beacon/blocks/head": blockJSON(10, ""),
+		}, "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+				body, ok := tt.responses[req.URL.Path]
+				if !ok {
+					http.NotFound(w, req)
+					return
+				}
+				jsonHandler(http.StatusOK, body)(w, req)
+			})
+			got, err := resolveHeadRoot(context.Background(), http.DefaultClient, NewConsensusNodes([]string{consensus.URL}))
+			if err != nil || got != tt.want {
+				t.Fatalf("resolveHeadRoot = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// With the headers endpoint failing, /slot/head is still served by the head
+// block's slot number; only with every head lookup down does it fail.
+func TestSlotHeadFallback(t *testing.T) {
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v1/slot/10" {
+			http.NotFound(w, req)
+			return
+		}
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":10,"epoch":0}}`)(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, headConsensus(t, "/eth/v2/beacon/blocks/head")))
+	rec := serve(h, http.MethodGet, "/api/v1/slot/head", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if data, _ := decodeJSON(t, rec)["data"].(map[string]interface{}); data["slot"] != float64(10) {
+		t.Fatalf("data = %v, want slot 10", data)
+	}
+
+	h = buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, headConsensus(t)))
+	if rec := serve(h, http.MethodGet, "/api/v1/slot/head", ""); rec.Code != http.StatusBadGateway {
+		t.Fatalf("all head lookups down: status = %d, want 502", rec.Code)
+	}
+}
+
+func TestHeadResolverCaches(t *testing.T) {
+	calls := 0
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		jsonHandler(http.StatusOK, `{"data":{"root":"0xhead"}}`)(w, req)
+	})
+	h := NewHeadResolver(http.DefaultClient, NewConsensusNodes([]string{consensus.URL}), 50*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if id, err := h.Resolve(context.Background()); err != nil || id != "0xhead" {
+			t.Fatalf("Resolve = %q, %v", id, err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("%d consensus calls for two rapid resolves, want 1", calls)
+	}
+	time.Sleep(60 * time.Millisecond)
+	h.Resolve(context.Background())
+	if calls != 2 {
+		t.Fatalf("%d consensus calls after the TTL, want 2", calls)
+	}
+}
+
+func TestHeadResolverSkipsFailures(t *testing.T) {
+	fail := true
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		jsonHandler(http.StatusOK, `{"data":{"root":"0xhead"}}`)(w, req)
+	})
+	h := NewHeadResolver(http.DefaultClient, NewConsensusNodes([]string{consensus.URL}), time.Minute)
+	if _, err := h.Resolve(context.Background()); err == nil {
+		t.Fatal("failed lookup resolved")
+	}
+	fail = false
+	if id, err := h.Resolve(context.Background()); err != nil || id != "0xhead" {
+		t.Fatalf("Resolve after recovery = %q, %v; want the failure not cached", id, err)
+	}
+}
+
+// Rapid /slot/head requests share one consensus lookup.
+func TestSlotHeadRequestsShareLookup(t *testing.T) {
+	lookups := 0
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/eth/v1/beacon/headers/head" {
+			http.NotFound(w, req)
+			return
+		}
+		lookups++
+		jsonHandler(http.StatusOK, `{"data":{"root":"0xhead"}}`)(w, req)
+	})
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":10,"epoch":0}}`))
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, consensus.URL))
+	for i := 0; i < 2; i++ {
+		if rec := serve(h, http.MethodGet, "/api/v1/slot/head", ""); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d", rec.Code)
+		}
+	}
+	if lookups != 1 {
+		t.Fatalf("%d head lookups, want 1", lookups)
+	}
+}
+
+// enrichFrom runs enrichSlotConsensus on slotData against a consensus node
+// serving block for every block ID.
+func enrichFrom(t *testing.T, block string, slotData map[string]interface{}) {
+	t.Helper()
+	consensus := newTestServer(t, jsonHandler(http.StatusOK, block))
+	if err := enrichSlotConsensus(context.Background(), http.DefaultClient, NewConsensusNodes([]string{consensus.URL}), nil, "5", slotData); err != nil {
+		t.Fatalf("enrichSlotConsensus: %v", err)
+	}
+}
+
+func TestEnrichProposerWhenDoraHasZero(t *testing.T) {
+	slotData := map[string]interface{}{"slot": json.Number("5"), "proposer": json.Number("0")}
+	enrichFrom(t, blockJSON(5, ""), slotData)
+	if slotData["proposer"] != uint64(7) {
+		t.Errorf("proposer = %v, want the consensus proposer 7", slotData["proposer"])
+	}
+
+	slotData = map[string]interface{}{"slot": json.Number("5"), "proposer": json.Number("9")}
+	enrichFrom(t, blockJSON(5, ""), slotData)
+	if slotData["proposer"] != json.Number("9") {
+		t.Errorf("proposer = %v, want Dora's 9 kept", slotData["proposer"])
+	}
+
+	// through the slot route
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0,"proposer":0}}`))
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, newTestServer(t, jsonHandler(http.StatusOK, blockJSON(5, ""))).URL))
+	data, _ := decodeJSON(t, serve(h, http.MethodGet, "/api/v1/slot/5", ""))["data"].(map[string]interface{})
+	if data["proposer"] != float64(7) {
+		t.Errorf("slot response proposer = %v, want 7", data["proposer"])
+	}
+}
+
+func TestEnrichWithdrawalTotal(t *testing.T) {
+	slotData := map[string]interface{}{}
+	enrichFrom(t, blockJSON(5, `"execution_payload":{"withdrawals":[`+
+		`{"index":"1","validator_index":"10","amount":"1000"},`+
+		`{"index":"2","validator_index":"11","amount":"2500"},`+
+		`{"index":"3","validator_index":"12","amount":"18000000000"}]}`), slotData)
+	if got := slotData["withdrawal_total_amount"]; got != uint64(18000003500) {
+		t.Errorf("withdrawal_total_amount = %v, want 18000003500", got)
+	}
+
+	slotData = map[string]interface{}{}
+	enrichFrom(t, blockJSON(5, `"execution_payload":{"withdrawals":[]}`), slotData)
+	if got := slotData["withdrawal_total_amount"]; got != uint64(0) {
+		t.Errorf("withdrawal_total_amount without withdrawals = %v, want 0", got)
+	}
+}
+
+func TestEnrichAttesterSlashings(t *testing.T) {
+	slotData := map[string]interface{}{}
+	enrichFrom(t, blockJSON(5, `"attestations":[],"attester_slashings":[{`+
+		`"attestation_1":{"attesting_indices":["3","8","21","40"]},`+
+		`"attestation_2":{"attesting_indices":["40","2","8"]}}]`), slotData)
+	want := []attesterSlashing{{Validators: []uint64{8, 40}}}
+	if got := slotData["attester_slashings"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("attester_slashings = %v, want %v", got, want)
+	}
+
+	slotData = map[string]interface{}{}
+	enrichFrom(t, blockJSON(5, ""), slotData)
+	if got, _ := slotData["attester_slashings"].([]attesterSlashing); got == nil || len(got) != 0 {
+		t.Errorf("attester_slashings without slashings = %#v, want an empty list", slotData["attester_slashings"])
+	}
+}
+
+func TestEnrichProposerSlashings(t *testing.T) {
+	slotData := map[string]interface{}{}
+	enrichFrom(t, blockJSON(5, `"attestations":[],"proposer_slashings":[{`+
+		`"signed_header_1":{"message":{"slot":"4","proposer_index":"17"},"signature":"0x01"},`+
+		`"signed_header_2":{"message":{"slot":"4","proposer_index":"17"},"signature":"0x02"}}]`), slotData)
+	want := []proposerSlashing{{ProposerIndex: 17, Header1Slot: 4, Header2Slot: 4}}
+	if got := slotData["proposer_slashings"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("proposer_slashings = %v, want %v", got, want)
+	}
+}
+
+func TestEnrichVoluntaryExits(t *testing.T) {
+	slotData := map[string]interface{}{}
+	enrichFrom(t, blockJSON(5, `"attestations":[],"voluntary_exits":[`+
+		`{"message":{"epoch":"200","validator_index":"31"},"signature":"0x01"},`+
+		`{"message":{"epoch":"201","validator_index":"64"},"signature":"0x02"}]`), slotData)
+	want := []voluntaryExit{{ValidatorIndex: 31, Epoch: 200}, {ValidatorIndex: 64, Epoch: 201}}
+	if got := slotData["voluntary_exits"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("voluntary_exits = %v, want %v", got, want)
+	}
+}
+
+func TestEnrichDeposits(t *testing.T) {
+	slotData := map[string]interface{}{}
+	enrichFrom(t, blockJSON(5, `"attestations":[],"deposits":[{"proof":["0x00"],"data":{`+
+		`"pubkey":"0xb0a1","withdrawal_credentials":"0x0100aa","amount":"32000000000","signature":"0x01"}}]`), slotData)
+	want := []deposit{{Pubkey: "0xb0a1", Amount: 32000000000, WithdrawalCredentials: "0x0100aa"}}
+	if got := slotData["deposits"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("deposits = %v, want %v", got, want)
+	}
+
+	// no deposits give an empty list, not null
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0}}`))
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, newTestServer(t, jsonHandler(http.StatusOK, blockJSON(5, ""))).URL))
+	data, _ := decodeJSON(t, serve(h, http.MethodGet, "/api/v1/slot/5", ""))["data"].(map[string]interface{})
+	if got, ok := data["deposits"].([]interface{}); !ok || len(got) != 0 {
+		t.Errorf("deposits = %#v, want []", data["deposits"])
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BlockCache briefly keeps decoded beacon blocks (the "data" object of
+// /eth/v2/beacon/blocks/{id}), so the scanner and the slot routes fetching
+// the same block around the same time share one request. Entries are keyed
+// by the requested block ID, when it names a fixed block, and by the block's
+// slot; the oldest entry is evicted once maxEntries is exceeded. Cached
+// blocks are shared and must not be modified.
+//
+// A nil *BlockCache caches nothing.
+type BlockCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*blockCacheEntry
+
+	hits, misses atomic.Uint64
+}
+
+type blockCacheEntry struct {
+	data   map[string]interface{}
+	stored time.Time
+}
+
+func NewBlockCache(ttl time.Duration, maxEntries int) *BlockCache {
+	return &BlockCache{ttl: ttl, maxEntries: maxEntries, entries: make(map[string]*blockCacheEntry)}
+}
+
+// Get returns the block cached under blockID. Only slots and roots are
+// cached; named IDs such as "head" or "finalized" move and always miss.
+func (c *BlockCache) Get(blockID string) (map[string]interface{}, bool) {
+	if c == nil || !fixedBlockID(blockID) {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[blockID]
+	if ok && time.Since(e.stored) > c.ttl {
+		delete(c.entries, blockID)
+		ok = false
+	}
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return e.data, true
+}
+
+// Set stores data, the block fetched as blockID, under blockID and its slot.
+func (c *BlockCache) Set(blockID string, data map[string]interface{}) {
+	if c == nil {
+		return
+	}
+	e := &blockCacheEntry{data: data, stored: time.Now()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if fixedBlockID(blockID) {
+		c.entries[blockID] = e
+	}
+	message, _ := data["message"].(map[string]interface{})
+	if slot, ok := parseUint64FromInterface(message["slot"]); ok {
+		c.entries[strconv.FormatUint(slot, 10)] = e
+	}
+	for len(c.entries) > c.maxEntries {
+		c.evictOldest()
+	}
+}
+
+// fixedBlockID reports whether blockID always names the same block: a slot
+// number or a 0x block root.
+func fixedBlockID(blockID string) bool {
+	if strings.HasPrefix(blockID, "0x") {
+		return len(blockID) > 2
+	}
+	_, err := strconv.ParseUint(blockID, 10, 64)
+	return err == nil
+}
+
+func (c *BlockCache) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for k, e := range c.entries {
+		if oldestKey == "" || e.stored.Before(oldest) {
+			oldestKey, oldest = k, e.stored
+		}
+	}
+	delete(c.entries, oldestKey)
+}
+
+// Stats reports the cache's configuration, contents and hit rate.
+func (c *BlockCache) Stats() cacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := cacheStats{
+		Name:       "block",
+		TTLSeconds: c.ttl.Seconds(),
+		Entries:    len(c.entries),
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+	}
+	var oldest, newest time.Time
+	for _, e := range c.entries {
+		if oldest.IsZero() || e.stored.Before(oldest) {
+			oldest = e.stored
+		}
+		if e.stored.After(newest) {
+			newest = e.stored
+		}
+	}
+	st.setAges(time.Now(), oldest, newest)
+	return st
+}
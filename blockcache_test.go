@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testBlock(slot string) map[string]interface{} {
+	return map[string]interface{}{"message": map[string]interface{}{"slot": slot}}
+}
+
+func TestBlockCacheKeys(t *testing.T) {
+	c := NewBlockCache(time.Minute, 8)
+	c.Set("0xroot", testBlock("5"))
+	for _, id := range []string{"0xroot", "5"} {
+		if _, ok := c.Get(id); !ok {
+			t.Errorf("block not cached under %s", id)
+		}
+	}
+	for i, id := range []string{"head", "finalized", "justified", "genesis"} {
+		slot := strconv.Itoa(6 + i)
+		c.Set(id, testBlock(slot))
+		if _, ok := c.Get(id); ok {
+			t.Errorf("%s cached", id)
+		}
+		if _, ok := c.Get(slot); !ok {
+			t.Errorf("%s block not cached under its slot", id)
+		}
+	}
+}
+
+// Head resolves to the new block after head moves, while the cache is warm.
+func TestBlockSlotHeadNotCached(t *testing.T) {
+	blocks := &blockCounter{head: 10, fetches: make(map[string]int)}
+	tr := newTestTracker(t, newTestConfig(t), newTestServer(t, blocks.ServeHTTP).URL)
+	for _, want := range []uint64{10, 11} {
+		blocks.mu.Lock()
+		blocks.head = want
+		blocks.mu.Unlock()
+		slot, ok, err := tr.BlockSlot(context.Background(), tr.client, "head")
+		if err != nil || !ok || slot != want {
+			t.Fatalf("BlockSlot(head) = %d, %v, %v; want %d", slot, ok, err, want)
+		}
+	}
+}
+
+func TestBlockCacheExpiryAndEviction(t *testing.T) {
+	c := NewBlockCache(50*time.Millisecond, 2)
+	c.Set("1", testBlock("1"))
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get("1"); ok {
+		t.Error("expired block returned")
+	}
+
+	c = NewBlockCache(time.Minute, 2)
+	for _, slot := range []string{"1", "2", "3"} {
+		c.Set(slot, testBlock(slot))
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := c.Get("1"); ok {
+		t.Error("oldest block not evicted")
+	}
+	if _, ok := c.Get("3"); !ok {
+		t.Error("newest block evicted")
+	}
+}
+
+// A block the scanner fetched is reused by the slot route.
+func TestBlockCacheSharedWithSlotRoute(t *testing.T) {
+	blocks := &blockCounter{head: 10, fetches: make(map[string]int)}
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0}}`))
+	d := newTestDeps(t, newTestConfig(t), dora.URL, newTestServer(t, blocks.ServeHTTP).URL)
+	d.tracker.processSlot(context.Background(), 5)
+
+	data, _ := decodeJSON(t, serve(buildRouter(d), http.MethodGet, "/api/v1/slot/5", ""))["data"].(map[string]interface{})
+	if data["enriched"] != true {
+		t.Fatalf("slot not enriched: %v", data)
+	}
+	if n := blocks.count("5"); n != 1 {
+		t.Fatalf("block 5 fetched %d times, want once", n)
+	}
+	if st := d.tracker.Blocks().Stats(); st.Hits == 0 {
+		t.Errorf("stats = %+v, want a hit", st)
+	}
+}
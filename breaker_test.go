@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Hour)
+	b.Failure()
+	if !b.Allow() {
+		t.Fatal("breaker open below the threshold")
+	}
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("breaker closed after threshold failures")
+	}
+}
+
+func TestCircuitBreakerSuccessResets(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Hour)
+	b.Failure()
+	b.Success()
+	b.Failure()
+	if !b.Allow() {
+		t.Fatal("failures counted across a success")
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Millisecond)
+	b.Failure()
+	time.Sleep(2 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("no probe let through after the cooldown")
+	}
+	if b.Allow() {
+		t.Fatal("second call let through while probing")
+	}
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("breaker closed after a failed probe")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	b.Allow()
+	b.Success()
+	if !b.Allow() || !b.Allow() {
+		t.Fatal("breaker still open after a successful probe")
+	}
+}
+
+// An abandoned probe frees the slot for the next call instead of leaving
+// the breaker half-open with nobody probing.
+func TestCircuitBreakerAbandonReleasesProbe(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Millisecond)
+	b.Failure()
+	time.Sleep(2 * time.Millisecond)
+	b.Allow()
+	b.Abandon()
+	if !b.Allow() {
+		t.Fatal("probe not released by Abandon")
+	}
+}
+
+// Once the breaker opens, requests fail fast with 503 without reaching
+// upstream.
+func TestBreakerFastFails(t *testing.T) {
+	calls := 0
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		jsonHandler(http.StatusInternalServerError, `{"status":"ERROR: internal"}`)(w, req)
+	})
+	cfg := newTestConfig(t)
+	cfg.UpstreamMaxAttempts = 1
+	cfg.BreakerFailures = 2
+	cfg.BreakerCooldown = time.Hour
+	h := buildRouter(newTestDeps(t, cfg, dora.URL, ""))
+
+	for i := 0; i < 2; i++ {
+		serve(h, http.MethodGet, "/api/v1/epoch/latest", "")
+	}
+	rec := serve(h, http.MethodGet, "/api/v1/epoch/latest", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	if m := decodeJSON(t, rec); m["message"] != "upstream temporarily unavailable" {
+		t.Errorf("message = %v", m["message"])
+	}
+	if calls != 2 {
+		t.Fatalf("upstream called %d times, want 2", calls)
+	}
+}
+
+// Calls cancelled by the client don't count as upstream failures.
+func TestBreakerIgnoresCancelledCalls(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{}}`))
+	cfg := newTestConfig(t)
+	cfg.UpstreamMaxAttempts = 1
+	cfg.BreakerFailures = 1
+	cfg.BreakerCooldown = time.Hour
+	d := newTestDeps(t, cfg, dora.URL, "")
+	p := NewUpstreamProxy(d.client, d.upstream, cfg, d.log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.do(ctx, http.MethodGet, "/epoch/latest", "", nil, nil); err == nil {
+		t.Fatal("cancelled call succeeded")
+	}
+	resp, err := p.do(context.Background(), http.MethodGet, "/epoch/latest", "", nil, nil)
+	if err != nil {
+		t.Fatalf("call after a cancelled one: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// Bounds on an execution block lookup, so one request can't walk the
+// consensus node's history: the search covers at most execBlockWindow
+// missed slots between the block and head, and gives up after
+// maxExecBlockProbes block fetches (a checkpoint-synced node answers 404 for
+// old slots, which look like missed ones).
+const (
+	execBlockWindow    = 8192
+	maxExecBlockProbes = 64
+)
+
+// errExecBlockProbes reports a lookup that hit maxExecBlockProbes.
+var errExecBlockProbes = errors.New("execution block lookup exceeded its probe limit")
+
+// SlotForExecBlock finds the slot whose block carries execution block number.
+// Execution block numbers grow by one per block, skipping missed slots, so
+// the block is at least head's number minus number slots before head; a
+// binary search over the execBlockWindow slots before that finds it.
+// Pre-merge blocks count as block 0. Blocks are fetched over client. It
+// reports false when no block in the window has that number, and fails with
+// errExecBlockProbes when the search needs too many fetches.
+func (t *AttestationTracker) SlotForExecBlock(ctx context.Context, client *http.Client, number uint64) (uint64, bool, error) {
+	head, err := t.fetchBlockMessageWith(ctx, client, "head", 1)
+	if err != nil || head == nil {
+		return 0, false, err
+	}
+	headSlot, _ := parseUint64FromInterface(head["slot"])
+	headNumber := execBlockNumber(head)
+	if headNumber < number {
+		return 0, false, nil
+	}
+	if headNumber-number > headSlot {
+		return 0, false, nil
+	}
+
+	// Smallest slot whose first block at or after it has a number >= number
+	s := &execBlockSearch{t: t, client: client, headSlot: headSlot}
+	hi := headSlot - (headNumber - number)
+	lo := uint64(0)
+	if hi > execBlockWindow {
+		lo = hi - execBlockWindow
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		n, _, err := s.atOrAfter(ctx, mid)
+		if err != nil {
+			return 0, false, err
+		}
+		if n >= number {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	n, slot, err := s.atOrAfter(ctx, lo)
+	if err != nil || n != number {
+		return 0, false, err
+	}
+	return slot, true, nil
+}
+
+// execBlockSearch counts the block fetches of one SlotForExecBlock lookup.
+type execBlockSearch struct {
+	t        *AttestationTracker
+	client   *http.Client
+	headSlot uint64
+	probes   int
+}
+
+// atOrAfter returns the execution block number and slot of the first block
+// at a slot from slot to head, skipping missed slots.
+func (s *execBlockSearch) atOrAfter(ctx context.Context, slot uint64) (uint64, uint64, error) {
+	for cur := slot; ; cur++ {
+		if s.probes >= maxExecBlockProbes {
+			return 0, 0, errExecBlockProbes
+		}
+		s.probes++
+		message, err := s.t.fetchBlockMessageWith(ctx, s.client, strconv.FormatUint(cur, 10), s.t.blockAttempts)
+		if err != nil {
+			return 0, 0, err
+		}
+		if message != nil || cur >= s.headSlot {
+			return execBlockNumber(message), cur, nil
+		}
+	}
+}
+
+// execBlockNumber returns body.execution_payload.block_number, 0 when the
+// block has no execution payload.
+func execBlockNumber(message map[string]interface{}) uint64 {
+	body, _ := message["body"].(map[string]interface{})
+	exec, _ := body["execution_payload"].(map[string]interface{})
+	n, _ := parseUint64FromInterface(exec["block_number"])
+	return n
+}
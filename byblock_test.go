@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// chainConsensus serves the blocks of a chain up to head, without blocks at
+// the missed slots. Each block's execution block number is 1000 plus the
+// number of blocks before it.
+func chainConsensus(t *testing.T, head uint64, missed func(slot uint64) bool) (string, func(slot uint64) uint64) {
+	t.Helper()
+	numbers := make(map[uint64]uint64)
+	next := uint64(1000)
+	for s := uint64(0); s <= head; s++ {
+		if !missed(s) {
+			numbers[s] = next
+			next++
+		}
+	}
+	url := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		id, ok := strings.CutPrefix(req.URL.Path, "/eth/v2/beacon/blocks/")
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		slot := head
+		if id != "head" {
+			slot, _ = strconv.ParseUint(id, 10, 64)
+		}
+		n, ok := numbers[slot]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		jsonHandler(http.StatusOK, blockJSON(slot, fmt.Sprintf(`"attestations":[],"execution_payload":{"block_number":"%d"}`, n)))(w, req)
+	}).URL
+	return url, func(slot uint64) uint64 { return numbers[slot] }
+}
+
+func TestSlotForExecBlock(t *testing.T) {
+	consensus, number := chainConsensus(t, 100, func(s uint64) bool { return s >= 50 && s <= 55 })
+	tr := newTestTracker(t, newTestConfig(t), consensus)
+	for _, want := range []uint64{0, 49, 56, 60, 100} {
+		slot, found, err := tr.SlotForExecBlock(context.Background(), tr.client, number(want))
+		if err != nil || !found || slot != want {
+			t.Errorf("block %d: slot %d, found %v, err %v; want slot %d", number(want), slot, found, err, want)
+		}
+	}
+	if _, found, err := tr.SlotForExecBlock(context.Background(), tr.client, number(100)+1); found || err != nil {
+		t.Errorf("block after head: found %v, err %v; want not found", found, err)
+	}
+}
+
+func TestSlotByBlockRoute(t *testing.T) {
+	consensus, number := chainConsensus(t, 100, func(s uint64) bool { return s%10 == 3 })
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasSuffix(req.URL.Path, "/slot/60") {
+			http.NotFound(w, req)
+			return
+		}
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":60,"epoch":1}}`)(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, consensus))
+
+	rec := serve(h, http.MethodGet, fmt.Sprintf("/api/v1/slot/byblock/%d", number(60)), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if data, _ := decodeJSON(t, rec)["data"].(map[string]interface{}); data["slot"] != float64(60) {
+		t.Fatalf("data = %v, want slot 60", data)
+	}
+	if rec := serve(h, http.MethodGet, "/api/v1/slot/byblock/abc", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("non-numeric block: status = %d, want 400", rec.Code)
+	}
+	if rec := serve(h, http.MethodGet, "/api/v1/slot/byblock/999999", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("block after head: status = %d, want 404", rec.Code)
+	}
+}
+
+// A long run of missed slots, as a checkpoint-synced node reports for old
+// slots, makes the search give up rather than walk it.
+func TestSlotForExecBlockProbeLimit(t *testing.T) {
+	consensus, number := chainConsensus(t, 100, func(s uint64) bool { return s >= 10 && s <= 90 })
+	d := newTestDeps(t, newTestConfig(t), "http://127.0.0.1:1", consensus)
+	if _, _, err := d.tracker.SlotForExecBlock(context.Background(), d.client, number(5)); !errors.Is(err, errExecBlockProbes) {
+		t.Fatalf("err = %v, want errExecBlockProbes", err)
+	}
+	rec := serve(buildRouter(d), http.MethodGet, fmt.Sprintf("/api/v1/slot/byblock/%d", number(5)), "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "time"
+
+// cacheStats describes one in-memory cache for /api/v1/internal/caches.
+// TTL is zero for caches whose entries never expire; entry ages are only
+// reported by caches that record when entries were stored.
+type cacheStats struct {
+	Name             string  `json:"name"`
+	TTLSeconds       float64 `json:"ttl_seconds"`
+	Entries          int     `json:"entries"`
+	Bytes            int64   `json:"bytes,omitempty"`
+	OldestAgeSeconds float64 `json:"oldest_age_seconds,omitempty"`
+	NewestAgeSeconds float64 `json:"newest_age_seconds,omitempty"`
+	Hits             uint64  `json:"hits"`
+	Misses           uint64  `json:"misses"`
+}
+
+// setAges fills the entry age fields from the oldest and newest store times.
+func (s *cacheStats) setAges(now, oldest, newest time.Time) {
+	if oldest.IsZero() {
+		return
+	}
+	s.OldestAgeSeconds = now.Sub(oldest).Seconds()
+	s.NewestAgeSeconds = now.Sub(newest).Seconds()
+}
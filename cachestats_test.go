@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestInternalCachesReportsSeededState(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"epoch":3}}`))
+	cfg := newTestConfig(t)
+	cfg.ResponseCacheTTL = 30 * time.Second
+	cfg.HeadRootTTL = 4 * time.Second
+	d := newTestDeps(t, cfg, dora.URL, "")
+	d.cache.SetIfGreater(1, 100)
+	d.cache.SetIfGreater(2, 200)
+	d.cache.GetOK(1)
+	d.cache.GetOK(9)
+	h := buildRouter(d)
+	serve(h, http.MethodGet, "/api/v1/epoch/latest", "") // response cache miss
+	serve(h, http.MethodGet, "/api/v1/epoch/latest", "") // and hit
+
+	rec := serve(h, http.MethodGet, "/api/v1/internal/caches", "")
+	var stats []cacheStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	byName := make(map[string]cacheStats)
+	for _, s := range stats {
+		byName[s.Name] = s
+	}
+
+	if s := byName["last_attestation"]; s.Entries != 2 || s.Hits != 1 || s.Misses != 1 || s.TTLSeconds != 0 {
+		t.Errorf("last_attestation = %+v, want 2 entries, 1 hit, 1 miss, no TTL", s)
+	}
+	s := byName["response"]
+	if s.TTLSeconds != 30 || s.Entries != 1 || s.Bytes == 0 || s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("response = %+v, want TTL 30s, 1 entry, 1 hit, 1 miss", s)
+	}
+	if s.OldestAgeSeconds < 0 || s.OldestAgeSeconds > 5 || s.NewestAgeSeconds > s.OldestAgeSeconds {
+		t.Errorf("response entry ages = %v oldest, %v newest", s.OldestAgeSeconds, s.NewestAgeSeconds)
+	}
+	if s, ok := byName["head"]; !ok || s.TTLSeconds != 4 {
+		t.Errorf("head = %+v, want TTL 4s", s)
+	}
+}
+
+func TestCacheStatsAges(t *testing.T) {
+	now := time.Now()
+	var s cacheStats
+	s.setAges(now, time.Time{}, time.Time{})
+	if s.OldestAgeSeconds != 0 || s.NewestAgeSeconds != 0 {
+		t.Fatalf("empty cache reports ages %+v", s)
+	}
+	s.setAges(now, now.Add(-10*time.Second), now.Add(-2*time.Second))
+	if s.OldestAgeSeconds != 10 || s.NewestAgeSeconds != 2 {
+		t.Fatalf("ages = %v, %v; want 10, 2", s.OldestAgeSeconds, s.NewestAgeSeconds)
+	}
+}
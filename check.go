@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// checkProbe is one reachability check run by -check-config.
+type checkProbe struct {
+	name string
+	url  string
+}
+
+// checkConfig runs the preflight behind -check-config: given the loaded
+// config (or the error loading it), it probes Dora and the consensus node
+// and writes a report to out. It returns whether every check passed.
+func checkConfig(cfg *proxyConfig, loadErr error, out io.Writer) bool {
+	if loadErr != nil {
+		fmt.Fprintf(out, "config: FAIL %v\n", loadErr)
+		return false
+	}
+	fmt.Fprintln(out, "config: ok")
+
+	client := &http.Client{Timeout: 10 * time.Second, Transport: newTransport(cfg)}
+	probes := []checkProbe{
+		{"upstream", strings.TrimRight(cfg.UpstreamBaseURL, "/") + cfg.PathEpochLatest},
+	}
+	for _, u := range cfg.ConsensusAPIURLs {
+		probes = append(probes, checkProbe{"consensus", strings.TrimRight(u, "/") + "/eth/v1/node/version"})
+	}
+	ok := true
+	for _, p := range probes {
+		if err := probeURL(client, p.url); err != nil {
+			fmt.Fprintf(out, "%s: FAIL %s: %v\n", p.name, p.url, err)
+			ok = false
+			continue
+		}
+		fmt.Fprintf(out, "%s: ok %s\n", p.name, p.url)
+	}
+	return ok
+}
+
+// probeURL GETs url and expects a 200.
+func probeURL(client *http.Client, url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
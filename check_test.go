@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfigGood(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"epoch":3}}`))
+	consensus := newTestServer(t, jsonHandler(http.StatusOK, `{"data":{"version":"test"}}`))
+	cfg := newTestConfig(t)
+	cfg.UpstreamBaseURL = applyAPIPrefix(dora.URL, cfg.UpstreamAPIPrefix)
+	cfg.ConsensusAPIURLs = []string{consensus.URL}
+
+	var out strings.Builder
+	if !checkConfig(cfg, nil, &out) {
+		t.Fatalf("check failed:\n%s", out.String())
+	}
+	for _, want := range []string{"config: ok", "upstream: ok", "consensus: ok"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCheckConfigBad(t *testing.T) {
+	var out strings.Builder
+	if checkConfig(nil, errors.New("PROXY_UPSTREAM_URL is not an absolute URL"), &out) {
+		t.Fatal("check passed with a config error")
+	}
+	if !strings.Contains(out.String(), "config: FAIL") {
+		t.Errorf("report = %q", out.String())
+	}
+
+	// a loadable config whose consensus node is down
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"epoch":3}}`))
+	cfg := newTestConfig(t)
+	cfg.UpstreamBaseURL = applyAPIPrefix(dora.URL, cfg.UpstreamAPIPrefix)
+	cfg.ConsensusAPIURLs = []string{newTestServer(t, http.NotFound).URL}
+	out.Reset()
+	if checkConfig(cfg, nil, &out) {
+		t.Fatalf("check passed with the consensus node answering 404:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "upstream: ok") || !strings.Contains(out.String(), "consensus: FAIL") {
+		t.Errorf("report = %q", out.String())
+	}
+}
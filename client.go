@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newProxyClient builds the client for user-facing calls to Dora and the
+// consensus node: a short timeout and a large connection pool.
+func newProxyClient(cfg *proxyConfig, log *logrus.Logger) *http.Client {
+	return &http.Client{
+		Timeout:       cfg.UpstreamTimeout,
+		Transport:     newTransport(cfg),
+		CheckRedirect: redirectPolicy(cfg.MaxRedirects, cfg.CrossHostRedirects, log),
+	}
+}
+
+// newScannerClient builds the client for background work (attestation
+// scanning, spec loading, alert webhooks). It has its own pool capped at
+// ScannerMaxConns per host, so a scan backlog cannot take connections from
+// user requests, and a longer timeout since nobody is waiting on it.
+func newScannerClient(cfg *proxyConfig, log *logrus.Logger) *http.Client {
+	t := newTransport(cfg)
+	t.MaxConnsPerHost = cfg.ScannerMaxConns
+	t.MaxIdleConnsPerHost = cfg.ScannerMaxConns
+	return &http.Client{
+		Timeout:       cfg.ScannerTimeout,
+		Transport:     t,
+		CheckRedirect: redirectPolicy(cfg.MaxRedirects, cfg.CrossHostRedirects, log),
+	}
+}
+
+// newTransport returns a pooled transport. The per-host idle limit defaults
+// to 32 so concurrent proxy traffic to the same host reuses connections
+// instead of redialing (net/http keeps 2).
+//
+// With compression on, the transport sends Accept-Encoding: gzip and hands
+// back decompressed bodies without Content-Encoding or Content-Length. That
+// only happens while the request has no Accept-Encoding of its own, which is
+// why shouldSkipHeader drops the client's.
+func newTransport(cfg *proxyConfig) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = cfg.MaxIdleConns
+	t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	t.IdleConnTimeout = cfg.IdleConnTimeout
+	t.DisableCompression = !cfg.UpstreamCompression
+	return t
+}
+
+// redirectPolicy follows at most max redirects, refusing ones that leave the
+// original host unless crossHost is set. net/http copies the original
+// request headers onto each redirect, dropping Authorization and cookies when
+// the host changes.
+func redirectPolicy(max int, crossHost bool, log *logrus.Logger) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return fmt.Errorf("stopped after %d redirects", max)
+		}
+		orig := via[0]
+		if !crossHost && req.URL.Host != orig.URL.Host {
+			return fmt.Errorf("refusing redirect from %s to %s", orig.URL.Host, req.URL.Host)
+		}
+		log.WithFields(logrus.Fields{"from": via[len(via)-1].URL.String(), "to": req.URL.String()}).Info("following upstream redirect")
+		return nil
+	}
+}
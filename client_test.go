@@ -0,0 +1,186 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// redirectTarget starts the server redirects point to, recording the Accept
+// header of each request reaching it.
+func redirectTarget(t *testing.T, accepts *[]string) string {
+	t.Helper()
+	return newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		*accepts = append(*accepts, req.Header.Get("Accept"))
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"epoch":3}}`)(w, req)
+	}).URL
+}
+
+func TestRedirectSameHost(t *testing.T) {
+	var accepts []string
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/v1/epoch/latest" {
+			http.Redirect(w, req, "/api/v1/epoch/moved", http.StatusFound)
+			return
+		}
+		accepts = append(accepts, req.Header.Get("Accept"))
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"epoch":3}}`)(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, ""))
+	rec := serve(h, http.MethodGet, "/api/v1/epoch/latest", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the redirect followed", rec.Code)
+	}
+	if len(accepts) != 1 || accepts[0] != "application/json" {
+		t.Fatalf("Accept after redirect = %q, want application/json kept", accepts)
+	}
+}
+
+func TestRedirectCrossHost(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		var accepts []string
+		target := redirectTarget(t, &accepts)
+		dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+			http.Redirect(w, req, target+req.URL.Path, http.StatusFound)
+		})
+		cfg := newTestConfig(t)
+		cfg.CrossHostRedirects = allow
+		cfg.UpstreamMaxAttempts = 1
+		h := buildRouter(newTestDeps(t, cfg, dora.URL, ""))
+		rec := serve(h, http.MethodGet, "/api/v1/epoch/latest", "")
+
+		want, wantCalls := http.StatusBadGateway, 0
+		if allow {
+			want, wantCalls = http.StatusOK, 1
+		}
+		if rec.Code != want || len(accepts) != wantCalls {
+			t.Errorf("cross-host allowed=%v: status = %d with %d calls to the other host, want %d and %d", allow, rec.Code, len(accepts), want, wantCalls)
+		}
+	}
+}
+
+func TestRedirectLimit(t *testing.T) {
+	hops := 0
+	srv := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		hops++
+		http.Redirect(w, req, "/again", http.StatusFound)
+	})
+	cfg := newTestConfig(t)
+	cfg.MaxRedirects = 2
+	client := newProxyClient(cfg, newTestLogger())
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("endless redirects followed")
+	}
+	if hops != 3 {
+		t.Fatalf("%d requests, want the original and 2 redirects", hops)
+	}
+}
+
+func TestTransportPoolSettings(t *testing.T) {
+	t.Setenv("PROXY_MAX_IDLE_CONNS", "50")
+	t.Setenv("PROXY_MAX_IDLE_CONNS_PER_HOST", "20")
+	t.Setenv("PROXY_IDLE_CONN_TIMEOUT", "30s")
+	t.Setenv("PROXY_SCANNER_MAX_CONNS", "4")
+	cfg := newTestConfig(t)
+
+	proxy := newProxyClient(cfg, newTestLogger()).Transport.(*http.Transport)
+	if proxy.MaxIdleConns != 50 || proxy.MaxIdleConnsPerHost != 20 || proxy.IdleConnTimeout != 30*time.Second {
+		t.Errorf("proxy transport = %d idle, %d per host, %v timeout; want 50, 20, 30s", proxy.MaxIdleConns, proxy.MaxIdleConnsPerHost, proxy.IdleConnTimeout)
+	}
+	if proxy.MaxConnsPerHost != 0 {
+		t.Errorf("proxy transport MaxConnsPerHost = %d, want no cap", proxy.MaxConnsPerHost)
+	}
+	scanner := newScannerClient(cfg, newTestLogger()).Transport.(*http.Transport)
+	if scanner == proxy {
+		t.Fatal("scanner shares the proxy transport")
+	}
+	if scanner.MaxConnsPerHost != 4 || scanner.MaxIdleConnsPerHost != 4 {
+		t.Errorf("scanner transport = %d conns, %d idle per host; want 4, 4", scanner.MaxConnsPerHost, scanner.MaxIdleConnsPerHost)
+	}
+}
+
+func TestClientTimeouts(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.UpstreamTimeout = 5 * time.Second
+	cfg.ScannerTimeout = time.Minute
+	if c := newProxyClient(cfg, newTestLogger()); c.Timeout != 5*time.Second {
+		t.Errorf("proxy client timeout = %v, want 5s", c.Timeout)
+	}
+	if c := newScannerClient(cfg, newTestLogger()); c.Timeout != time.Minute {
+		t.Errorf("scanner client timeout = %v, want 1m", c.Timeout)
+	}
+}
+
+// gzipHandler answers with body gzip-encoded when the request accepts gzip,
+// recording the Accept-Encoding it got.
+func gzipHandler(body string, acceptEncoding *string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		*acceptEncoding = req.Header.Get("Accept-Encoding")
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(*acceptEncoding, "gzip") {
+			w.Write([]byte(body))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(body))
+		zw.Close()
+	}
+}
+
+func TestGzipUpstream(t *testing.T) {
+	const epochBody = `{"status":"OK","data":{"epoch":3}}`
+	var acceptEncoding string
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.URL.Path, "slot") {
+			gzipHandler(`{"status":"OK","data":{"slot":5,"epoch":0}}`, &acceptEncoding)(w, req)
+			return
+		}
+		gzipHandler(epochBody, &acceptEncoding)(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, ""))
+
+	// the client's own Accept-Encoding is not passed on
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if acceptEncoding != "gzip" {
+		t.Errorf("upstream Accept-Encoding = %q, want gzip", acceptEncoding)
+	}
+	if rec.Body.String() != epochBody {
+		t.Errorf("passthrough body = %q, want it decompressed", rec.Body.String())
+	}
+	if ce := rec.Header().Get("Content-Encoding"); ce != "" {
+		t.Errorf("Content-Encoding = %q on a decompressed body", ce)
+	}
+	if cl := rec.Header().Get("Content-Length"); cl != "" && cl != strconv.Itoa(len(epochBody)) {
+		t.Errorf("Content-Length = %s, want %d or none", cl, len(epochBody))
+	}
+
+	rec = serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	data, _ := decodeJSON(t, rec)["data"].(map[string]interface{})
+	if data["slot"] != float64(5) {
+		t.Errorf("transformed body = %s", rec.Body.String())
+	}
+}
+
+func TestUpstreamCompressionDisabled(t *testing.T) {
+	var acceptEncoding string
+	dora := newTestServer(t, gzipHandler(`{"status":"OK","data":{"epoch":3}}`, &acceptEncoding))
+	cfg := newTestConfig(t)
+	cfg.UpstreamCompression = false
+	rec := serve(buildRouter(newTestDeps(t, cfg, dora.URL, "")), http.MethodGet, "/api/v1/epoch/latest", "")
+	if acceptEncoding != "" {
+		t.Errorf("upstream Accept-Encoding = %q with compression off", acceptEncoding)
+	}
+	if rec.Body.String() != `{"status":"OK","data":{"epoch":3}}` {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CommitteeCache keeps the committees (committee index -> validator indices)
+// of recently scanned slots, so repeated scans and cold starts need not
+// refetch them. Slots older than retainEpochs before head are pruned.
+type CommitteeCache struct {
+	retainEpochs   uint64
+	slotsPerEpoch  uint64
+	secondsPerSlot uint64
+
+	mu     sync.RWMutex
+	bySlot map[uint64]map[uint64][]uint64
+
+	hits, misses atomic.Uint64
+}
+
+func NewCommitteeCache(retainEpochs, slotsPerEpoch, secondsPerSlot uint64) *CommitteeCache {
+	return &CommitteeCache{
+		retainEpochs:   retainEpochs,
+		slotsPerEpoch:  slotsPerEpoch,
+		secondsPerSlot: secondsPerSlot,
+		bySlot:         make(map[uint64]map[uint64][]uint64),
+	}
+}
+
+func (c *CommitteeCache) Get(slot uint64) (map[uint64][]uint64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	committees, ok := c.bySlot[slot]
+	if ok {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return committees, ok
+}
+
+func (c *CommitteeCache) Set(slot uint64, committees map[uint64][]uint64) {
+	c.mu.Lock()
+	c.bySlot[slot] = committees
+	c.mu.Unlock()
+}
+
+// Prune drops slots more than retainEpochs epochs before headSlot.
+func (c *CommitteeCache) Prune(headSlot uint64) {
+	keep := c.retainEpochs * c.slotsPerEpoch
+	if headSlot < keep {
+		return
+	}
+	c.mu.Lock()
+	for slot := range c.bySlot {
+		if slot < headSlot-keep {
+			delete(c.bySlot, slot)
+		}
+	}
+	c.mu.Unlock()
+}
+
+func (c *CommitteeCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.bySlot)
+}
+
+// Stats reports the cache size and hit rate. Entries are pruned by slot
+// rather than by age, so no TTL is reported.
+func (c *CommitteeCache) Stats() cacheStats {
+	return cacheStats{Name: "committee", Entries: c.Len(), Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// committeeCacheFile is the on-disk form of a CommitteeCache.
+type committeeCacheFile struct {
+	SavedAt int64                          `json:"saved_at"` // unix seconds
+	Network string                         `json:"network"`  // genesis validators root
+	Slots   map[uint64]map[uint64][]uint64 `json:"slots"`
+}
+
+// Save writes the cache to path, replacing it atomically. network
+// identifies the chain the committees belong to (its genesis validators
+// root).
+func (c *CommitteeCache) Save(path, network string) error {
+	c.mu.RLock()
+	body, err := json.Marshal(committeeCacheFile{SavedAt: time.Now().Unix(), Network: network, Slots: c.bySlot})
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load reads a cache saved by Save. A missing file is not an error; a file
+// older than the retention window is ignored as stale, and so is one saved
+// for another network than network, or when either is unknown. It returns
+// the number of slots loaded.
+func (c *CommitteeCache) Load(path, network string) (int, error) {
+	body, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var f committeeCacheFile
+	if err := json.Unmarshal(body, &f); err != nil {
+		return 0, err
+	}
+	if network == "" || f.Network != network {
+		return 0, nil
+	}
+	maxAge := time.Duration(c.retainEpochs*c.slotsPerEpoch*c.secondsPerSlot) * time.Second
+	if time.Since(time.Unix(f.SavedAt, 0)) > maxAge {
+		return 0, nil
+	}
+	c.mu.Lock()
+	for slot, committees := range f.Slots {
+		c.bySlot[slot] = committees
+	}
+	c.mu.Unlock()
+	return len(f.Slots), nil
+}
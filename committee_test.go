@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testNetwork = "0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95"
+
+func TestCommitteeCacheDiskRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "committees.json")
+	c := NewCommitteeCache(2, 32, 12)
+	c.Set(100, map[uint64][]uint64{0: {1, 2, 3}, 1: {4, 5}})
+	c.Set(101, map[uint64][]uint64{0: {6}})
+	if err := c.Save(path, testNetwork); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewCommitteeCache(2, 32, 12)
+	n, err := loaded.Load(path, testNetwork)
+	if err != nil || n != 2 {
+		t.Fatalf("Load = %d, %v; want 2 slots", n, err)
+	}
+	for _, slot := range []uint64{100, 101} {
+		want, _ := c.Get(slot)
+		if got, ok := loaded.Get(slot); !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("slot %d = %v, want %v", slot, got, want)
+		}
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestCommitteeCacheLoadIgnored(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, savedAt time.Time, network string) string {
+		path := filepath.Join(dir, name)
+		body, _ := json.Marshal(committeeCacheFile{SavedAt: savedAt.Unix(), Network: network, Slots: map[uint64]map[uint64][]uint64{1: {0: {1}}}})
+		if err := os.WriteFile(path, body, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tests := []struct {
+		name, path, network string
+	}{
+		{"missing file", filepath.Join(dir, "missing.json"), testNetwork},
+		{"other network", write("other.json", time.Now(), "0xother"), testNetwork},
+		{"unknown network", write("unknown.json", time.Now(), testNetwork), ""},
+		{"file without network", write("none.json", time.Now(), ""), ""},
+		// two epochs of 32 12s slots is 12m48s
+		{"stale", write("stale.json", time.Now().Add(-13*time.Minute), testNetwork), testNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCommitteeCache(2, 32, 12)
+			n, err := c.Load(tt.path, tt.network)
+			if err != nil || n != 0 || c.Len() != 0 {
+				t.Fatalf("Load = %d, %v with %d cached; want nothing loaded", n, err, c.Len())
+			}
+		})
+	}
+}
+
+func TestCommitteeCacheLoadCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "committees.json")
+	os.WriteFile(path, []byte("{not json"), 0o644)
+	if _, err := NewCommitteeCache(2, 32, 12).Load(path, testNetwork); err == nil {
+		t.Fatal("corrupt file loaded without error")
+	}
+}
+
+func TestCommitteeCachePrune(t *testing.T) {
+	c := NewCommitteeCache(1, 32, 12)
+	for _, slot := range []uint64{10, 67, 68, 100} {
+		c.Set(slot, map[uint64][]uint64{})
+	}
+	c.Prune(100) // keeps slots from 68
+	for slot, want := range map[uint64]bool{10: false, 67: false, 68: true, 100: true} {
+		if _, ok := c.Get(slot); ok != want {
+			t.Errorf("slot %d kept = %v, want %v", slot, ok, want)
+		}
+	}
+}
+
+func TestSlotCommitteesRoute(t *testing.T) {
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/eth/v2/beacon/blocks/head":
+			jsonHandler(http.StatusOK, blockJSON(9, ""))(w, req)
+		case strings.HasSuffix(req.URL.Path, "/committees") && req.URL.Query().Get("slot") == "9":
+			jsonHandler(http.StatusOK, `{"data":[{"index":"0","slot":"9","validators":["1","2","3"]},{"index":"1","slot":"9","validators":["4","5"]}]}`)(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), "http://127.0.0.1:1", consensus.URL))
+
+	want := map[string]interface{}{
+		"slot": float64(9),
+		"committees": map[string]interface{}{
+			"0": []interface{}{float64(1), float64(2), float64(3)},
+			"1": []interface{}{float64(4), float64(5)},
+		},
+	}
+	for _, id := range []string{"9", "head"} {
+		rec := serve(h, http.MethodGet, "/api/v1/slot/"+id+"/committees", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", id, rec.Code, rec.Body.String())
+		}
+		if got := decodeJSON(t, rec); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: body = %v, want %v", id, got, want)
+		}
+	}
+
+	for _, id := range []string{"10", "0xunknown"} {
+		if rec := serve(h, http.MethodGet, "/api/v1/slot/"+id+"/committees", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", id, rec.Code)
+		}
+	}
+}
+
+// committeeStates answers committee requests from the states in serve and
+// 404 from the others, recording the state IDs asked for.
+func committeeStates(t *testing.T, asked *[]string, serve ...string) string {
+	t.Helper()
+	return newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		state, ok := strings.CutPrefix(req.URL.Path, "/eth/v1/beacon/states/")
+		state, ok2 := strings.CutSuffix(state, "/committees")
+		if !ok || !ok2 {
+			http.NotFound(w, req)
+			return
+		}
+		*asked = append(*asked, state)
+		for _, s := range serve {
+			if s == state {
+				jsonHandler(http.StatusOK, `{"data":[{"index":"0","slot":"`+req.URL.Query().Get("slot")+`","validators":["1","2"]}]}`)(w, req)
+				return
+			}
+		}
+		http.NotFound(w, req)
+	}).URL
+}
+
+func TestFetchCommitteesStateFallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		serve []string
+		slot  uint64
+		asked []string
+		found bool
+	}{
+		{"slot state", []string{"37", "32", "head"}, 37, []string{"37"}, true},
+		{"epoch boundary state", []string{"32", "head"}, 37, []string{"37", "32"}, true},
+		{"head state", []string{"head"}, 37, []string{"37", "32", "head"}, true},
+		{"boundary slot skips its duplicate", []string{"head"}, 32, []string{"32", "head"}, true},
+		{"no state", nil, 37, []string{"37", "32", "head"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked []string
+			tr := newTestTracker(t, newTestConfig(t), committeeStates(t, &asked, tt.serve...))
+			got := tr.fetchCommittees(context.Background(), tr.client, tt.slot)
+			if !reflect.DeepEqual(asked, tt.asked) {
+				t.Errorf("states asked = %v, want %v", asked, tt.asked)
+			}
+			if found := reflect.DeepEqual(got, map[uint64][]uint64{0: {1, 2}}); found != tt.found {
+				t.Errorf("committees = %v, found %v; want found %v", got, found, tt.found)
+			}
+		})
+	}
+}
+
+func TestDecodeCommittees(t *testing.T) {
+	tr := newTestTracker(t, newTestConfig(t), "http://127.0.0.1:1")
+	tests := []struct {
+		name string
+		body string
+		want map[uint64][]uint64
+	}{
+		{"strings", `{"data":[{"index":"0","slot":"9","validators":["1","2"]},{"index":"1","slot":"9","validators":["3"]}]}`,
+			map[uint64][]uint64{0: {1, 2}, 1: {3}}},
+		{"numbers", `{"data":[{"index":0,"slot":9,"validators":[1,2]},{"index":1,"slot":9,"validators":[3]}]}`,
+			map[uint64][]uint64{0: {1, 2}, 1: {3}}},
+		{"mixed", `{"data":[{"index":2,"validators":["4",5]}]}`,
+			map[uint64][]uint64{2: {4, 5}}},
+		{"committee without index skipped", `{"data":[{"validators":["1"]},{"index":"x","validators":["2"]},{"index":"3","validators":["6",null,"-1"]}]}`,
+			map[uint64][]uint64{3: {6}}},
+		{"large index", `{"data":[{"index":18446744073709551615,"validators":[18446744073709551614]}]}`,
+			map[uint64][]uint64{math.MaxUint64: {math.MaxUint64 - 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tr.decodeCommittees(strings.NewReader(tt.body)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCommittees = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := tr.decodeCommittees(strings.NewReader(`{"data":[`)); got != nil {
+		t.Errorf("decodeCommittees of broken JSON = %v, want nil", got)
+	}
+}
@@ -1,14 +1,206 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type proxyConfig struct {
 	ListenAddr      string
 	UpstreamBaseURL string
-	ConsensusAPIURL string
+	// ConsensusAPIURLs lists the beacon nodes, tried in order with failover.
+	ConsensusAPIURLs []string
+	// UpstreamAPIPrefix is appended to UpstreamBaseURL unless already present.
+	// Empty disables appending.
+	UpstreamAPIPrefix string
+	// Dora API paths below the base URL and prefix, overridable for Dora
+	// versions that moved them. Slot IDs are appended to PathSlot.
+	PathValidator   string
+	PathSlot        string
+	PathEpochLatest string
+	// ReadOnly rejects every request that is not GET, HEAD or OPTIONS.
+	ReadOnly bool
+	// DedupeValidators drops repeated validators from POST /api/v1/validator
+	// bodies before forwarding.
+	DedupeValidators bool
+	// ValidatorBatchWindow coalesces POST /api/v1/validator requests arriving
+	// within this window into one upstream request; zero disables batching.
+	ValidatorBatchWindow time.Duration
+	// ValidatorPageMax is the largest limit accepted when paging
+	// POST /api/v1/validator results.
+	ValidatorPageMax int
+	// CollapseSlashed reports every slashed validator as "slashed", including
+	// active ones still in the exit queue (otherwise "slashing_online").
+	CollapseSlashed bool
+	// StatusMap remaps Dora validator statuses ahead of the built-in
+	// mapping, e.g. for statuses introduced by a new fork.
+	StatusMap map[string]string
+	// WrapEnvelope wraps responses of proxy-served endpoints (answered from
+	// local state rather than upstream) in Dora's {"status","data"} envelope.
+	WrapEnvelope bool
+	// CORSOrigins lists origins allowed to call the proxy from a browser.
+	// "*" allows any origin; empty disables CORS headers.
+	CORSOrigins []string
+	// StripHeaders and ForwardHeaders filter the client headers sent
+	// upstream: StripHeaders are always dropped; a non-empty ForwardHeaders
+	// forwards only the headers it lists. Hop-by-hop headers never pass.
+	StripHeaders   []string
+	ForwardHeaders []string
+	// APIKey, when set, must be presented via X-API-Key or a bearer token.
+	APIKey string
+	// AttestationSource selects how lastattestationslot is attributed:
+	// "bitlist" decodes block attestations, "rewards" uses the beacon
+	// attestation rewards endpoint per epoch.
+	AttestationSource string
+	// ConcurrentWarmup runs the startup backfill alongside the live scanner
+	// instead of starting the scanner after backfill completes.
+	ConcurrentWarmup bool
+
+	// Per-client rate limiting; zero ClientRPS disables it. TrustForwardedFor
+	// keys clients on X-Forwarded-For instead of the connection address.
+	ClientRPS         float64
+	ClientBurst       int
+	TrustForwardedFor bool
+	// ForwardedHeaders adds X-Forwarded-For/-Proto/-Host to proxied
+	// requests so upstream sees the original client.
+	ForwardedHeaders bool
+
+	// Response cache for GET routes; a zero TTL disables it. Entry and byte
+	// limits apply together, zero meaning unlimited.
+	ResponseCacheTTL     time.Duration
+	ResponseCacheEntries int
+	ResponseCacheBytes   int64
+
+	// MaxRequestBodyBytes bounds buffered inbound request bodies (413 above).
+	MaxRequestBodyBytes int64
+	// MaxResponseBytes bounds upstream bodies the proxy reads to transform
+	// them (502 above); zero disables the limit.
+	MaxResponseBytes int64
+	// UpstreamMaxAttempts is how many times a request is sent to Dora when it
+	// fails with a transport error or 502/503/504.
+	UpstreamMaxAttempts  int
+	UpstreamRetryBackoff time.Duration
+	// StrictJSON rejects upstream bodies with data after the JSON value (502)
+	// instead of ignoring it.
+	StrictJSON bool
+	// BreakerFailures consecutive upstream failures open the circuit breaker
+	// for BreakerCooldown; zero disables the breaker.
+	BreakerFailures int
+	BreakerCooldown time.Duration
+
+	// Offline alerting: AlertWebhookURL receives a POST when a watched
+	// validator has not attested for AlertOfflineEpochs. Empty disables it.
+	AlertWebhookURL    string
+	AlertOfflineEpochs uint64
+	AlertMaxWatched    int
+
+	// ResolvePubkeys looks up indices for pubkey-only validator objects on the
+	// consensus node so they also get lastattestationslot.
+	ResolvePubkeys bool
+
+	// FloatPrecision is the number of decimals float fields in slot responses
+	// are rendered with; negative keeps Go's default formatting.
+	FloatPrecision int
+
+	// DisabledRoutes holds route names switched off via
+	// PROXY_ROUTE_<NAME>_ENABLED=false; they answer DisabledRouteStatus.
+	DisabledRoutes      map[string]bool
+	DisabledRouteStatus int
+
+	// SlotsRangeMax caps how many slots one /api/v1/slots request may span.
+	SlotsRangeMax uint64
+
+	// RequestBudget bounds the whole upstream fetch, retries and enrichment
+	// of slot requests; zero disables it.
+	RequestBudget time.Duration
+
+	// EnrichTimeout bounds the consensus calls made to enrich one slot; zero
+	// leaves them bound only by the inbound request and the HTTP client.
+	EnrichTimeout time.Duration
+
+	// SlotsPerEpoch and SecondsPerSlot drive all epoch and slot math. Zero
+	// (the default) takes them from the consensus spec at startup, see
+	// resolveSlotTiming.
+	SlotsPerEpoch  uint64
+	SecondsPerSlot uint64
+
+	// MaxRedirects caps redirects followed per outgoing request; zero refuses
+	// all. CrossHostRedirects allows redirects to a different host.
+	MaxRedirects       int
+	CrossHostRedirects bool
+
+	// RecentActivationEpochs flags validators activated within this many
+	// epochs of head as recently_activated; zero disables the flag.
+	RecentActivationEpochs uint64
+
+	// HTTP server timeouts for inbound connections.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// ShutdownTimeout is how long in-flight requests may run on shutdown
+	// before they are dropped.
+	ShutdownTimeout time.Duration
+
+	// Outgoing connection pool of each HTTP client.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// UpstreamCompression asks upstreams for gzip; bodies are decompressed
+	// by the transport before anything reads them.
+	UpstreamCompression bool
+
+	// UpstreamTimeout bounds user-facing calls to Dora and the consensus
+	// node. Background scanning uses its own client with ScannerTimeout and
+	// at most ScannerMaxConns connections per host (zero for no cap).
+	UpstreamTimeout time.Duration
+	ScannerTimeout  time.Duration
+	ScannerMaxConns int
+
+	// CommitteeCacheEpochs is how many epochs of committees the scanner
+	// keeps in memory (zero disables the cache). CommitteeCacheFile, when
+	// set, persists them across restarts.
+	CommitteeCacheEpochs uint64
+	CommitteeCacheFile   string
+
+	// BlockCacheSize is how many recently fetched beacon blocks are kept for
+	// BlockCacheTTL, shared by the scanner and the slot routes (zero
+	// disables the cache).
+	BlockCacheSize int
+	BlockCacheTTL  time.Duration
+
+	// HeadRootTTL is how long a resolved head block is reused for
+	// {slotOrHash}=head requests; zero resolves it on every request.
+	HeadRootTTL time.Duration
+	// FinalityTTL is how long the consensus finalized checkpoint is reused
+	// to mark slot responses finalized; zero disables the check.
+	FinalityTTL time.Duration
+
+	// /readyz fails once the scanner has ScannerMaxFailedTicks consecutive
+	// failed ticks (zero never fails), except during ScannerStartupGrace
+	// after startup while the backfill warms up.
+	ScannerMaxFailedTicks int
+	ScannerStartupGrace   time.Duration
+
+	// AttestationsBatchMax caps the indices of one POST /api/v1/attestations.
+	AttestationsBatchMax int
+
+	// BlockFetchAttempts is how often the scanner tries to fetch a block;
+	// retry N waits a random time up to N*BlockFetchBackoff.
+	BlockFetchAttempts int
+	BlockFetchBackoff  time.Duration
+
+	// ScanMaxSlotsPerTick caps the slots the live scanner covers per tick;
+	// a larger gap is worked off over several ticks. Zero disables the cap.
+	ScanMaxSlotsPerTick uint64
+	// ScanStartMode picks where the live scanner's first run starts: "head"
+	// or "continue-from-backfill".
+	ScanStartMode string
 }
 
 func getEnv(key, def string) string {
@@ -18,19 +210,416 @@ func getEnv(key, def string) string {
 	return def
 }
 
+// getEnvAllowEmpty is like getEnv but keeps an explicitly set empty value.
+func getEnvAllowEmpty(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+// getEnvList splits a comma-separated env var, dropping empty entries.
+func getEnvList(key string) []string {
+	var out []string
+	for _, p := range strings.Split(os.Getenv(key), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// getEnvStringMap parses a JSON object of strings, e.g. {"a":"b"}; unset
+// yields nil.
+func getEnvStringMap(key string) (map[string]string, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal([]byte(v), &m); err != nil {
+		return nil, fmt.Errorf("%s must be a JSON object of strings (got %q)", key, v)
+	}
+	return m, nil
+}
+
+func getEnvBool(key string, def bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be a boolean (got %q)", key, v)
+	}
+	return b, nil
+}
+
+func getEnvInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer (got %q)", key, v)
+	}
+	return n, nil
+}
+
+func getEnvInt64(key string, def int64) (int64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer (got %q)", key, v)
+	}
+	return n, nil
+}
+
+func getEnvFloat(key string, def float64) (float64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number (got %q)", key, v)
+	}
+	return f, nil
+}
+
+func getEnvDuration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative duration like 5s (got %q)", key, v)
+	}
+	return d, nil
+}
+
 func loadConfig() (*proxyConfig, error) {
 	cfg := &proxyConfig{
-		ListenAddr:      getEnv("PROXY_LISTEN_ADDR", ":8081"),
-		UpstreamBaseURL: getEnv("PROXY_UPSTREAM_BASE_URL", "http://localhost:8080"),
-		ConsensusAPIURL: getEnv("PROXY_CONSENSUS_API_URL", "http://localhost:5052"),
+		ListenAddr:       getEnv("PROXY_LISTEN_ADDR", ":8081"),
+		UpstreamBaseURL:  getEnv("PROXY_UPSTREAM_BASE_URL", "http://localhost:8080"),
+		ConsensusAPIURLs: getEnvList("PROXY_CONSENSUS_API_URL"),
+
+		UpstreamAPIPrefix:  getEnvAllowEmpty("PROXY_UPSTREAM_API_PREFIX", "/api"),
+		PathValidator:      getEnv("PROXY_PATH_VALIDATOR", "/v1/validator"),
+		PathSlot:           getEnv("PROXY_PATH_SLOT", "/v1/slot"),
+		PathEpochLatest:    getEnv("PROXY_PATH_EPOCH_LATEST", "/v1/epoch/latest"),
+		CORSOrigins:        getEnvList("PROXY_CORS_ORIGINS"),
+		StripHeaders:       getEnvList("PROXY_STRIP_HEADERS"),
+		ForwardHeaders:     getEnvList("PROXY_FORWARD_HEADERS"),
+		APIKey:             os.Getenv("PROXY_API_KEY"),
+		AlertWebhookURL:    os.Getenv("PROXY_ALERT_WEBHOOK_URL"),
+		CommitteeCacheFile: os.Getenv("PROXY_COMMITTEE_CACHE_FILE"),
+		AttestationSource:  strings.ToLower(getEnv("PROXY_ATTESTATION_SOURCE", attestationSourceBitlist)),
+		ScanStartMode:      strings.ToLower(getEnv("PROXY_SCAN_START_MODE", scanStartHead)),
 	}
 
-	// Ensure upstream has /api prefix once
-	if !strings.HasSuffix(cfg.UpstreamBaseURL, "/api") {
-		cfg.UpstreamBaseURL = strings.TrimRight(cfg.UpstreamBaseURL, "/") + "/api"
+	var err error
+	if cfg.ReadTimeout, err = getEnvDuration("PROXY_READ_TIMEOUT", 15*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WriteTimeout, err = getEnvDuration("PROXY_WRITE_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.IdleTimeout, err = getEnvDuration("PROXY_IDLE_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownTimeout, err = getEnvDuration("PROXY_SHUTDOWN_TIMEOUT", 15*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.MaxIdleConns, err = getEnvInt("PROXY_MAX_IDLE_CONNS", 100); err != nil {
+		return nil, err
+	}
+	if cfg.MaxIdleConnsPerHost, err = getEnvInt("PROXY_MAX_IDLE_CONNS_PER_HOST", 32); err != nil {
+		return nil, err
+	}
+	if cfg.IdleConnTimeout, err = getEnvDuration("PROXY_IDLE_CONN_TIMEOUT", 90*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.UpstreamCompression, err = getEnvBool("PROXY_UPSTREAM_COMPRESSION", true); err != nil {
+		return nil, err
+	}
+	if cfg.UpstreamTimeout, err = getEnvDuration("PROXY_UPSTREAM_TIMEOUT", 20*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ScannerTimeout, err = getEnvDuration("PROXY_SCANNER_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ScannerMaxConns, err = getEnvInt("PROXY_SCANNER_MAX_CONNS", 16); err != nil {
+		return nil, err
+	}
+	if cfg.HeadRootTTL, err = getEnvDuration("PROXY_HEAD_ROOT_TTL", 4*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.FinalityTTL, err = getEnvDuration("PROXY_FINALITY_TTL", 12*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ScannerMaxFailedTicks, err = getEnvInt("PROXY_SCANNER_MAX_FAILED_TICKS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.ScannerStartupGrace, err = getEnvDuration("PROXY_SCANNER_STARTUP_GRACE", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.AttestationsBatchMax, err = getEnvInt("PROXY_ATTESTATIONS_BATCH_MAX", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.BlockFetchAttempts, err = getEnvInt("PROXY_BLOCK_FETCH_ATTEMPTS", 3); err != nil {
+		return nil, err
+	}
+	if cfg.BlockFetchAttempts == 0 {
+		return nil, fmt.Errorf("PROXY_BLOCK_FETCH_ATTEMPTS must be at least 1")
+	}
+	if cfg.BlockFetchBackoff, err = getEnvDuration("PROXY_BLOCK_FETCH_BACKOFF", 100*time.Millisecond); err != nil {
+		return nil, err
+	}
+	maxSlotsPerTick, err := getEnvInt("PROXY_SCAN_MAX_SLOTS_PER_TICK", 64)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ScanMaxSlotsPerTick = uint64(maxSlotsPerTick)
+	committeeEpochs, err := getEnvInt("PROXY_COMMITTEE_CACHE_EPOCHS", 4)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CommitteeCacheEpochs = uint64(committeeEpochs)
+	if cfg.BlockCacheSize, err = getEnvInt("PROXY_BLOCK_CACHE_SIZE", 8); err != nil {
+		return nil, err
+	}
+	if cfg.BlockCacheTTL, err = getEnvDuration("PROXY_BLOCK_CACHE_TTL", 12*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ReadOnly, err = getEnvBool("PROXY_READ_ONLY", false); err != nil {
+		return nil, err
+	}
+	if cfg.DedupeValidators, err = getEnvBool("PROXY_DEDUPE_VALIDATORS", false); err != nil {
+		return nil, err
+	}
+	if cfg.ValidatorBatchWindow, err = getEnvDuration("PROXY_VALIDATOR_BATCH_WINDOW", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ValidatorPageMax, err = getEnvInt("PROXY_VALIDATOR_PAGE_MAX", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.ValidatorPageMax == 0 {
+		return nil, fmt.Errorf("PROXY_VALIDATOR_PAGE_MAX must be at least 1")
+	}
+	if cfg.CollapseSlashed, err = getEnvBool("PROXY_COLLAPSE_SLASHED", false); err != nil {
+		return nil, err
+	}
+	if cfg.StatusMap, err = getEnvStringMap("PROXY_STATUS_MAP"); err != nil {
+		return nil, err
+	}
+	if cfg.WrapEnvelope, err = getEnvBool("PROXY_WRAP_ENVELOPE", false); err != nil {
+		return nil, err
+	}
+	if cfg.ResponseCacheTTL, err = getEnvDuration("PROXY_RESPONSE_CACHE_TTL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ResponseCacheEntries, err = getEnvInt("PROXY_RESPONSE_CACHE_ENTRIES", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.ResponseCacheBytes, err = getEnvInt64("PROXY_RESPONSE_CACHE_BYTES", 64<<20); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRequestBodyBytes, err = getEnvInt64("PROXY_MAX_REQUEST_BYTES", 1<<20); err != nil {
+		return nil, err
+	}
+	if cfg.MaxResponseBytes, err = getEnvInt64("PROXY_MAX_RESPONSE_BYTES", 128<<20); err != nil {
+		return nil, err
+	}
+	if cfg.UpstreamMaxAttempts, err = getEnvInt("PROXY_UPSTREAM_MAX_ATTEMPTS", 1); err != nil {
+		return nil, err
+	}
+	if cfg.UpstreamRetryBackoff, err = getEnvDuration("PROXY_UPSTREAM_RETRY_BACKOFF", 200*time.Millisecond); err != nil {
+		return nil, err
+	}
+	if cfg.StrictJSON, err = getEnvBool("PROXY_STRICT_JSON", false); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerFailures, err = getEnvInt("PROXY_BREAKER_FAILURES", 5); err != nil {
+		return nil, err
+	}
+	if cfg.BreakerCooldown, err = getEnvDuration("PROXY_BREAKER_COOLDOWN", 30*time.Second); err != nil {
+		return nil, err
+	}
+	offlineEpochs, err := getEnvInt("PROXY_ALERT_OFFLINE_EPOCHS", 3)
+	if err != nil {
+		return nil, err
+	}
+	cfg.AlertOfflineEpochs = uint64(offlineEpochs)
+	if cfg.AlertMaxWatched, err = getEnvInt("PROXY_ALERT_MAX_WATCHED", 1000); err != nil {
+		return nil, err
+	}
+	if cfg.AlertWebhookURL != "" {
+		if err := validateHTTPURL("PROXY_ALERT_WEBHOOK_URL", cfg.AlertWebhookURL); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ResolvePubkeys, err = getEnvBool("PROXY_RESOLVE_PUBKEYS", false); err != nil {
+		return nil, err
+	}
+	cfg.FloatPrecision = -1
+	if os.Getenv("PROXY_FLOAT_PRECISION") != "" {
+		if cfg.FloatPrecision, err = getEnvInt("PROXY_FLOAT_PRECISION", -1); err != nil {
+			return nil, err
+		}
+	}
+	cfg.DisabledRoutes = make(map[string]bool)
+	for _, name := range routeNames {
+		enabled, err := getEnvBool("PROXY_ROUTE_"+name+"_ENABLED", true)
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			cfg.DisabledRoutes[name] = true
+		}
+	}
+	if cfg.DisabledRouteStatus, err = getEnvInt("PROXY_DISABLED_ROUTE_STATUS", http.StatusNotFound); err != nil {
+		return nil, err
+	}
+	if cfg.DisabledRouteStatus != http.StatusNotFound && cfg.DisabledRouteStatus != http.StatusForbidden {
+		return nil, fmt.Errorf("PROXY_DISABLED_ROUTE_STATUS must be 404 or 403 (got %d)", cfg.DisabledRouteStatus)
+	}
+	slotsRangeMax, err := getEnvInt("PROXY_SLOTS_RANGE_MAX", 32)
+	if err != nil {
+		return nil, err
+	}
+	if slotsRangeMax == 0 {
+		return nil, fmt.Errorf("PROXY_SLOTS_RANGE_MAX must be at least 1")
+	}
+	cfg.SlotsRangeMax = uint64(slotsRangeMax)
+	if cfg.EnrichTimeout, err = getEnvDuration("PROXY_ENRICH_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.RequestBudget, err = getEnvDuration("PROXY_REQUEST_BUDGET", 0); err != nil {
+		return nil, err
+	}
+	slotsPerEpochCfg, err := getEnvInt("PROXY_SLOTS_PER_EPOCH", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.SlotsPerEpoch = uint64(slotsPerEpochCfg)
+	secondsPerSlotCfg, err := getEnvInt("PROXY_SECONDS_PER_SLOT", 0)
+	if err != nil {
+		return nil, err
+	}
+	cfg.SecondsPerSlot = uint64(secondsPerSlotCfg)
+	if cfg.MaxRedirects, err = getEnvInt("PROXY_MAX_REDIRECTS", 3); err != nil {
+		return nil, err
+	}
+	if cfg.CrossHostRedirects, err = getEnvBool("PROXY_CROSS_HOST_REDIRECTS", false); err != nil {
+		return nil, err
+	}
+	recentActivation, err := getEnvInt("PROXY_RECENT_ACTIVATION_EPOCHS", 2)
+	if err != nil {
+		return nil, err
+	}
+	cfg.RecentActivationEpochs = uint64(recentActivation)
+	if cfg.ConcurrentWarmup, err = getEnvBool("PROXY_CONCURRENT_WARMUP", false); err != nil {
+		return nil, err
+	}
+	if cfg.ClientRPS, err = getEnvFloat("PROXY_CLIENT_RPS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ClientBurst, err = getEnvInt("PROXY_CLIENT_BURST", 20); err != nil {
+		return nil, err
+	}
+	if cfg.TrustForwardedFor, err = getEnvBool("PROXY_TRUST_FORWARDED_FOR", false); err != nil {
+		return nil, err
+	}
+	if cfg.ForwardedHeaders, err = getEnvBool("PROXY_FORWARDED_HEADERS", true); err != nil {
+		return nil, err
+	}
+
+	switch cfg.AttestationSource {
+	case attestationSourceBitlist, attestationSourceRewards:
+	default:
+		return nil, fmt.Errorf("PROXY_ATTESTATION_SOURCE must be %q or %q (got %q)", attestationSourceBitlist, attestationSourceRewards, cfg.AttestationSource)
+	}
+	switch cfg.ScanStartMode {
+	case scanStartHead, scanStartContinue:
+	default:
+		return nil, fmt.Errorf("PROXY_SCAN_START_MODE must be %q or %q (got %q)", scanStartHead, scanStartContinue, cfg.ScanStartMode)
+	}
+
+	if err := validateHTTPURL("PROXY_UPSTREAM_BASE_URL", cfg.UpstreamBaseURL); err != nil {
+		return nil, err
+	}
+	if len(cfg.ConsensusAPIURLs) == 0 {
+		cfg.ConsensusAPIURLs = []string{"http://localhost:5052"}
+	}
+	for _, u := range cfg.ConsensusAPIURLs {
+		if err := validateHTTPURL("PROXY_CONSENSUS_API_URL", u); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.UpstreamBaseURL = applyAPIPrefix(cfg.UpstreamBaseURL, cfg.UpstreamAPIPrefix)
+	for _, p := range []struct {
+		name string
+		path *string
+	}{
+		{"PROXY_PATH_VALIDATOR", &cfg.PathValidator},
+		{"PROXY_PATH_SLOT", &cfg.PathSlot},
+		{"PROXY_PATH_EPOCH_LATEST", &cfg.PathEpochLatest},
+	} {
+		if *p.path, err = normalizeUpstreamPath(p.name, *p.path); err != nil {
+			return nil, err
+		}
 	}
 
 	return cfg, nil
 }
 
+// normalizeUpstreamPath checks that raw is an absolute URL path and drops
+// any trailing slash.
+func normalizeUpstreamPath(name, raw string) (string, error) {
+	path := strings.TrimRight(strings.TrimSpace(raw), "/")
+	if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, "?#") {
+		return "", fmt.Errorf("%s must be a path starting with / (got %q)", name, raw)
+	}
+	return path, nil
+}
 
+// applyAPIPrefix ensures base ends with prefix exactly once. An empty prefix
+// leaves base untouched.
+func applyAPIPrefix(base, prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		return base
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	if strings.HasSuffix(strings.TrimRight(base, "/"), prefix) {
+		return strings.TrimRight(base, "/")
+	}
+	return strings.TrimRight(base, "/") + prefix
+}
+
+// validateHTTPURL checks that raw is an absolute http(s) URL with a host.
+func validateHTTPURL(name, raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return fmt.Errorf("%s must not be empty", name)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid URL: %w", name, err)
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute URL (got %q)", name, raw)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s must use http or https scheme (got %q)", name, u.Scheme)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateHTTPURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		wantErr string // substring, "" for valid
+	}{
+		{"http://localhost:8080", ""},
+		{"https://dora.example.org/api", ""},
+		{"", "must not be empty"},
+		{"   ", "must not be empty"},
+		{"/api/v1", "must be an absolute URL"},
+		{"localhost:8080", "must be an absolute URL"},
+		{"http://", "must be an absolute URL"},
+		{"ftp://dora.example.org", "must use http or https"},
+		{"ws://localhost:5052", "must use http or https"},
+		{"http://[::1", "is not a valid URL"},
+	}
+	for _, tt := range tests {
+		err := validateHTTPURL("PROXY_UPSTREAM_BASE_URL", tt.raw)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validateHTTPURL(%q) = %v, want nil", tt.raw, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("validateHTTPURL(%q) = %v, want error containing %q", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if !strings.Contains(err.Error(), "PROXY_UPSTREAM_BASE_URL") {
+			t.Errorf("validateHTTPURL(%q) = %v, want the variable named", tt.raw, err)
+		}
+	}
+}
+
+func TestLoadConfigRejectsInvalidURLs(t *testing.T) {
+	tests := []struct {
+		env, value string
+	}{
+		{"PROXY_UPSTREAM_BASE_URL", "/relative"},
+		{"PROXY_UPSTREAM_BASE_URL", "ftp://dora:8080"},
+		{"PROXY_CONSENSUS_API_URL", "localhost:5052"},
+		{"PROXY_CONSENSUS_API_URL", "http://beacon:5052,file:///tmp/beacon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), tt.env) {
+				t.Fatalf("loadConfig() error = %v, want one naming %s", err, tt.env)
+			}
+		})
+	}
+}
+
+func TestApplyAPIPrefix(t *testing.T) {
+	tests := []struct {
+		base, prefix, want string
+	}{
+		{"http://dora:8080", "/api", "http://dora:8080/api"},
+		{"http://dora:8080/", "/api", "http://dora:8080/api"},
+		{"http://dora:8080/api", "/api", "http://dora:8080/api"},
+		{"http://dora:8080/api/", "/api/", "http://dora:8080/api"},
+		{"http://host/dora", "/explorer/api", "http://host/dora/explorer/api"},
+		{"http://host/dora", "explorer/api", "http://host/dora/explorer/api"},
+		{"http://host/explorer/api", "/explorer/api", "http://host/explorer/api"},
+		{"http://host/dora", "", "http://host/dora"},
+		{"http://host/dora/", "", "http://host/dora/"},
+		{"http://host/dora", "/", "http://host/dora"},
+	}
+	for _, tt := range tests {
+		if got := applyAPIPrefix(tt.base, tt.prefix); got != tt.want {
+			t.Errorf("applyAPIPrefix(%q, %q) = %q, want %q", tt.base, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigAPIPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		set    bool // false leaves PROXY_UPSTREAM_API_PREFIX unset
+		prefix string
+		want   string
+	}{
+		{"default", false, "", "http://dora:8080/api"},
+		{"custom", true, "/explorer/api", "http://dora:8080/explorer/api"},
+		{"disabled", true, "", "http://dora:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PROXY_UPSTREAM_BASE_URL", "http://dora:8080")
+			if tt.set {
+				t.Setenv("PROXY_UPSTREAM_API_PREFIX", tt.prefix)
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.UpstreamBaseURL != tt.want {
+				t.Fatalf("UpstreamBaseURL = %q, want %q", cfg.UpstreamBaseURL, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigServerTimeouts(t *testing.T) {
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ReadTimeout != 15*time.Second || cfg.WriteTimeout != 30*time.Second || cfg.IdleTimeout != 60*time.Second {
+		t.Fatalf("defaults = %v, %v, %v; want 15s, 30s, 1m", cfg.ReadTimeout, cfg.WriteTimeout, cfg.IdleTimeout)
+	}
+
+	t.Setenv("PROXY_READ_TIMEOUT", "2m")
+	t.Setenv("PROXY_WRITE_TIMEOUT", "90s")
+	t.Setenv("PROXY_IDLE_TIMEOUT", "0s")
+	if cfg, err = loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ReadTimeout != 2*time.Minute || cfg.WriteTimeout != 90*time.Second || cfg.IdleTimeout != 0 {
+		t.Fatalf("got %v, %v, %v; want 2m, 90s, 0", cfg.ReadTimeout, cfg.WriteTimeout, cfg.IdleTimeout)
+	}
+}
+
+func TestLoadConfigRejectsBadTimeouts(t *testing.T) {
+	for _, name := range []string{"PROXY_READ_TIMEOUT", "PROXY_WRITE_TIMEOUT", "PROXY_IDLE_TIMEOUT"} {
+		for _, value := range []string{"15", "soon", "-1s"} {
+			t.Run(name+"="+value, func(t *testing.T) {
+				t.Setenv(name, value)
+				_, err := loadConfig()
+				if err == nil || !strings.Contains(err.Error(), name) {
+					t.Fatalf("loadConfig error = %v, want one naming %s", err, name)
+				}
+			})
+		}
+	}
+}
+
+func TestLoadConfigUpstreamPaths(t *testing.T) {
+	t.Setenv("PROXY_PATH_SLOT", " /v2/slots/ ")
+	t.Setenv("PROXY_PATH_VALIDATOR", "/v2/validators")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.PathSlot != "/v2/slots" || cfg.PathValidator != "/v2/validators" || cfg.PathEpochLatest == "" {
+		t.Fatalf("paths = %q, %q, %q", cfg.PathSlot, cfg.PathValidator, cfg.PathEpochLatest)
+	}
+
+	for _, bad := range []string{"v2/slots", "/v2/slots?x=1"} {
+		t.Setenv("PROXY_PATH_SLOT", bad)
+		if _, err := loadConfig(); err == nil {
+			t.Errorf("PROXY_PATH_SLOT=%q accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ConsensusNodes is the list of beacon nodes from PROXY_CONSENSUS_API_URL.
+// Requests go to the nodes in order, healthy ones first, and fail over to
+// the next node on a connection error or a 5xx answer. Other statuses,
+// including 404, are answers and are returned as is. A failed node is
+// probed again in its configured place once nodeRetryAfter has passed, so a
+// recovered primary gets its traffic back.
+type ConsensusNodes struct {
+	nodes      []*consensusNode
+	retryAfter time.Duration
+}
+
+// nodeRetryAfter is how long a failed node waits behind the healthy ones
+// before it is probed again.
+const nodeRetryAfter = 30 * time.Second
+
+type consensusNode struct {
+	base     string
+	healthy  atomic.Bool
+	failures atomic.Uint64
+	lastFail atomic.Int64 // unix nanoseconds, 0 when the node never failed
+}
+
+// consensusNodeStatus is a node's health as reported on the status endpoint.
+type consensusNodeStatus struct {
+	URL        string     `json:"url"`
+	Healthy    bool       `json:"healthy"`
+	Failures   uint64     `json:"failures"`
+	LastFailAt *time.Time `json:"last_fail_at,omitempty"`
+}
+
+func NewConsensusNodes(urls []string) *ConsensusNodes {
+	c := &ConsensusNodes{retryAfter: nodeRetryAfter}
+	for _, u := range urls {
+		n := &consensusNode{base: strings.TrimRight(u, "/")}
+		n.healthy.Store(true)
+		c.nodes = append(c.nodes, n)
+	}
+	return c
+}
+
+// URLs returns the node base URLs in configured order.
+func (c *ConsensusNodes) URLs() []string {
+	out := make([]string, len(c.nodes))
+	for i, n := range c.nodes {
+		out[i] = n.base
+	}
+	return out
+}
+
+// Status reports each node's health in configured order.
+func (c *ConsensusNodes) Status() []consensusNodeStatus {
+	out := make([]consensusNodeStatus, len(c.nodes))
+	for i, n := range c.nodes {
+		out[i] = consensusNodeStatus{URL: n.base, Healthy: n.healthy.Load(), Failures: n.failures.Load()}
+		if ts := n.lastFail.Load(); ts != 0 {
+			t := time.Unix(0, ts).UTC()
+			out[i].LastFailAt = &t
+		}
+	}
+	return out
+}
+
+// Get issues a GET of path (e.g. "/eth/v1/config/spec") accepting JSON.
+func (c *ConsensusNodes) Get(ctx context.Context, client *http.Client, path string) (*http.Response, error) {
+	return c.Do(ctx, client, http.MethodGet, path, http.Header{"Accept": []string{"application/json"}}, nil)
+}
+
+// Do sends the request to the first node that answers below 500. When every
+// node fails, the last node's error or 5xx response is returned; the caller
+// must close a returned body.
+func (c *ConsensusNodes) Do(ctx context.Context, client *http.Client, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	order := c.order()
+	var lastErr error
+	for i, n := range order {
+		var rd io.Reader
+		if body != nil {
+			rd = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, n.base+path, rd)
+		if err != nil {
+			return nil, err
+		}
+		for k, vv := range header {
+			req.Header[k] = vv
+		}
+		resp, err := client.Do(req)
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up; not the node's fault
+			return nil, err
+		}
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			n.healthy.Store(true)
+			return resp, nil
+		}
+		n.healthy.Store(false)
+		n.failures.Add(1)
+		n.lastFail.Store(time.Now().UnixNano())
+		if err == nil {
+			if i == len(order)-1 {
+				return resp, nil
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// order returns the nodes to try: the healthy ones followed by the
+// unhealthy ones, each in configured order, so a failed node is retried once
+// the others fail too. An unhealthy node whose last failure is older than
+// retryAfter keeps its configured place, as a probe; failing again pushes it
+// back for another retryAfter.
+func (c *ConsensusNodes) order() []*consensusNode {
+	retryBefore := time.Now().Add(-c.retryAfter).UnixNano()
+	preferred := func(n *consensusNode) bool {
+		return n.healthy.Load() || n.lastFail.Load() < retryBefore
+	}
+	out := make([]*consensusNode, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		if preferred(n) {
+			out = append(out, n)
+		}
+	}
+	for _, n := range c.nodes {
+		if !preferred(n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
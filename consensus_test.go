@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// deadURL returns the address of a server that is no longer listening.
+func deadURL(t *testing.T) string {
+	t.Helper()
+	srv := newTestServer(t, http.NotFound)
+	srv.Close()
+	return srv.URL
+}
+
+func TestConsensusFailover(t *testing.T) {
+	served := 0
+	second := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		served++
+		jsonHandler(http.StatusOK, blockJSON(5, ""))(w, req)
+	})
+	down := deadURL(t)
+	nodes := NewConsensusNodes([]string{down, second.URL})
+
+	slotData := map[string]interface{}{}
+	if err := enrichSlotConsensus(context.Background(), http.DefaultClient, nodes, nil, "5", slotData); err != nil {
+		t.Fatalf("enrichSlotConsensus with the first node down: %v", err)
+	}
+	if slotData["proposer"] != uint64(7) || served != 1 {
+		t.Fatalf("proposer = %v after %d requests to the second node", slotData["proposer"], served)
+	}
+
+	status := nodes.Status()
+	if status[0].Healthy || status[0].Failures != 1 || status[0].LastFailAt == nil {
+		t.Errorf("first node status = %+v, want unhealthy after one failure", status[0])
+	}
+	if !status[1].Healthy || status[1].Failures != 0 {
+		t.Errorf("second node status = %+v, want healthy", status[1])
+	}
+
+	// the unhealthy node is tried last from now on
+	resp, err := nodes.Get(context.Background(), http.DefaultClient, "/eth/v2/beacon/blocks/5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := nodes.Status()[0].Failures; got != 1 {
+		t.Errorf("first node failures = %d, want it skipped while unhealthy", got)
+	}
+}
+
+func TestConsensusAllNodesFail(t *testing.T) {
+	errorNode := newTestServer(t, jsonHandler(http.StatusServiceUnavailable, `{"code":503}`))
+	nodes := NewConsensusNodes([]string{deadURL(t), errorNode.URL})
+	resp, err := nodes.Get(context.Background(), http.DefaultClient, "/eth/v1/node/version")
+	if err != nil {
+		t.Fatalf("Get: %v, want the last node's response", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want the last node's 503", resp.StatusCode)
+	}
+
+	nodes = NewConsensusNodes([]string{errorNode.URL, deadURL(t)})
+	if _, err := nodes.Get(context.Background(), http.DefaultClient, "/eth/v1/node/version"); err == nil {
+		t.Error("no error with the last node unreachable")
+	}
+}
+
+func TestLoadConfigConsensusList(t *testing.T) {
+	t.Setenv("PROXY_CONSENSUS_API_URL", "http://a:5052, http://b:5052")
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.ConsensusAPIURLs) != 2 || cfg.ConsensusAPIURLs[0] != "http://a:5052" || cfg.ConsensusAPIURLs[1] != "http://b:5052" {
+		t.Fatalf("ConsensusAPIURLs = %q", cfg.ConsensusAPIURLs)
+	}
+}
+
+// A recovered primary is probed again after the cooldown and takes the
+// traffic back from the secondary.
+func TestConsensusPrimaryReprobed(t *testing.T) {
+	var primaryUp atomic.Bool
+	var primaryServed, secondaryServed atomic.Int32
+	primary := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if !primaryUp.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		primaryServed.Add(1)
+		jsonHandler(http.StatusOK, `{}`)(w, req)
+	})
+	secondary := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		secondaryServed.Add(1)
+		jsonHandler(http.StatusOK, `{}`)(w, req)
+	})
+	nodes := NewConsensusNodes([]string{primary.URL, secondary.URL})
+	nodes.retryAfter = 50 * time.Millisecond
+	get := func() {
+		t.Helper()
+		resp, err := nodes.Get(context.Background(), http.DefaultClient, "/eth/v1/node/version")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	get() // primary fails, secondary answers
+	primaryUp.Store(true)
+	get() // within the cooldown: secondary only
+	if primaryServed.Load() != 0 || secondaryServed.Load() != 2 {
+		t.Fatalf("served primary %d, secondary %d; want the primary skipped during the cooldown", primaryServed.Load(), secondaryServed.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	get()
+	get()
+	if primaryServed.Load() != 2 || secondaryServed.Load() != 2 {
+		t.Fatalf("served primary %d, secondary %d; want the recovered primary used again", primaryServed.Load(), secondaryServed.Load())
+	}
+	if !nodes.Status()[0].Healthy {
+		t.Error("primary still reported unhealthy")
+	}
+}
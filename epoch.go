@@ -0,0 +1,31 @@
+package main
+
+// currentEpoch is the /api/v1/epoch/current response.
+type currentEpoch struct {
+	Epoch       uint64 `json:"epoch"`
+	Slot        uint64 `json:"slot"`
+	SlotInEpoch uint64 `json:"slot_in_epoch"`
+}
+
+// currentEpochAt derives the epoch position of headSlot.
+func currentEpochAt(headSlot, slotsPerEpoch uint64) currentEpoch {
+	return currentEpoch{
+		Epoch:       headSlot / slotsPerEpoch,
+		Slot:        headSlot,
+		SlotInEpoch: headSlot % slotsPerEpoch,
+	}
+}
+
+// epochSlots is the /api/v1/epoch/{epoch}/slots response.
+type epochSlots struct {
+	Epoch uint64      `json:"epoch"`
+	Slots []epochSlot `json:"slots"`
+}
+
+// epochSlot is one slot of an epoch. Status is "present" (block proposed),
+// "missed" (no block), "scheduled" (after the known head, not probed) or
+// "unknown" (the consensus node could not be asked).
+type epochSlot struct {
+	Slot   uint64 `json:"slot"`
+	Status string `json:"status"`
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCurrentEpochAt(t *testing.T) {
+	tests := []struct {
+		head, slotsPerEpoch uint64
+		want                currentEpoch
+	}{
+		{0, 32, currentEpoch{Epoch: 0, Slot: 0, SlotInEpoch: 0}},
+		{31, 32, currentEpoch{Epoch: 0, Slot: 31, SlotInEpoch: 31}},
+		{32, 32, currentEpoch{Epoch: 1, Slot: 32, SlotInEpoch: 0}},
+		{9000005, 32, currentEpoch{Epoch: 281250, Slot: 9000005, SlotInEpoch: 5}},
+		{17, 8, currentEpoch{Epoch: 2, Slot: 17, SlotInEpoch: 1}},
+	}
+	for _, tt := range tests {
+		if got := currentEpochAt(tt.head, tt.slotsPerEpoch); got != tt.want {
+			t.Errorf("currentEpochAt(%d, %d) = %+v, want %+v", tt.head, tt.slotsPerEpoch, got, tt.want)
+		}
+	}
+}
+
+func TestEpochCurrentRoute(t *testing.T) {
+	calls := 0
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) { calls++ })
+	d := newTestDeps(t, newTestConfig(t), dora.URL, "")
+	h := buildRouter(d)
+
+	if rec := serve(h, http.MethodGet, "/api/v1/epoch/current", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status before the head is known = %d, want 503", rec.Code)
+	}
+	setHeadSlot(d.tracker, 100)
+	rec := serve(h, http.MethodGet, "/api/v1/epoch/current", "")
+	m := decodeJSON(t, rec)
+	if m["epoch"] != float64(3) || m["slot"] != float64(100) || m["slot_in_epoch"] != float64(4) {
+		t.Fatalf("body = %s, want epoch 3, slot 100, slot_in_epoch 4", rec.Body.String())
+	}
+	if calls != 0 {
+		t.Fatalf("upstream called %d times, want none", calls)
+	}
+}
+
+func TestEpochSlotsRoute(t *testing.T) {
+	blocks := &blockCounter{head: 20, fetches: make(map[string]int)}
+	d := newTestDeps(t, newTestConfig(t), "http://127.0.0.1:1", newTestServer(t, blocks.ServeHTTP).URL)
+	setHeadSlot(d.tracker, 25)
+	rec := serve(buildRouter(d), http.MethodGet, "/api/v1/epoch/0/slots", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got epochSlots
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Epoch != 0 || len(got.Slots) != 32 {
+		t.Fatalf("epoch %d with %d slots, want epoch 0 with 32", got.Epoch, len(got.Slots))
+	}
+	for i, s := range got.Slots {
+		want := "present"
+		switch {
+		case i > 25:
+			want = "scheduled"
+		case i > 20:
+			want = "missed"
+		}
+		if s.Slot != uint64(i) || s.Status != want {
+			t.Errorf("slots[%d] = %+v, want slot %d %s", i, s, i, want)
+		}
+	}
+	// slots after the head are not probed
+	if n := blocks.count(fmt.Sprint(26)); n != 0 {
+		t.Errorf("slot 26 after head probed %d times", n)
+	}
+}
+
+func TestEpochSlotsRouteRejectsBadEpochs(t *testing.T) {
+	h := buildRouter(newTestDeps(t, newTestConfig(t), "http://127.0.0.1:1", ""))
+	for _, epoch := range []string{"-1", "abc", "576460752303423488"} { // the last overflows epoch*32
+		if rec := serve(h, http.MethodGet, "/api/v1/epoch/"+epoch+"/slots", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("epoch %s: status = %d, want 400", epoch, rec.Code)
+		}
+	}
+}
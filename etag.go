@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// slotFinalized reports whether a slot status marks the slot as final, so
+// its response can no longer change.
+func slotFinalized(status string) bool {
+	return strings.EqualFold(status, "finalized")
+}
+
+// slotETag returns a strong ETag for a finalized slot response, or "" when
+// the response may still change. An unenriched response changes once the
+// consensus node answers, so it gets none either.
+func slotETag(slot SlotResponse) string {
+	if !slotFinalized(slot.Status) || !slot.Enriched {
+		return ""
+	}
+	b, err := json.Marshal(slot)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, c := range strings.Split(ifNoneMatch, ",") {
+		c = strings.TrimPrefix(strings.TrimSpace(c), "W/")
+		if c == "*" || c == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionalResponses answers GET requests with 304 Not Modified when the
+// handler's 200 response carries an ETag listed in If-None-Match.
+func conditionalResponses(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		inm := req.Header.Get("If-None-Match")
+		if req.Method != http.MethodGet || inm == "" {
+			next(w, req)
+			return
+		}
+		next(&notModifiedWriter{ResponseWriter: w, ifNoneMatch: inm}, req)
+	}
+}
+
+// notModifiedWriter turns a matching 200 into a bodiless 304.
+type notModifiedWriter struct {
+	http.ResponseWriter
+	ifNoneMatch string
+	wrote       bool
+	discard     bool
+}
+
+func (nw *notModifiedWriter) WriteHeader(code int) {
+	if nw.wrote {
+		return
+	}
+	nw.wrote = true
+	if etag := nw.Header().Get("ETag"); code == http.StatusOK && etag != "" && etagMatches(nw.ifNoneMatch, etag) {
+		nw.discard = true
+		nw.Header().Del("Content-Type")
+		nw.Header().Del("Content-Length")
+		code = http.StatusNotModified
+	}
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+func (nw *notModifiedWriter) Write(b []byte) (int, error) {
+	if !nw.wrote {
+		nw.WriteHeader(http.StatusOK)
+	}
+	if nw.discard {
+		return len(b), nil
+	}
+	return nw.ResponseWriter.Write(b)
+}
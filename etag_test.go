@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"x", "abc"`, true},
+		{`*`, true},
+		{`"abd"`, false},
+		{`abc`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"abc"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestSlotETagOnlyWhenFinal(t *testing.T) {
+	slot := func(n uint64, status string, enriched bool) SlotResponse {
+		s := SlotResponse{Enriched: enriched}
+		s.Slot, s.Status = n, status
+		return s
+	}
+	etag := slotETag(slot(5, "Finalized", true))
+	if etag == "" {
+		t.Fatal("no ETag for an enriched finalized slot")
+	}
+	if slotETag(slot(5, "Proposed", true)) != "" {
+		t.Error("ETag for a slot that is not finalized")
+	}
+	if slotETag(slot(5, "Finalized", false)) != "" {
+		t.Error("ETag for an unenriched slot")
+	}
+	if slotETag(slot(6, "Finalized", true)) == etag {
+		t.Error("different slots share an ETag")
+	}
+}
+
+func TestSlotNotModified(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0,"status":"Finalized"}}`))
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/eth/v2/beacon/blocks/5" {
+			jsonHandler(http.StatusOK, blockJSON(5, ""))(w, req)
+			return
+		}
+		http.NotFound(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, consensus.URL))
+
+	rec := serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q; want 200 with an ETag: %s", rec.Code, etag, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/slot/5", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("status = %d with a matching If-None-Match, want 304", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("304 has a body: %s", rec.Body.String())
+	}
+
+	req.Header.Set("If-None-Match", `"stale"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Fatalf("status = %d with a stale ETag, want 200 with the body", rec.Code)
+	}
+}
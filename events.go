@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// streamHeadEvents relays the consensus node's head events to the client as
+// server-sent events. The upstream subscription is closed as soon as the
+// client disconnects or shutdown is done, rather than holding up a graceful
+// shutdown for the whole grace period.
+func streamHeadEvents(w http.ResponseWriter, req *http.Request, shutdown context.Context, client *http.Client, consensus *ConsensusNodes, log *logrus.Logger) {
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	stop := context.AfterFunc(shutdown, cancel)
+	defer stop()
+	// The stream is open-ended; only the context bounds it
+	streamClient := *client
+	streamClient.Timeout = 0
+	header := http.Header{"Accept": []string{"text/event-stream"}}
+	resp, err := consensus.Do(ctx, &streamClient, http.MethodGet, "/eth/v1/events?topics=head", header, nil)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "consensus event stream unreachable")
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		writeError(w, http.StatusBadGateway, "consensus event stream unavailable")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// Lift the server write timeout for this long-lived response
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	log.Debug("head event stream opened")
+	defer log.Debug("head event stream closed")
+	br := bufio.NewReader(resp.Body)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := w.Write(line); werr != nil {
+				return
+			}
+			// A blank line ends an event
+			if len(bytes.TrimRight(line, "\r\n")) == 0 {
+				rc.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
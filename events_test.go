@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseConsensus serves one head event and then holds the stream open,
+// signalling on closed once the proxy drops the upstream connection.
+func sseConsensus(t *testing.T, closed chan<- struct{}) string {
+	t.Helper()
+	return newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/eth/v1/events" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: head\ndata: {\"slot\":\"10\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+		closed <- struct{}{}
+	}).URL
+}
+
+// openHeadStream connects to the proxy's head stream and reads the first
+// event.
+func openHeadStream(t *testing.T, ctx context.Context, proxyURL string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, proxyURL+"/api/v1/events/head", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("status %d, Content-Type %q", resp.StatusCode, ct)
+	}
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "event: head") {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+	return resp
+}
+
+func TestHeadEventsClosedOnClientDisconnect(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	d := newTestDeps(t, newTestConfig(t), newTestServer(t, http.NotFound).URL, sseConsensus(t, closed))
+	proxy := httptest.NewServer(buildRouter(d))
+	defer proxy.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resp := openHeadStream(t, ctx, proxy.URL)
+	cancel()
+	resp.Body.Close()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream event stream still open after the client left")
+	}
+}
+
+func TestHeadEventsClosedOnShutdown(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	d := newTestDeps(t, newTestConfig(t), newTestServer(t, http.NotFound).URL, sseConsensus(t, closed))
+	shutdown, stop := context.WithCancel(context.Background())
+	d.shutdown = shutdown
+	proxy := httptest.NewServer(buildRouter(d))
+	defer proxy.Close()
+
+	resp := openHeadStream(t, context.Background(), proxy.URL)
+	defer resp.Body.Close()
+	stop()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream event stream still open after shutdown")
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatalf("client stream not ended cleanly: %v", err)
+	}
+}
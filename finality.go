@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FinalityCache reuses the consensus node's finalized checkpoint for a short
+// TTL, so slot responses can be checked against finality without a
+// consensus request each.
+type FinalityCache struct {
+	client    *http.Client
+	consensus *ConsensusNodes
+	ttl       time.Duration
+
+	// mu is held across the lookup so concurrent callers wait for it
+	// instead of issuing their own.
+	mu      sync.Mutex
+	epoch   uint64
+	fetched time.Time
+
+	hits, misses atomic.Uint64
+}
+
+func NewFinalityCache(client *http.Client, consensus *ConsensusNodes, ttl time.Duration) *FinalityCache {
+	return &FinalityCache{client: client, consensus: consensus, ttl: ttl}
+}
+
+// FinalizedEpoch returns the head state's finalized checkpoint epoch,
+// reusing a result younger than the TTL. Failures are not cached.
+func (f *FinalityCache) FinalizedEpoch(ctx context.Context) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.fetched.IsZero() && time.Since(f.fetched) < f.ttl {
+		f.hits.Add(1)
+		return f.epoch, nil
+	}
+	f.misses.Add(1)
+	epoch, err := fetchFinalizedEpoch(ctx, f.client, f.consensus)
+	if err != nil {
+		return 0, err
+	}
+	f.epoch, f.fetched = epoch, time.Now()
+	return epoch, nil
+}
+
+// Stats reports the finality cache TTL, the age of the cached checkpoint and
+// the hit rate.
+func (f *FinalityCache) Stats() cacheStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st := cacheStats{Name: "finality", TTLSeconds: f.ttl.Seconds(), Hits: f.hits.Load(), Misses: f.misses.Load()}
+	if !f.fetched.IsZero() && time.Since(f.fetched) < f.ttl {
+		st.Entries = 1
+		st.setAges(time.Now(), f.fetched, f.fetched)
+	}
+	return st
+}
+
+// fetchFinalizedEpoch reads the finalized checkpoint epoch of the head state.
+func fetchFinalizedEpoch(ctx context.Context, client *http.Client, consensus *ConsensusNodes) (uint64, error) {
+	resp, err := consensus.Get(ctx, client, "/eth/v1/beacon/states/head/finality_checkpoints")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("finality checkpoints request returned status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Data struct {
+			Finalized struct {
+				Epoch interface{} `json:"epoch"`
+			} `json:"finalized"`
+		} `json:"data"`
+	}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return 0, err
+	}
+	epoch, ok := parseUint64FromInterface(payload.Data.Finalized.Epoch)
+	if !ok {
+		return 0, fmt.Errorf("finality checkpoints response has no finalized epoch")
+	}
+	return epoch, nil
+}
+
+// markFinalized sets a slot's status to "finalized" when the slot is at or
+// before the finalized checkpoint, i.e. the first slot of finalizedEpoch;
+// later slots of that epoch can still be reorged. Missed and orphaned slots
+// keep their status, and so do slots Dora already reports as finalized.
+func markFinalized(slot *SlotResponse, finalizedEpoch, slotsPerEpoch uint64) {
+	if slot.Slot > finalizedEpoch*slotsPerEpoch || slotFinalized(slot.Status) {
+		return
+	}
+	switch strings.ToLower(slot.Status) {
+	case "missed", "orphaned":
+		return
+	}
+	slot.Status = "finalized"
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMarkFinalized(t *testing.T) {
+	tests := []struct {
+		slot   uint64
+		status string
+		want   string
+	}{
+		{63, "Canonical", "finalized"},
+		{64, "Canonical", "finalized"}, // first slot of the finalized epoch
+		{65, "Canonical", "Canonical"},
+		{10, "Missed", "Missed"},
+		{10, "orphaned", "orphaned"},
+		{10, "Finalized", "Finalized"},
+	}
+	for _, tt := range tests {
+		var slot SlotResponse
+		slot.Slot, slot.Status = tt.slot, tt.status
+		markFinalized(&slot, 2, 32)
+		if slot.Status != tt.want {
+			t.Errorf("slot %d %q: status = %q, want %q", tt.slot, tt.status, slot.Status, tt.want)
+		}
+	}
+}
+
+// finalityConsensus answers finality checkpoints with epoch (a 500 while
+// fail is set) and counts the requests.
+func finalityConsensus(t *testing.T, epoch string, fail *atomic.Bool, calls *atomic.Int32) string {
+	return newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/eth/v1/beacon/states/head/finality_checkpoints" {
+			http.NotFound(w, req)
+			return
+		}
+		calls.Add(1)
+		if fail != nil && fail.Load() {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		jsonHandler(http.StatusOK, `{"data":{"finalized":{"epoch":"`+epoch+`","root":"0xf"}}}`)(w, req)
+	}).URL
+}
+
+func TestFinalityCache(t *testing.T) {
+	var fail atomic.Bool
+	var calls atomic.Int32
+	url := finalityConsensus(t, "9", &fail, &calls)
+	f := NewFinalityCache(http.DefaultClient, NewConsensusNodes([]string{url}), time.Minute)
+
+	fail.Store(true)
+	if _, err := f.FinalizedEpoch(context.Background()); err == nil {
+		t.Fatal("want an error from the failing node")
+	}
+	fail.Store(false)
+	for i := 0; i < 3; i++ {
+		epoch, err := f.FinalizedEpoch(context.Background())
+		if err != nil || epoch != 9 {
+			t.Fatalf("FinalizedEpoch = %d, %v; want 9", epoch, err)
+		}
+	}
+	// the failure is not cached; the later lookups share one fetch
+	if n := calls.Load(); n != 2 {
+		t.Errorf("%d consensus requests, want 2", n)
+	}
+	if st := f.Stats(); st.Hits != 2 || st.Misses != 2 || st.Entries != 1 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestSlotBelowFinalizedEpoch(t *testing.T) {
+	var calls atomic.Int32
+	consensus := finalityConsensus(t, "10", nil, &calls)
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		slot := req.URL.Path[len(req.URL.Path)-3:]
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":`+slot+`,"epoch":0,"status":"Canonical"}}`)(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, consensus))
+
+	for slot, want := range map[string]string{"100": "finalized", "400": "Canonical"} {
+		data, _ := decodeJSON(t, serve(h, http.MethodGet, "/api/v1/slot/"+slot, ""))["data"].(map[string]interface{})
+		if data["status"] != want {
+			t.Errorf("slot %s: status = %v, want %q", slot, data["status"], want)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d finality requests, want 1 reused by both slots", n)
+	}
+}
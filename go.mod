@@ -5,6 +5,7 @@ go 1.23.7
 require (
 	github.com/gorilla/mux v1.8.1
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/sync v0.10.0
 )
 
 require golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
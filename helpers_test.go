@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestConfig returns the config loaded without PROXY_* environment, with
+// mainnet slot timing as resolveSlotTiming would set it.
+func newTestConfig(t *testing.T) *proxyConfig {
+	t.Helper()
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	cfg.SlotsPerEpoch = defaultSlotsPerEpoch
+	cfg.SecondsPerSlot = defaultSecondsPerSlot
+	return cfg
+}
+
+func newTestLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// newTestDeps wires router dependencies to a Dora upstream at doraURL (the
+// API prefix is applied as loadConfig does) and the consensus node at
+// consensusURL. An empty consensusURL gets a node answering 404 to
+// everything. The tracker is not started.
+func newTestDeps(t *testing.T, cfg *proxyConfig, doraURL, consensusURL string) *routerDeps {
+	t.Helper()
+	if consensusURL == "" {
+		consensusURL = newTestServer(t, http.NotFound).URL
+	}
+	cfg.UpstreamBaseURL = applyAPIPrefix(doraURL, cfg.UpstreamAPIPrefix)
+	cfg.ConsensusAPIURLs = []string{consensusURL}
+	upstream, err := url.Parse(cfg.UpstreamBaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := newTestLogger()
+	client := newProxyClient(cfg, log)
+	consensus := NewConsensusNodes(cfg.ConsensusAPIURLs)
+	cache := NewLastAttestCache()
+	return &routerDeps{
+		cfg:       cfg,
+		client:    client,
+		consensus: consensus,
+		upstream:  upstream,
+		cache:     cache,
+		tracker:   NewAttestationTracker(client, consensus, cfg, cache, log),
+		network:   NewNetworkInfo(),
+		startedAt: time.Now(),
+		log:       log,
+		shutdown:  context.Background(),
+	}
+}
+
+// newTestServer starts an HTTP server for h, closed when the test ends.
+func newTestServer(t *testing.T, h http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// jsonHandler answers every request with status and body as JSON.
+func jsonHandler(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+// serve sends a request through h and returns the recorded response.
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, rd)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeJSON decodes a response body into a generic value, failing the test
+// when it is not JSON.
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatalf("response is not a JSON object: %v: %s", err, rec.Body.String())
+	}
+	return m
+}
+
+// setHeadSlot records slot as the latest head seen by tr.
+func setHeadSlot(tr *AttestationTracker, slot uint64) {
+	tr.mu.Lock()
+	tr.headSlot = slot
+	tr.mu.Unlock()
+}
+
+// newTestTracker returns a tracker scanning the consensus node at
+// consensusURL with the scanner client.
+func newTestTracker(t *testing.T, cfg *proxyConfig, consensusURL string) *AttestationTracker {
+	t.Helper()
+	log := newTestLogger()
+	return NewAttestationTracker(newScannerClient(cfg, log), NewConsensusNodes([]string{consensusURL}), cfg, NewLastAttestCache(), log)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net"
+	"os"
+	"strings"
+)
+
+const unixAddrPrefix = "unix:"
+
+// listen opens the proxy listener. addr is a TCP host:port (IPv6 hosts in
+// brackets, e.g. [::1]:8081) or unix:/path/to.sock. A socket file left
+// behind by an earlier run is replaced. The returned cleanup removes the
+// socket file once the server is done; it is a no-op for TCP.
+func listen(addr string) (net.Listener, func(), error) {
+	path, ok := strings.CutPrefix(addr, unixAddrPrefix)
+	if !ok {
+		ln, err := net.Listen("tcp", addr)
+		return ln, func() {}, err
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ln, func() { os.Remove(path) }, nil
+}
@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"flag"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
+	startedAt := time.Now()
 	log := logrus.New()
 	log.SetLevel(logrus.InfoLevel)
     log.SetFormatter(&logrus.TextFormatter{
@@ -17,7 +24,18 @@ func main() {
         TimestampFormat: "2006-01-02 15:04:05",
     })
 
+	log.WithFields(logrus.Fields{"version": Version, "commit": Commit, "build_time": BuildTime}).Info("dora-proxy starting")
+
+	checkOnly := flag.Bool("check-config", false, "validate the config, probe Dora and the consensus node, then exit")
+	flag.Parse()
+
 	cfg, err := loadConfig()
+	if *checkOnly || os.Getenv("PROXY_CHECK_CONFIG") == "1" {
+		if !checkConfig(cfg, err, os.Stdout) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
@@ -27,13 +45,56 @@ func main() {
 		log.Fatalf("invalid PROXY_UPSTREAM_BASE_URL: %v", err)
 	}
 
-	client := &http.Client{Timeout: 20 * time.Second}
+	client := newProxyClient(cfg, log)
+	scannerClient := newScannerClient(cfg, log)
+	consensus := NewConsensusNodes(cfg.ConsensusAPIURLs)
+
+	// Detect network parameters (best-effort; slot responses omit the fork and
+	// the slot timing falls back to mainnet values if this fails)
+	network := NewNetworkInfo()
+	specCtx, specCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := network.Load(specCtx, scannerClient, consensus); err != nil {
+		log.WithError(err).Warn("failed to load consensus spec")
+	} else {
+		log.WithField("forks", network.ForkSchedule()).Info("detected fork schedule")
+	}
+	if err := network.LoadGenesis(specCtx, scannerClient, consensus); err != nil {
+		log.WithError(err).Warn("failed to load genesis time")
+	}
+	specCancel()
+	resolveSlotTiming(cfg, network)
+	log.WithFields(logrus.Fields{"slots_per_epoch": cfg.SlotsPerEpoch, "seconds_per_slot": cfg.SecondsPerSlot}).Info("slot timing")
 
 	// Initialize attestation cache and tracker
 	cache := NewLastAttestCache()
-	tracker := NewAttestationTracker(client, cfg.ConsensusAPIURL, cache, log)
+	tracker := NewAttestationTracker(scannerClient, consensus, cfg, cache, log)
+	committees := tracker.Committees()
+	persistCommittees := committees != nil && cfg.CommitteeCacheFile != ""
+	// The file is tied to this chain's genesis validators root, so another
+	// network's committees are never loaded
+	saveCommittees := func() error {
+		genesisRoot, _ := network.GenesisRoot()
+		return committees.Save(cfg.CommitteeCacheFile, genesisRoot)
+	}
+	if persistCommittees {
+		genesisRoot, _ := network.GenesisRoot()
+		if n, err := committees.Load(cfg.CommitteeCacheFile, genesisRoot); err != nil {
+			log.WithError(err).Warn("failed to load committee cache")
+		} else {
+			log.WithField("slots", n).Info("loaded committee cache")
+		}
+		go func() {
+			ticker := time.NewTicker(time.Duration(cfg.SecondsPerSlot*cfg.SlotsPerEpoch) * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := saveCommittees(); err != nil {
+					log.WithError(err).Warn("failed to save committee cache")
+				}
+			}
+		}()
+	}
 	// Kick off startup backfill (best-effort) and periodic epoch scans
-	go func() {
+	backfill := func() {
 		log.Info("starting attestation backfill (last 3 epochs)")
 		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 		if err := tracker.Backfill(ctx); err != nil {
@@ -41,21 +102,83 @@ func main() {
 		}
 		cancel()
 		log.Info("attestation backfill finished")
+	}
+	if cfg.ConcurrentWarmup {
+		// Slot claims keep the live scanner and backfill from scanning the
+		// same slot twice while both run.
+		tracker.BeginWarmup()
+	}
+	go func() {
+		backfill()
+		tracker.EndWarmup()
 	}()
 	tracker.Start()
 
-	r := buildRouter(cfg, client, upstream, cache)
+	var alerter *OfflineAlerter
+	if cfg.AlertWebhookURL != "" {
+		alerter = NewOfflineAlerter(scannerClient, cfg, cache, tracker, log)
+		alerter.Start()
+	}
+
+	var pubkeys *PubkeyResolver
+	if cfg.ResolvePubkeys {
+		pubkeys = NewPubkeyResolver(client, consensus)
+	}
 
+	// ctx ends on SIGINT/SIGTERM, closing long-lived streams at once. Other
+	// requests run on baseCtx, which is only canceled if they outlast the
+	// shutdown grace period.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
+	r := buildRouter(&routerDeps{
+		cfg:       cfg,
+		client:    client,
+		consensus: consensus,
+		upstream:  upstream,
+		cache:     cache,
+		tracker:   tracker,
+		network:   network,
+		alerter:   alerter,
+		pubkeys:   pubkeys,
+		startedAt: startedAt,
+		log:       log,
+		shutdown:  ctx,
+	})
+
+	inflight := newInflightRequests()
 	srv := &http.Server{
-		Addr:         cfg.ListenAddr,
-		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Handler:      inflight.middleware(r),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
 	}
 
-	log.Infof("dora-proxy listening on %s, upstream=%s, consensus_api=%s", cfg.ListenAddr, cfg.UpstreamBaseURL, cfg.ConsensusAPIURL)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		log.Info("shutting down")
+		shutdownServer(srv, inflight, cfg.ShutdownTimeout, cancelBase, log)
+	}()
+
+	ln, cleanup, err := listen(cfg.ListenAddr)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", cfg.ListenAddr, err)
+	}
+	log.Infof("dora-proxy listening on %s, upstream=%s, consensus_api=%s", cfg.ListenAddr, cfg.UpstreamBaseURL, strings.Join(cfg.ConsensusAPIURLs, ","))
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		cleanup()
 		log.Fatalf("proxy server error: %v", err)
 	}
+	<-shutdownDone
+	cleanup()
+	if persistCommittees {
+		if err := saveCommittees(); err != nil {
+			log.WithError(err).Warn("failed to save committee cache")
+		}
+	}
 }
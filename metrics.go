@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A minimal Prometheus text-format registry, enough for the handful of
+// counters, gauges and histograms the proxy exports.
+
+type collector interface {
+	write(b *strings.Builder)
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// defaultRegistry holds every metric exported on /metrics.
+var defaultRegistry = NewRegistry()
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// Handler serves all registered metrics in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		cs := append([]collector(nil), r.collectors...)
+		r.mu.Unlock()
+		var b strings.Builder
+		for _, c := range cs {
+			c.write(&b)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write([]byte(b.String()))
+	})
+}
+
+func writeHeader(b *strings.Builder, name, help, typ string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	name, help string
+	v          atomic.Uint64
+}
+
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.register(c)
+	return c
+}
+
+func (c *Counter) Inc()          { c.v.Add(1) }
+func (c *Counter) Add(n uint64)  { c.v.Add(n) }
+func (c *Counter) Value() uint64 { return c.v.Load() }
+
+func (c *Counter) write(b *strings.Builder) {
+	writeHeader(b, c.name, c.help, "counter")
+	fmt.Fprintf(b, "%s %d\n", c.name, c.Value())
+}
+
+// CounterVec is a family of counters sharing a name, partitioned by label
+// values.
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	series map[string]*Counter // by rendered label set
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{name: name, help: help, labels: labels, series: make(map[string]*Counter)}
+	r.register(v)
+	return v
+}
+
+// With returns the counter for the given label values, in the order the
+// labels were declared.
+func (v *CounterVec) With(values ...string) *Counter {
+	parts := make([]string, len(v.labels))
+	for i, l := range v.labels {
+		val := ""
+		if i < len(values) {
+			val = values[i]
+		}
+		parts[i] = fmt.Sprintf("%s=%q", l, val)
+	}
+	key := "{" + strings.Join(parts, ",") + "}"
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.series[key]
+	if !ok {
+		c = &Counter{name: v.name, help: v.help}
+		v.series[key] = c
+	}
+	return c
+}
+
+func (v *CounterVec) write(b *strings.Builder) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	cs := make([]*Counter, len(keys))
+	for i, k := range keys {
+		cs[i] = v.series[k]
+	}
+	v.mu.Unlock()
+	writeHeader(b, v.name, v.help, "counter")
+	for i, c := range cs {
+		fmt.Fprintf(b, "%s%s %d\n", v.name, keys[i], c.Value())
+	}
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	name, help string
+	bits       atomic.Uint64
+}
+
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) Set(v float64)  { g.bits.Store(math.Float64bits(v)) }
+func (g *Gauge) Value() float64 { return math.Float64frombits(g.bits.Load()) }
+
+func (g *Gauge) write(b *strings.Builder) {
+	writeHeader(b, g.name, g.help, "gauge")
+	fmt.Fprintf(b, "%s %s\n", g.name, formatFloat(g.Value()))
+}
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	name, help string
+	buckets    []float64 // sorted upper bounds, +Inf implied
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, non-cumulative; last is +Inf
+	sum    float64
+	count  uint64
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := newHistogram(name, help, buckets)
+	r.register(h)
+	return h
+}
+
+func newHistogram(name, help string, buckets []float64) *Histogram {
+	bs := append([]float64(nil), buckets...)
+	sort.Float64s(bs)
+	return &Histogram{name: name, help: help, buckets: bs, counts: make([]uint64, len(bs)+1)}
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v) // first bucket with bound >= v
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func (h *Histogram) write(b *strings.Builder) {
+	writeHeader(b, h.name, h.help, "histogram")
+	h.writeSeries(b, "")
+}
+
+// writeSeries writes bucket, sum and count lines; labels is either empty or
+// a rendered `k="v",` prefix.
+func (h *Histogram) writeSeries(b *strings.Builder, labels string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var cum uint64
+	for i, bound := range h.buckets {
+		cum += h.counts[i]
+		fmt.Fprintf(b, "%s_bucket{%sle=\"%s\"} %d\n", h.name, labels, formatFloat(bound), cum)
+	}
+	cum += h.counts[len(h.buckets)]
+	fmt.Fprintf(b, "%s_bucket{%sle=\"+Inf\"} %d\n", h.name, labels, cum)
+	braces := ""
+	if labels != "" {
+		braces = "{" + strings.TrimSuffix(labels, ",") + "}"
+	}
+	fmt.Fprintf(b, "%s_sum%s %s\n", h.name, braces, formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count%s %d\n", h.name, braces, h.count)
+}
+
+// HistogramVec is a family of histograms sharing a name and buckets,
+// partitioned by label values.
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*Histogram // by rendered label prefix
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*Histogram)}
+	r.register(v)
+	return v
+}
+
+// With returns the histogram for the given label values, in the order the
+// labels were declared.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	var b strings.Builder
+	for i, l := range v.labels {
+		val := ""
+		if i < len(values) {
+			val = values[i]
+		}
+		fmt.Fprintf(&b, "%s=%q,", l, val)
+	}
+	key := b.String()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.series[key]
+	if !ok {
+		h = newHistogram(v.name, v.help, v.buckets)
+		v.series[key] = h
+	}
+	return h
+}
+
+func (v *HistogramVec) write(b *strings.Builder) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	hs := make([]*Histogram, len(keys))
+	for i, k := range keys {
+		hs[i] = v.series[k]
+	}
+	v.mu.Unlock()
+	writeHeader(b, v.name, v.help, "histogram")
+	for i, h := range hs {
+		h.writeSeries(b, keys[i])
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	corsAllowMethods = "GET, POST, DELETE, OPTIONS"
+	corsAllowHeaders = "Content-Type, Authorization, X-API-Key"
+)
+
+// corsMiddleware adds CORS headers for the configured origins and answers
+// preflight requests directly. An empty origin list disables CORS handling.
+func corsMiddleware(origins []string) func(http.Handler) http.Handler {
+	allowAll := false
+	allowed := make(map[string]struct{}, len(origins))
+	for _, o := range origins {
+		if o == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimRight(o, "/")] = struct{}{}
+	}
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			origin := req.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, req)
+				return
+			}
+			h := w.Header()
+			if allowAll {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else if _, ok := allowed[origin]; ok {
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Add("Vary", "Origin")
+			} else {
+				next.ServeHTTP(w, req)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			if reqHeaders := req.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			} else {
+				h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			}
+
+			// Preflight: answer without hitting the routes
+			if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// authExemptPaths are reachable without an API key so orchestrators can probe
+// the proxy.
+var authExemptPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// readOnlyMiddleware answers 405 to any request that could change state
+// upstream, i.e. anything but GET, HEAD and OPTIONS.
+func readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, req)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+			writeError(w, http.StatusMethodNotAllowed, "proxy is read-only: only GET requests are served")
+		}
+	})
+}
+
+// apiKeyMiddleware requires a matching X-API-Key or Authorization: Bearer
+// header on every request. An empty key disables authentication.
+func apiKeyMiddleware(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if key == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if authExemptPaths[req.URL.Path] || validAPIKey(req, key) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dora-proxy"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+		})
+	}
+}
+
+func validAPIKey(req *http.Request, key string) bool {
+	got := req.Header.Get("X-API-Key")
+	if got == "" {
+		if auth := req.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+			got = strings.TrimSpace(auth[7:])
+		}
+	}
+	if got == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(key)) == 1
+}
+
+// clientIP returns the address identifying the client. With trustXFF the
+// left-most X-Forwarded-For entry is used, which is only safe behind a proxy
+// that sets the header.
+func clientIP(req *http.Request, trustXFF bool) string {
+	if trustXFF {
+		if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
+			first, _, _ := strings.Cut(xff, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// loggingMiddleware logs each request at debug level and reports the time
+// spent before the response started in an X-Proxy-Duration-Ms header.
+func loggingMiddleware(log logrus.FieldLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			tw := &timingWriter{ResponseWriter: w, started: time.Now()}
+			next.ServeHTTP(tw, req)
+			if tw.status == 0 {
+				tw.WriteHeader(http.StatusOK)
+			}
+			log.WithFields(logrus.Fields{
+				"method":      req.Method,
+				"path":        req.URL.Path,
+				"status":      tw.status,
+				"duration_ms": time.Since(tw.started).Milliseconds(),
+			}).Debug("request served")
+		})
+	}
+}
+
+// timingWriter sets X-Proxy-Duration-Ms when the handler writes the header,
+// so it goes out ahead of the body.
+type timingWriter struct {
+	http.ResponseWriter
+	started time.Time
+	status  int
+}
+
+func (tw *timingWriter) WriteHeader(code int) {
+	if tw.status != 0 {
+		return
+	}
+	tw.status = code
+	tw.Header().Set("X-Proxy-Duration-Ms", strconv.FormatInt(time.Since(tw.started).Milliseconds(), 10))
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timingWriter) Write(b []byte) (int, error) {
+	if tw.status == 0 {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush event streams.
+func (tw *timingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// inflightRequests tracks the requests being served, so a shutdown that runs
+// out of time can report which ones it cut off.
+type inflightRequests struct {
+	mu     sync.Mutex
+	nextID uint64
+	reqs   map[uint64]inflightRequest
+}
+
+type inflightRequest struct {
+	method, path string
+	started      time.Time
+}
+
+func newInflightRequests() *inflightRequests {
+	return &inflightRequests{reqs: make(map[uint64]inflightRequest)}
+}
+
+func (f *inflightRequests) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		f.mu.Lock()
+		f.nextID++
+		id := f.nextID
+		f.reqs[id] = inflightRequest{method: req.Method, path: req.URL.Path, started: time.Now()}
+		f.mu.Unlock()
+		defer func() {
+			f.mu.Lock()
+			delete(f.reqs, id)
+			f.mu.Unlock()
+		}()
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Snapshot returns the requests currently in flight, oldest first.
+func (f *inflightRequests) Snapshot() []inflightRequest {
+	f.mu.Lock()
+	out := make([]inflightRequest, 0, len(f.reqs))
+	for _, r := range f.reqs {
+		out = append(out, r)
+	}
+	f.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].started.Before(out[j].started) })
+	return out
+}
+
+// shutdownServer shuts srv down, giving in-flight requests up to timeout to
+// finish. Requests still running then are logged and dropped: cancelBase
+// cancels their contexts and srv is closed. It returns the Shutdown error,
+// nil when everything finished in time.
+func shutdownServer(srv *http.Server, inflight *inflightRequests, timeout time.Duration, cancelBase context.CancelFunc, log *logrus.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		// Out of time: drop what is still running
+		for _, r := range inflight.Snapshot() {
+			log.WithFields(logrus.Fields{"method": r.method, "path": r.path, "running": time.Since(r.started).Round(time.Millisecond)}).Warn("dropping in-flight request")
+		}
+		cancelBase()
+		srv.Close()
+		log.WithError(err).Warn("graceful shutdown incomplete")
+	}
+	return err
+}
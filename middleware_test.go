@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// okHandler answers 200 and counts its calls.
+func okHandler(calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		*calls++
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name       string
+		origins    []string
+		origin     string
+		wantAllow  string
+		wantStatus int
+		wantNext   bool
+	}{
+		{"allowed origin", []string{"https://dash.example.org"}, "https://dash.example.org", "https://dash.example.org", http.StatusNoContent, false},
+		{"allowed with trailing slash in config", []string{"https://dash.example.org/"}, "https://dash.example.org", "https://dash.example.org", http.StatusNoContent, false},
+		{"other origin", []string{"https://dash.example.org"}, "https://evil.example.org", "", http.StatusOK, true},
+		{"any origin", []string{"*"}, "https://evil.example.org", "*", http.StatusNoContent, false},
+		{"disabled", nil, "https://dash.example.org", "", http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			h := corsMiddleware(tt.origins)(okHandler(&calls))
+			req := httptest.NewRequest(http.MethodOptions, "/api/v1/validator", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllow)
+			}
+			if (calls > 0) != tt.wantNext {
+				t.Errorf("handler called %d times, want called = %v", calls, tt.wantNext)
+			}
+			if tt.wantAllow != "" && rec.Header().Get("Access-Control-Allow-Methods") == "" {
+				t.Error("Access-Control-Allow-Methods missing")
+			}
+			if tt.wantAllow != "" && tt.wantAllow != "*" && rec.Header().Get("Vary") != "Origin" {
+				t.Errorf("Vary = %q, want Origin", rec.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	calls := 0
+	h := corsMiddleware([]string{"https://dash.example.org"})(okHandler(&calls))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil)
+	req.Header.Set("Origin", "https://dash.example.org")
+	req.Header.Set("Access-Control-Request-Headers", "X-Custom")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if calls != 1 || rec.Code != http.StatusOK {
+		t.Fatalf("handler calls = %d, status %d; want the request served", calls, rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://dash.example.org" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "X-Custom" {
+		t.Errorf("Access-Control-Allow-Headers = %q, want the requested headers", got)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"missing", "/api/v1/epoch/latest", "", "", http.StatusUnauthorized},
+		{"wrong key", "/api/v1/epoch/latest", "X-API-Key", "nope", http.StatusUnauthorized},
+		{"wrong bearer", "/api/v1/epoch/latest", "Authorization", "Bearer nope", http.StatusUnauthorized},
+		{"basic auth", "/api/v1/epoch/latest", "Authorization", "Basic c2VjcmV0", http.StatusUnauthorized},
+		{"correct key", "/api/v1/epoch/latest", "X-API-Key", "secret", http.StatusOK},
+		{"correct bearer", "/api/v1/epoch/latest", "Authorization", "bearer secret", http.StatusOK},
+		{"health probe", "/healthz", "", "", http.StatusOK},
+		{"readiness probe", "/readyz", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			h := apiKeyMiddleware("secret")(okHandler(&calls))
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized {
+				if calls != 0 {
+					t.Error("rejected request reached the handler")
+				}
+				if rec.Header().Get("WWW-Authenticate") == "" {
+					t.Error("WWW-Authenticate missing on 401")
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyDisabled(t *testing.T) {
+	calls := 0
+	rec := httptest.NewRecorder()
+	apiKeyMiddleware("")(okHandler(&calls)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil))
+	if rec.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("status = %d, calls = %d; want requests served without a key", rec.Code, calls)
+	}
+}
+
+// startShutdownServer serves h behind the in-flight tracker on a local port,
+// with request contexts derived from baseCtx.
+func startShutdownServer(t *testing.T, h http.HandlerFunc, baseCtx context.Context) (*http.Server, *inflightRequests, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflight := newInflightRequests()
+	srv := &http.Server{
+		Handler:     inflight.middleware(h),
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return srv, inflight, "http://" + ln.Addr().String()
+}
+
+func TestShutdownTimeoutDropsSlowRequests(t *testing.T) {
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	started, dropped := make(chan struct{}), make(chan struct{})
+	srv, inflight, url := startShutdownServer(t, func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		select {
+		case <-req.Context().Done():
+			close(dropped)
+		case <-time.After(10 * time.Second):
+		}
+	}, baseCtx)
+	go http.Get(url + "/slow")
+	<-started
+
+	var logs bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&logs)
+	begin := time.Now()
+	if err := shutdownServer(srv, inflight, 100*time.Millisecond, cancelBase, log); err == nil {
+		t.Fatal("shutdown reported success with a request still running")
+	}
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Errorf("shutdown took %v with a 100ms timeout", elapsed)
+	}
+	select {
+	case <-dropped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow request's context not canceled")
+	}
+	if !strings.Contains(logs.String(), "dropping in-flight request") || !strings.Contains(logs.String(), "/slow") {
+		t.Errorf("dropped request not logged:\n%s", logs.String())
+	}
+}
+
+func TestShutdownWaitsForRequestsWithinTimeout(t *testing.T) {
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+	started := make(chan struct{})
+	srv, inflight, url := startShutdownServer(t, func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}, baseCtx)
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	if err := shutdownServer(srv, inflight, 5*time.Second, cancelBase, newTestLogger()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if baseCtx.Err() != nil {
+		t.Error("request contexts canceled after a graceful shutdown")
+	}
+	if got := <-status; got != http.StatusOK {
+		t.Errorf("in-flight request got status %d, want 200", got)
+	}
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	calls := 0
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"epoch":3}}`)(w, req)
+	})
+	cfg := newTestConfig(t)
+	cfg.ReadOnly = true
+	h := buildRouter(newTestDeps(t, cfg, dora.URL, ""))
+
+	rec := serve(h, http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"1"}`)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status = %d, want 405", rec.Code)
+	}
+	if m := decodeJSON(t, rec); m["status"] != "error" {
+		t.Errorf("POST body = %s, want an error envelope", rec.Body.String())
+	}
+	if calls != 0 {
+		t.Errorf("POST reached upstream %d times in read-only mode", calls)
+	}
+	if rec := serve(h, http.MethodDelete, "/api/v1/internal/cache", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE status = %d, want 405", rec.Code)
+	}
+
+	if rec := serve(h, http.MethodGet, "/api/v1/epoch/latest", ""); rec.Code != http.StatusOK || calls != 1 {
+		t.Errorf("GET status = %d with %d upstream calls, want 200 with 1", rec.Code, calls)
+	}
+}
+
+func TestProxyDurationHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"write only", func(w http.ResponseWriter, req *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte("ok"))
+		}},
+		{"explicit status", func(w http.ResponseWriter, req *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusNotFound)
+		}},
+		{"no output", func(w http.ResponseWriter, req *http.Request) {}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			loggingMiddleware(newTestLogger())(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			v := rec.Result().Header.Get("X-Proxy-Duration-Ms")
+			ms, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || ms < 0 {
+				t.Fatalf("X-Proxy-Duration-Ms = %q, want a non-negative number", v)
+			}
+			if tt.name != "no output" && ms < 20 {
+				t.Errorf("X-Proxy-Duration-Ms = %d, want at least the handler's 20ms", ms)
+			}
+		})
+	}
+
+	// and on real routes
+	h := buildRouter(newTestDeps(t, newTestConfig(t), "http://127.0.0.1:1", ""))
+	if v := serve(h, http.MethodGet, "/healthz", "").Result().Header.Get("X-Proxy-Duration-Ms"); v == "" {
+		t.Error("X-Proxy-Duration-Ms missing on /healthz")
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ForkEpoch is a named fork and the epoch it activates at.
+type ForkEpoch struct {
+	Name  string `json:"name"`
+	Epoch uint64 `json:"epoch"`
+}
+
+// NetworkInfo holds network parameters detected from the consensus node at
+// startup.
+type NetworkInfo struct {
+	mu    sync.RWMutex
+	forks []ForkEpoch // sorted by activation epoch
+
+	// Slot timing from the spec, zero when not reported
+	slotsPerEpoch  uint64
+	secondsPerSlot uint64
+
+	genesis     time.Time // zero until fetched; immutable once known
+	genesisRoot string    // genesis_validators_root, set with genesis
+}
+
+func NewNetworkInfo() *NetworkInfo {
+	return &NetworkInfo{}
+}
+
+// Load fetches the consensus spec and derives the fork schedule from it.
+func (n *NetworkInfo) Load(ctx context.Context, client *http.Client, consensus *ConsensusNodes) error {
+	spec, err := fetchSpec(ctx, client, consensus)
+	if err != nil {
+		return err
+	}
+	forks := forkScheduleFromSpec(spec)
+	slotsPerEpoch, _ := strconv.ParseUint(spec["SLOTS_PER_EPOCH"], 10, 64)
+	secondsPerSlot, _ := strconv.ParseUint(spec["SECONDS_PER_SLOT"], 10, 64)
+	n.mu.Lock()
+	n.forks = forks
+	n.slotsPerEpoch = slotsPerEpoch
+	n.secondsPerSlot = secondsPerSlot
+	n.mu.Unlock()
+	return nil
+}
+
+// SlotTiming returns SLOTS_PER_EPOCH and SECONDS_PER_SLOT from the spec; each
+// is zero if the spec did not provide it.
+func (n *NetworkInfo) SlotTiming() (slotsPerEpoch, secondsPerSlot uint64) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.slotsPerEpoch, n.secondsPerSlot
+}
+
+// resolveSlotTiming fills the slot timing not set in cfg from the detected
+// spec, falling back to the mainnet values.
+func resolveSlotTiming(cfg *proxyConfig, n *NetworkInfo) {
+	specSlots, specSeconds := n.SlotTiming()
+	if cfg.SlotsPerEpoch == 0 {
+		cfg.SlotsPerEpoch = specSlots
+	}
+	if cfg.SlotsPerEpoch == 0 {
+		cfg.SlotsPerEpoch = defaultSlotsPerEpoch
+	}
+	if cfg.SecondsPerSlot == 0 {
+		cfg.SecondsPerSlot = specSeconds
+	}
+	if cfg.SecondsPerSlot == 0 {
+		cfg.SecondsPerSlot = defaultSecondsPerSlot
+	}
+}
+
+// LoadGenesis fetches the chain's genesis time and validators root, unless
+// already known.
+func (n *NetworkInfo) LoadGenesis(ctx context.Context, client *http.Client, consensus *ConsensusNodes) error {
+	if _, ok := n.Genesis(); ok {
+		return nil
+	}
+	resp, err := consensus.Get(ctx, client, "/eth/v1/beacon/genesis")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("genesis request returned status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Data struct {
+			GenesisTime           string `json:"genesis_time"`
+			GenesisValidatorsRoot string `json:"genesis_validators_root"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return err
+	}
+	secs, err := strconv.ParseInt(payload.Data.GenesisTime, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid genesis_time %q", payload.Data.GenesisTime)
+	}
+	n.mu.Lock()
+	n.genesis = time.Unix(secs, 0).UTC()
+	n.genesisRoot = strings.ToLower(payload.Data.GenesisValidatorsRoot)
+	n.mu.Unlock()
+	return nil
+}
+
+// Genesis returns the chain's genesis time, once fetched.
+func (n *NetworkInfo) Genesis() (time.Time, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.genesis, !n.genesis.IsZero()
+}
+
+// GenesisRoot returns the chain's genesis validators root, which tells
+// networks apart, once fetched.
+func (n *NetworkInfo) GenesisRoot() (string, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.genesisRoot, n.genesisRoot != ""
+}
+
+// slotToTime returns the start time of slot.
+func slotToTime(genesis time.Time, slot, secondsPerSlot uint64) time.Time {
+	return genesis.Add(time.Duration(slot*secondsPerSlot) * time.Second)
+}
+
+// ForkSchedule returns a copy of the detected fork schedule.
+func (n *NetworkInfo) ForkSchedule() []ForkEpoch {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return append([]ForkEpoch(nil), n.forks...)
+}
+
+// ForkAt returns the name of the fork active at epoch, or "" if the schedule
+// is unknown.
+func (n *NetworkInfo) ForkAt(epoch uint64) string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	name := ""
+	for _, f := range n.forks {
+		if f.Epoch > epoch {
+			break
+		}
+		name = f.Name
+	}
+	return name
+}
+
+// fetchSpec returns the consensus /eth/v1/config/spec values as strings.
+func fetchSpec(ctx context.Context, client *http.Client, consensus *ConsensusNodes) (map[string]string, error) {
+	resp, err := consensus.Get(ctx, client, "/eth/v1/config/spec")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("spec request returned status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	spec := make(map[string]string, len(payload.Data))
+	for k, v := range payload.Data {
+		switch t := v.(type) {
+		case string:
+			spec[k] = t
+		case float64:
+			spec[k] = strconv.FormatFloat(t, 'f', -1, 64)
+		}
+	}
+	return spec, nil
+}
+
+// forkScheduleFromSpec collects every <NAME>_FORK_EPOCH entry, skipping forks
+// scheduled at the far-future epoch. Phase0 is always active from epoch 0.
+func forkScheduleFromSpec(spec map[string]string) []ForkEpoch {
+	forks := []ForkEpoch{{Name: "phase0", Epoch: 0}}
+	for k, v := range spec {
+		name, ok := strings.CutSuffix(k, "_FORK_EPOCH")
+		if !ok {
+			continue
+		}
+		epoch, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || epoch == math.MaxUint64 {
+			continue
+		}
+		forks = append(forks, ForkEpoch{Name: strings.ToLower(name), Epoch: epoch})
+	}
+	sort.SliceStable(forks, func(i, j int) bool {
+		if forks[i].Epoch != forks[j].Epoch {
+			return forks[i].Epoch < forks[j].Epoch
+		}
+		return forkOrder(forks[i].Name) < forkOrder(forks[j].Name)
+	})
+	return forks
+}
+
+// knownForks orders forks that may share an activation epoch (common on
+// devnets that start at a later fork).
+var knownForks = []string{"phase0", "altair", "bellatrix", "capella", "deneb", "electra", "fulu"}
+
+func forkOrder(name string) int {
+	for i, f := range knownForks {
+		if f == name {
+			return i
+		}
+	}
+	return len(knownForks)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestForkScheduleFromSpec(t *testing.T) {
+	spec := map[string]string{
+		"ALTAIR_FORK_EPOCH":    "74240",
+		"BELLATRIX_FORK_EPOCH": "144896",
+		"CAPELLA_FORK_EPOCH":   "194048",
+		"DENEB_FORK_EPOCH":     "269568",
+		"ELECTRA_FORK_EPOCH":   "18446744073709551615", // not scheduled
+		"ALTAIR_FORK_VERSION":  "0x01000000",
+		"SECONDS_PER_SLOT":     "12",
+	}
+	want := []ForkEpoch{
+		{"phase0", 0},
+		{"altair", 74240},
+		{"bellatrix", 144896},
+		{"capella", 194048},
+		{"deneb", 269568},
+	}
+	if got := forkScheduleFromSpec(spec); !reflect.DeepEqual(got, want) {
+		t.Fatalf("forkScheduleFromSpec = %v, want %v", got, want)
+	}
+}
+
+// Devnets often activate several forks at genesis; they stay in fork order.
+func TestForkScheduleFromSpecSameEpoch(t *testing.T) {
+	spec := map[string]string{
+		"DENEB_FORK_EPOCH":     "0",
+		"ALTAIR_FORK_EPOCH":    "0",
+		"CAPELLA_FORK_EPOCH":   "0",
+		"BELLATRIX_FORK_EPOCH": "0",
+		"ELECTRA_FORK_EPOCH":   "10",
+	}
+	var names []string
+	for _, f := range forkScheduleFromSpec(spec) {
+		names = append(names, f.Name)
+	}
+	want := []string{"phase0", "altair", "bellatrix", "capella", "deneb", "electra"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("fork order = %v, want %v", names, want)
+	}
+	n := &NetworkInfo{forks: forkScheduleFromSpec(spec)}
+	for epoch, want := range map[uint64]string{0: "deneb", 9: "deneb", 10: "electra", 1 << 40: "electra"} {
+		if got := n.ForkAt(epoch); got != want {
+			t.Errorf("ForkAt(%d) = %q, want %q", epoch, got, want)
+		}
+	}
+}
+
+func TestForkAtUnknownSchedule(t *testing.T) {
+	if got := NewNetworkInfo().ForkAt(100); got != "" {
+		t.Fatalf("ForkAt = %q, want empty while the schedule is unknown", got)
+	}
+}
+
+func TestForkScheduleExposedAndAnnotated(t *testing.T) {
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/eth/v1/config/spec":
+			jsonHandler(http.StatusOK, `{"data":{"ALTAIR_FORK_EPOCH":"5","BELLATRIX_FORK_EPOCH":"20","SLOTS_PER_EPOCH":"32"}}`)(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":320,"epoch":10}}`))
+	d := newTestDeps(t, newTestConfig(t), dora.URL, consensus.URL)
+	if err := d.network.Load(context.Background(), d.client, d.consensus); err != nil {
+		t.Fatal(err)
+	}
+	h := buildRouter(d)
+
+	rec := serve(h, http.MethodGet, "/api/v1/config", "")
+	schedule, _ := decodeJSON(t, rec)["fork_schedule"].([]interface{})
+	if len(schedule) != 3 {
+		t.Fatalf("fork_schedule = %v, want phase0, altair and bellatrix", schedule)
+	}
+
+	rec = serve(h, http.MethodGet, "/api/v1/slot/320", "")
+	data, _ := decodeJSON(t, rec)["data"].(map[string]interface{})
+	if data["fork"] != "altair" {
+		t.Fatalf("slot fork = %v, want altair", data["fork"])
+	}
+}
+
+func TestLoadGenesis(t *testing.T) {
+	calls := 0
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		jsonHandler(http.StatusOK, `{"data":{"genesis_time":"1606824023","genesis_validators_root":"0x4B363DB94E286120D76EB905340FDD4E54BFE9F06BF33FF6CF5AD27F511BFE95","genesis_fork_version":"0x00000000"}}`)(w, req)
+	})
+	n := NewNetworkInfo()
+	if _, ok := n.GenesisRoot(); ok {
+		t.Fatal("genesis root known before loading")
+	}
+	for i := 0; i < 2; i++ {
+		if err := n.LoadGenesis(context.Background(), http.DefaultClient, NewConsensusNodes([]string{consensus.URL})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("genesis fetched %d times, want once", calls)
+	}
+	if root, ok := n.GenesisRoot(); !ok || root != testNetwork {
+		t.Errorf("GenesisRoot = %q, %v; want the lowercased root", root, ok)
+	}
+	if g, _ := n.Genesis(); g.Unix() != 1606824023 {
+		t.Errorf("Genesis = %v", g)
+	}
+}
+
+func TestResolveSlotTiming(t *testing.T) {
+	consensus := newTestServer(t, jsonHandler(http.StatusOK, `{"data":{"SLOTS_PER_EPOCH":"8","SECONDS_PER_SLOT":6}}`))
+	n := NewNetworkInfo()
+	if err := n.Load(context.Background(), http.DefaultClient, NewConsensusNodes([]string{consensus.URL})); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &proxyConfig{}
+	resolveSlotTiming(cfg, n)
+	if cfg.SlotsPerEpoch != 8 || cfg.SecondsPerSlot != 6 {
+		t.Errorf("timing from spec = %d/%d, want 8/6", cfg.SlotsPerEpoch, cfg.SecondsPerSlot)
+	}
+
+	// configured values win over the spec
+	cfg = &proxyConfig{SlotsPerEpoch: 4}
+	resolveSlotTiming(cfg, n)
+	if cfg.SlotsPerEpoch != 4 || cfg.SecondsPerSlot != 6 {
+		t.Errorf("timing with SlotsPerEpoch set = %d/%d, want 4/6", cfg.SlotsPerEpoch, cfg.SecondsPerSlot)
+	}
+
+	// without a spec the mainnet values apply
+	cfg = &proxyConfig{}
+	resolveSlotTiming(cfg, NewNetworkInfo())
+	if cfg.SlotsPerEpoch != defaultSlotsPerEpoch || cfg.SecondsPerSlot != defaultSecondsPerSlot {
+		t.Errorf("fallback timing = %d/%d, want %d/%d", cfg.SlotsPerEpoch, cfg.SecondsPerSlot, defaultSlotsPerEpoch, defaultSecondsPerSlot)
+	}
+}
+
+// Epoch math follows the configured slots per epoch.
+func TestNonDefaultSlotsPerEpoch(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.SlotsPerEpoch = 8
+	d := newTestDeps(t, cfg, "http://127.0.0.1:1", "")
+	setHeadSlot(d.tracker, 17)
+	h := buildRouter(d)
+
+	m := decodeJSON(t, serve(h, http.MethodGet, "/api/v1/epoch/current", ""))
+	if m["epoch"] != float64(2) || m["slot_in_epoch"] != float64(1) {
+		t.Errorf("current epoch = %v, want epoch 2, slot_in_epoch 1", m)
+	}
+	slots, _ := decodeJSON(t, serve(h, http.MethodGet, "/api/v1/epoch/1/slots", ""))["slots"].([]interface{})
+	if len(slots) != 8 {
+		t.Fatalf("epoch 1 has %d slots, want 8", len(slots))
+	}
+	if first, _ := slots[0].(map[string]interface{}); first["slot"] != float64(8) {
+		t.Errorf("epoch 1 starts at %v, want slot 8", first["slot"])
+	}
+}
+
+func TestSlotToTime(t *testing.T) {
+	genesis := time.Unix(1606824023, 0).UTC()
+	if got := slotToTime(genesis, 0, 12); !got.Equal(genesis) {
+		t.Errorf("slot 0 = %v, want genesis %v", got, genesis)
+	}
+	if got := slotToTime(genesis, 1, 12); !got.Equal(genesis.Add(12 * time.Second)) {
+		t.Errorf("slot 1 = %v, want genesis+12s", got)
+	}
+	if got := slotToTime(genesis, 10, 6); !got.Equal(genesis.Add(time.Minute)) {
+		t.Errorf("slot 10 at 6s = %v, want genesis+1m", got)
+	}
+}
+
+func TestSlotResponseSlotTime(t *testing.T) {
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/eth/v1/beacon/genesis" {
+			http.NotFound(w, req)
+			return
+		}
+		jsonHandler(http.StatusOK, `{"data":{"genesis_time":"1606824023","genesis_validators_root":"0x01"}}`)(w, req)
+	})
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":1,"epoch":0}}`))
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, consensus.URL))
+	rec := serve(h, http.MethodGet, "/api/v1/slot/1", "")
+	data, _ := decodeJSON(t, rec)["data"].(map[string]interface{})
+	if data["slot_time"] != float64(1606824023+12) {
+		t.Fatalf("slot_time = %v, want genesis+12: %s", data["slot_time"], rec.Body.String())
+	}
+}
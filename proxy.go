@@ -1,37 +1,195 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
-// proxyJSON proxies the request to upstream and optionally transforms the JSON response.
-func proxyJSON(w http.ResponseWriter, req *http.Request, client *http.Client, upstream *url.URL, upstreamPath string, transform func(interface{})) {
-	// Build upstream request URL
-	u := *upstream
-	u.Path = strings.TrimRight(upstream.Path, "/") + upstreamPath
-	u.RawQuery = req.URL.RawQuery
+// UpstreamProxy forwards requests to the Dora upstream.
+type UpstreamProxy struct {
+	client       *http.Client
+	upstream     *url.URL
+	maxBodyBytes int64
+	maxRespBytes int64 // upstream bodies read by the proxy (0 = no limit)
+	maxAttempts  int
+	retryBackoff time.Duration
+	breaker      *CircuitBreaker // nil when disabled
+	strictJSON   bool
+	headers      headerFilter
+	forwarded    bool // add X-Forwarded-* headers to proxied requests
+	log          logrus.FieldLogger
+}
+
+func NewUpstreamProxy(client *http.Client, upstream *url.URL, cfg *proxyConfig, log logrus.FieldLogger) *UpstreamProxy {
+	attempts := cfg.UpstreamMaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	p := &UpstreamProxy{
+		client:       client,
+		upstream:     upstream,
+		maxBodyBytes: cfg.MaxRequestBodyBytes,
+		maxRespBytes: cfg.MaxResponseBytes,
+		maxAttempts:  attempts,
+		retryBackoff: cfg.UpstreamRetryBackoff,
+		strictJSON:   cfg.StrictJSON,
+		headers:      newHeaderFilter(cfg.StripHeaders, cfg.ForwardHeaders),
+		forwarded:    cfg.ForwardedHeaders,
+		log:          log,
+	}
+	if cfg.BreakerFailures > 0 {
+		p.breaker = NewCircuitBreaker(cfg.BreakerFailures, cfg.BreakerCooldown)
+	}
+	return p
+}
 
-	// Create the request
-	newReq, err := http.NewRequestWithContext(req.Context(), req.Method, u.String(), req.Body)
+// readBody buffers the inbound request body so it can be resent on retries.
+// It writes a 413 (over the size limit) or 400 error and returns false when
+// the body cannot be read.
+func (p *UpstreamProxy) readBody(w http.ResponseWriter, req *http.Request) ([]byte, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true
+	}
+	var r io.Reader = req.Body
+	if p.maxBodyBytes > 0 {
+		r = http.MaxBytesReader(w, req.Body, p.maxBodyBytes)
+	}
+	body, err := io.ReadAll(r)
+	req.Body.Close()
 	if err != nil {
-		http.Error(w, `{"status":"ERROR: failed to create upstream request"}`, http.StatusInternalServerError)
-		return
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else {
+			writeError(w, http.StatusBadRequest, "failed to read request body")
+		}
+		return nil, false
+	}
+	// Leave a replayable body behind for anyone reading it after us
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	return body, true
+}
+
+// do sends a request to upstreamPath, retrying transport errors and
+// 502/503/504 responses up to maxAttempts times. body is resent on each
+// attempt. When the circuit breaker is open it fails fast with errCircuitOpen.
+// A call ended by ctx is not held against upstream.
+func (p *UpstreamProxy) do(ctx context.Context, method, upstreamPath, rawQuery string, header http.Header, body []byte) (*http.Response, error) {
+	if p.breaker != nil && !p.breaker.Allow() {
+		return nil, errCircuitOpen
+	}
+	resp, err := p.doWithRetries(ctx, method, upstreamPath, rawQuery, header, body)
+	if p.breaker != nil {
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// The caller cancelled or ran out of time; that says nothing
+			// about upstream
+			p.breaker.Abandon()
+		case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+			p.breaker.Failure()
+		default:
+			p.breaker.Success()
+		}
+	}
+	return resp, err
+}
+
+func (p *UpstreamProxy) doWithRetries(ctx context.Context, method, upstreamPath, rawQuery string, header http.Header, body []byte) (*http.Response, error) {
+	// Build upstream request URL
+	u := *p.upstream
+	u.Path = strings.TrimRight(p.upstream.Path, "/") + upstreamPath
+	u.RawQuery = rawQuery
+
+	var lastErr error
+	for attempt := 1; attempt <= p.maxAttempts; attempt++ {
+		var rd io.Reader
+		if body != nil {
+			rd = bytes.NewReader(body) // sets Content-Length on the upstream request
+		}
+		newReq, err := http.NewRequestWithContext(ctx, method, u.String(), rd)
+		if err != nil {
+			return nil, err
+		}
+
+		// Copy headers, prefer JSON unless the caller chose a type
+		p.headers.copy(newReq.Header, header)
+		if newReq.Header.Get("Accept") == "" {
+			newReq.Header.Set("Accept", "application/json")
+		}
+
+		resp, err := p.client.Do(newReq)
+		backoff := time.Duration(attempt) * p.retryBackoff
+		// Out of attempts, or the context's deadline leaves no time to retry
+		last := attempt == p.maxAttempts || !retryFits(ctx, backoff)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if err == nil {
+			if last {
+				return resp, nil
+			}
+			// drain and close before retrying
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			lastErr = nil
+		} else {
+			lastErr = err
+		}
+		if last {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	return nil, lastErr
+}
+
+// retryFits reports whether ctx leaves time to wait backoff and try again.
+func retryFits(ctx context.Context, backoff time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > backoff
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
 	}
+}
 
-	// Copy headers, prefer JSON
-	copyHeaders(newReq.Header, req.Header)
-	newReq.Header.Set("Accept", "application/json")
+// forward sends the inbound request to upstreamPath and copies the upstream
+// response headers to w. Unless passthrough is set, JSON is requested from
+// upstream whatever the client accepts; passthrough forwards the client's
+// Accept header so e.g. SSZ responses can flow through. Upstream failures
+// come back as an *ErrUpstreamUnreachable for the caller to answer; a
+// request body that can't be read is answered here, and forward returns nil
+// for both. Otherwise the caller must close the returned body.
+func (p *UpstreamProxy) forward(w http.ResponseWriter, req *http.Request, upstreamPath string, passthrough bool) (*http.Response, error) {
+	body, ok := p.readBody(w, req)
+	if !ok {
+		return nil, nil
+	}
 
-	resp, err := client.Do(newReq)
+	resp, err := p.do(req.Context(), req.Method, upstreamPath, req.URL.RawQuery, p.requestHeader(req, passthrough), body)
 	if err != nil {
-		http.Error(w, `{"status":"ERROR: upstream unreachable"}`, http.StatusBadGateway)
-		return
+		return nil, &ErrUpstreamUnreachable{Err: err}
 	}
-	defer resp.Body.Close()
 
 	// Pass status and headers from upstream
 	for k, vv := range resp.Header {
@@ -39,30 +197,108 @@ func proxyJSON(w http.ResponseWriter, req *http.Request, client *http.Client, up
 			w.Header().Add(k, v)
 		}
 	}
+	return resp, nil
+}
+
+// requestHeader returns the client headers of req to send upstream, before
+// the header filter: JSON is requested unless passthrough is set, and the
+// X-Forwarded-* headers are added when enabled. req.Header itself is left
+// unchanged.
+func (p *UpstreamProxy) requestHeader(req *http.Request, passthrough bool) http.Header {
+	header := req.Header
+	if !passthrough || p.forwarded {
+		header = header.Clone()
+	}
+	if !passthrough {
+		header.Set("Accept", "application/json")
+	}
+	if p.forwarded {
+		setForwardedHeaders(header, req)
+	}
+	return header
+}
+
+// setForwardedHeaders appends the client address to X-Forwarded-For and
+// sets X-Forwarded-Proto and X-Forwarded-Host for the inbound request.
+func setForwardedHeaders(header http.Header, req *http.Request) {
+	if ip := clientIP(req, false); ip != "" && ip != "@" { // unix socket peers have no address
+		if prior := header.Values("X-Forwarded-For"); len(prior) > 0 {
+			ip = strings.Join(prior, ", ") + ", " + ip
+		}
+		header.Set("X-Forwarded-For", ip)
+	}
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+	header.Set("X-Forwarded-Proto", proto)
+	header.Set("X-Forwarded-Host", req.Host)
+}
+
+// proxyJSON proxies the request to upstream and optionally transforms the JSON response.
+//
+// Without a transform the request is passed through: the client's Accept
+// header goes upstream and the upstream Content-Type is kept, so non-JSON
+// (e.g. SSZ) bodies arrive untouched.
+func (p *UpstreamProxy) proxyJSON(w http.ResponseWriter, req *http.Request, upstreamPath string, transform func(interface{})) {
+	if err := p.serveJSON(w, req, upstreamPath, transform); err != nil {
+		writeUpstreamError(w, err)
+	}
+}
+
+// serveJSON is the core of proxyJSON. Upstream failures are returned as one
+// of the upstream error types without writing a response, after the
+// upstream headers copied by forward have been dropped again.
+func (p *UpstreamProxy) serveJSON(w http.ResponseWriter, req *http.Request, upstreamPath string, transform func(interface{})) error {
+	resp, err := p.forward(w, req, upstreamPath, transform == nil)
+	if resp == nil {
+		return err
+	}
+	defer resp.Body.Close()
 
 	// Fast path: no transform, stream body through
 	if transform == nil {
-		w.Header().Set("Content-Type", "application/json")
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
 		w.WriteHeader(resp.StatusCode)
 		io.Copy(w, resp.Body)
-		return
+		return nil
 	}
 
 	// Read the response body for transformation
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(p.limitBody(resp.Body))
+	if errors.Is(err, errResponseTooLarge) {
+		p.log.WithFields(logrus.Fields{"path": upstreamPath, "limit": p.maxRespBytes}).Warn("upstream response over size limit")
+		dropUpstreamHeaders(w, resp)
+		return &ErrUpstreamStatus{Status: resp.StatusCode, Reason: "upstream response too large", Err: err}
+	}
 	if err != nil {
-		http.Error(w, `{"status":"ERROR: failed to read upstream response"}`, http.StatusInternalServerError)
-		return
+		// Typically the connection dropped mid-body
+		p.log.WithFields(logrus.Fields{"path": upstreamPath, "bytes": len(respBody)}).WithError(err).Warn("upstream response cut short")
+		dropUpstreamHeaders(w, resp)
+		return &ErrUpstreamStatus{Status: resp.StatusCode, Reason: "upstream response was truncated", Err: err}
 	}
 
 	// Parse JSON response
-	var result interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		// If not JSON, pass through as-is
-		w.Header().Set("Content-Type", "application/json")
+	result, err := decodeUpstreamJSON(respBody, p.strictJSON)
+	if errors.Is(err, errTrailingData) {
+		dropUpstreamHeaders(w, resp)
+		return &ErrUpstreamStatus{Status: resp.StatusCode, Reason: "upstream returned malformed JSON", Err: err}
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		// JSON that stops part way, whatever the status: never pass it on
+		p.log.WithFields(logrus.Fields{"path": upstreamPath, "bytes": len(respBody), "status": resp.StatusCode}).Warn("upstream returned truncated JSON")
+		dropUpstreamHeaders(w, resp)
+		return &ErrUpstreamStatus{Status: resp.StatusCode, Reason: "upstream response was truncated", Err: err}
+	}
+	if err != nil {
+		if err := nonJSONError(w, resp); err != nil {
+			return err
+		}
 		w.WriteHeader(resp.StatusCode)
-		w.Write(body)
-		return
+		w.Write(respBody)
+		return nil
 	}
 
 	// Apply transform
@@ -71,19 +307,205 @@ func proxyJSON(w http.ResponseWriter, req *http.Request, client *http.Client, up
 	// Marshal back to JSON
 	modifiedBody, err := json.Marshal(result)
 	if err != nil {
-		http.Error(w, `{"status":"ERROR: failed to marshal response"}`, http.StatusInternalServerError)
-		return
+		dropUpstreamHeaders(w, resp)
+		return &ErrTransformFailed{Err: err}
 	}
 
+	// The body changed size; drop upstream's length
+	w.Header().Del("Content-Length")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(resp.StatusCode)
 	w.Write(modifiedBody)
+	return nil
+}
+
+// proxyJSONStream proxies the request and applies transform to each element
+// of the response's top-level "data" array while streaming, so large
+// responses are never held in memory as a whole. A "data" object is
+// transformed as one element. A non-nil page limits the elements returned.
+func (p *UpstreamProxy) proxyJSONStream(w http.ResponseWriter, req *http.Request, upstreamPath string, transform func(interface{}), page *dataPage) {
+	resp, err := p.forward(w, req, upstreamPath, false)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+	if resp == nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	br := bufio.NewReader(p.limitBody(resp.Body))
+	if !startsWithObject(br) {
+		if err := nonJSONError(w, resp); err != nil {
+			writeUpstreamError(w, err)
+			return
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, br)
+		return
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	bw := bufio.NewWriter(w)
+	// Errors past this point leave a truncated body; the status is already
+	// sent, so they are only counted and logged.
+	if err := streamTransformData(bw, br, transform, page); errors.Is(err, errResponseTooLarge) {
+		countUpstreamError(&ErrUpstreamStatus{Status: resp.StatusCode, Reason: "upstream response too large", Err: err})
+		p.log.WithFields(logrus.Fields{"path": upstreamPath, "limit": p.maxRespBytes}).Warn("streamed upstream response over size limit, cut short")
+	} else if err != nil {
+		countUpstreamError(&ErrUpstreamStatus{Status: resp.StatusCode, Reason: "upstream response was truncated", Err: err})
+		p.log.WithField("path", upstreamPath).WithError(err).Warn("streamed upstream response cut short")
+	}
+	bw.Flush()
+}
+
+// nonJSONError decides what to do with an upstream body that is not the JSON
+// a transformed route expects. Error statuses are passed through with the
+// upstream Content-Type (nil). A success status with such a body, typically
+// an HTML page from a misconfigured reverse proxy, is an *ErrUpstreamStatus
+// (answered with a 502) rather than relabelled as JSON.
+func nonJSONError(w http.ResponseWriter, resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		dropUpstreamHeaders(w, resp)
+		return &ErrUpstreamStatus{Status: resp.StatusCode, Reason: "upstream returned a non-JSON response"}
+	}
+	return nil
+}
+
+// dropUpstreamHeaders removes the upstream headers copied by forward, before
+// answering with an error of the proxy's own.
+func dropUpstreamHeaders(w http.ResponseWriter, resp *http.Response) {
+	for k := range resp.Header {
+		w.Header().Del(k)
+	}
+}
+
+// errTrailingData reports data after the first JSON value of an upstream body.
+var errTrailingData = errors.New("trailing data after JSON value")
+
+// decodeUpstreamJSON decodes the first JSON value of body. Anything after it is
+// ignored, unless strict is set, in which case errTrailingData is returned.
+// Numbers are kept as json.Number so large uint64 values survive unchanged.
+func decodeUpstreamJSON(body []byte, strict bool) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if strict {
+		if _, err := dec.Token(); err != io.EOF {
+			return nil, errTrailingData
+		}
+	}
+	return v, nil
+}
+
+// fetchJSON GETs upstreamPath from Dora and decodes the JSON body. It
+// returns the upstream status code alongside the decoded body; failures are
+// one of the upstream error types.
+func (p *UpstreamProxy) fetchJSON(ctx context.Context, upstreamPath string) (map[string]interface{}, int, error) {
+	resp, err := p.do(ctx, http.MethodGet, upstreamPath, "", nil, nil)
+	if err != nil {
+		return nil, 0, &ErrUpstreamUnreachable{Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, resp.StatusCode, nil
+	}
+	var body map[string]interface{}
+	dec := json.NewDecoder(p.limitBody(resp.Body))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, resp.StatusCode, p.readError(upstreamPath, resp.StatusCode, err)
+	}
+	return body, resp.StatusCode, nil
 }
 
-func copyHeaders(dst, src http.Header) {
+// readError turns a failure reading or decoding an upstream body into an
+// *ErrUpstreamStatus, or an *ErrUpstreamUnreachable when the request itself
+// was cancelled.
+func (p *UpstreamProxy) readError(upstreamPath string, status int, err error) error {
+	switch {
+	case errors.Is(err, errResponseTooLarge):
+		p.log.WithFields(logrus.Fields{"path": upstreamPath, "limit": p.maxRespBytes}).Warn("upstream response over size limit")
+		return &ErrUpstreamStatus{Status: status, Reason: "upstream response too large", Err: err}
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return &ErrUpstreamUnreachable{Err: err}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &ErrUpstreamStatus{Status: status, Reason: "upstream response was truncated", Err: err}
+	default:
+		return &ErrUpstreamStatus{Status: status, Reason: "upstream returned malformed JSON", Err: err}
+	}
+}
+
+// errResponseTooLarge reports an upstream body over PROXY_MAX_RESPONSE_BYTES.
+var errResponseTooLarge = errors.New("upstream response too large")
+
+// limitBody bounds what the proxy reads from an upstream body: past
+// maxRespBytes, reads fail with errResponseTooLarge instead of buffering on.
+func (p *UpstreamProxy) limitBody(r io.Reader) io.Reader {
+	if p.maxRespBytes <= 0 {
+		return r
+	}
+	return &limitedBody{r: io.LimitReader(r, p.maxRespBytes+1), max: p.maxRespBytes}
+}
+
+// limitedBody reads up to one byte past max from a LimitReader, so an
+// oversized body is told apart from one of exactly max bytes.
+type limitedBody struct {
+	r      io.Reader
+	n, max int64
+}
+
+func (l *limitedBody) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	l.n += int64(n)
+	if l.n > l.max {
+		return n - int(l.n-l.max), errResponseTooLarge
+	}
+	return n, err
+}
+
+// headerFilter decides which client headers are forwarded upstream, on top
+// of the hop-by-hop headers that are always skipped. Keys are canonical.
+type headerFilter struct {
+	strip   map[string]struct{}
+	forward map[string]struct{} // nil forwards everything not stripped
+}
+
+func newHeaderFilter(strip, forward []string) headerFilter {
+	set := func(names []string) map[string]struct{} {
+		if len(names) == 0 {
+			return nil
+		}
+		m := make(map[string]struct{}, len(names))
+		for _, n := range names {
+			m[http.CanonicalHeaderKey(n)] = struct{}{}
+		}
+		return m
+	}
+	return headerFilter{strip: set(strip), forward: set(forward)}
+}
+
+func (f headerFilter) allows(k string) bool {
+	k = http.CanonicalHeaderKey(k)
+	if _, ok := f.strip[k]; ok {
+		return false
+	}
+	if f.forward == nil {
+		return true
+	}
+	_, ok := f.forward[k]
+	return ok
+}
+
+func (f headerFilter) copy(dst, src http.Header) {
 	for k, vv := range src {
 		// Skip hop-by-hop headers
-		if shouldSkipHeader(k) {
+		if shouldSkipHeader(k) || !f.allows(k) {
 			continue
 		}
 		for _, v := range vv {
@@ -101,32 +523,73 @@ func shouldSkipHeader(k string) bool {
 	}
 }
 
-// mapValidatorStatus maps Dora status to Beacon status
-// - active_ongoing -> active_online
-// - withdrawal_done && slashed=true -> slashed
-// - withdrawal_done && slashed=false -> exited
-func mapValidatorStatus(data interface{}) {
+// doraToBeaconStatus maps Dora (beacon API) validator statuses to the Beacon
+// Explorer vocabulary:
+//
+//	Dora status          slashed=false     slashed=true
+//	pending_initialized  deposited         deposited
+//	pending_queued       pending           pending
+//	active_ongoing       active_online     slashing_online
+//	active_exiting       exiting_online    slashing_online
+//	active_slashed       slashing_online   slashing_online
+//	exited_unslashed     exited            slashed
+//	exited_slashed       slashed           slashed
+//	withdrawal_possible  exited            slashed
+//	withdrawal_done      exited            slashed
+//
+// With collapseSlashed every slashed validator past the pending states
+// (slashed flag set or a *_slashed status) is reported as slashed, including
+// those still active in the exit queue. Unknown statuses pass through
+// unchanged. Operator overrides (PROXY_STATUS_MAP) take precedence over
+// this table and the slashed flag.
+var doraToBeaconStatus = map[string]string{
+	"pending_initialized": "deposited",
+	"pending_queued":      "pending",
+	"active_ongoing":      "active_online",
+	"active_exiting":      "exiting_online",
+	"active_slashed":      "slashing_online",
+	"exited_unslashed":    "exited",
+	"exited_slashed":      "slashed",
+	"withdrawal_possible": "exited",
+	"withdrawal_done":     "exited",
+}
+
+// beaconStatus returns the Beacon Explorer status for a Dora status, taking
+// the validator's slashed flag into account. A status found in overrides is
+// mapped as given.
+func beaconStatus(status string, slashed bool, overrides map[string]string, collapseSlashed bool) string {
+	if mapped, ok := overrides[status]; ok {
+		return mapped
+	}
+	mapped, known := doraToBeaconStatus[status]
+	if !known {
+		return status
+	}
+	slashed = slashed || strings.HasSuffix(status, "_slashed")
+	if !slashed || strings.HasPrefix(status, "pending_") {
+		return mapped
+	}
+	if collapseSlashed || !strings.HasPrefix(status, "active_") {
+		return "slashed"
+	}
+	return "slashing_online"
+}
+
+// mapValidatorStatus rewrites every validator status in data using
+// beaconStatus.
+func mapValidatorStatus(data interface{}, overrides map[string]string, collapseSlashed bool) {
 	switch v := data.(type) {
 	case map[string]interface{}:
 		if status, hasStatus := v["status"].(string); hasStatus {
 			slashed, _ := v["slashed"].(bool)
-			switch status {
-			case "active_ongoing":
-				v["status"] = "active_online"
-			case "withdrawal_done":
-				if slashed {
-					v["status"] = "slashed"
-				} else {
-					v["status"] = "exited"
-				}
-			}
+			v["status"] = beaconStatus(status, slashed, overrides, collapseSlashed)
 		}
 		for _, val := range v {
-			mapValidatorStatus(val)
+			mapValidatorStatus(val, overrides, collapseSlashed)
 		}
 	case []interface{}:
 		for _, item := range v {
-			mapValidatorStatus(item)
+			mapValidatorStatus(item, overrides, collapseSlashed)
 		}
 	}
 }
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A POST body is buffered and sent again in full when the first attempt
+// fails with a retryable status.
+func TestValidatorPostRetried(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		attempt := len(bodies)
+		mu.Unlock()
+		if attempt == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		jsonHandler(http.StatusOK, `{"status":"OK","data":[{"validatorindex":3,"status":"active_ongoing"}]}`)(w, req)
+	})
+	cfg := newTestConfig(t)
+	cfg.UpstreamMaxAttempts = 2
+	cfg.UpstreamRetryBackoff = time.Millisecond
+	d := newTestDeps(t, cfg, dora.URL, "")
+	const body = `{"indicesOrPubkey":"3"}`
+	rec := serve(buildRouter(d), http.MethodPost, "/api/v1/validator", body)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(bodies) != 2 || bodies[0] != body || bodies[1] != body {
+		t.Fatalf("upstream bodies = %q, want the full body twice", bodies)
+	}
+	data, _ := decodeJSON(t, rec)["data"].([]interface{})
+	if len(data) != 1 {
+		t.Fatalf("data = %v, want the retried response", data)
+	}
+}
+
+func TestValidatorPostNotRetriedOn4xx(t *testing.T) {
+	calls := 0
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		jsonHandler(http.StatusBadRequest, `{"status":"ERROR: bad request"}`)(w, req)
+	})
+	cfg := newTestConfig(t)
+	cfg.UpstreamMaxAttempts = 3
+	cfg.UpstreamRetryBackoff = time.Millisecond
+	d := newTestDeps(t, cfg, dora.URL, "")
+	rec := serve(buildRouter(d), http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"x"}`)
+	if rec.Code != http.StatusBadRequest || calls != 1 {
+		t.Fatalf("status = %d after %d calls, want the 400 passed on after one", rec.Code, calls)
+	}
+}
+
+func TestRequestBodyTooLarge(t *testing.T) {
+	calls := 0
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) { calls++ })
+	cfg := newTestConfig(t)
+	cfg.MaxRequestBodyBytes = 16
+	d := newTestDeps(t, cfg, dora.URL, "")
+	rec := serve(buildRouter(d), http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"`+strings.Repeat("1,", 20)+`1"}`)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rec.Code)
+	}
+	if calls != 0 {
+		t.Fatal("oversized body was forwarded")
+	}
+}
+
+func TestBeaconStatus(t *testing.T) {
+	tests := []struct {
+		status  string
+		slashed bool
+		want    string
+	}{
+		{"pending_initialized", false, "deposited"},
+		{"pending_queued", false, "pending"},
+		{"active_ongoing", false, "active_online"},
+		{"active_exiting", false, "exiting_online"},
+		{"active_slashed", false, "slashing_online"},
+		{"exited_unslashed", false, "exited"},
+		{"exited_slashed", false, "slashed"},
+		{"withdrawal_possible", false, "exited"},
+		{"withdrawal_done", false, "exited"},
+		{"withdrawal_done", true, "slashed"},
+		{"active_ongoing", true, "slashing_online"},
+		{"pending_queued", true, "pending"},
+		{"something_new", false, "something_new"},
+	}
+	for _, tt := range tests {
+		if got := beaconStatus(tt.status, tt.slashed, nil, false); got != tt.want {
+			t.Errorf("beaconStatus(%q, slashed=%v) = %q, want %q", tt.status, tt.slashed, got, tt.want)
+		}
+	}
+}
+
+// Every status Dora documents has a mapping.
+func TestBeaconStatusCoversDoraStatuses(t *testing.T) {
+	for _, status := range []string{
+		"pending_initialized", "pending_queued",
+		"active_ongoing", "active_exiting", "active_slashed",
+		"exited_unslashed", "exited_slashed",
+		"withdrawal_possible", "withdrawal_done",
+	} {
+		if _, ok := doraToBeaconStatus[status]; !ok {
+			t.Errorf("no mapping for %q", status)
+		}
+	}
+}
+
+func TestMapValidatorStatusNested(t *testing.T) {
+	var data interface{}
+	if err := json.Unmarshal([]byte(`[{"status":"active_ongoing"},{"status":"withdrawal_done","slashed":true}]`), &data); err != nil {
+		t.Fatal(err)
+	}
+	mapValidatorStatus(data, nil, false)
+	got, _ := json.Marshal(data)
+	if want := `[{"status":"active_online"},{"slashed":true,"status":"slashed"}]`; string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestDecodeUpstreamJSONTrailingData(t *testing.T) {
+	for _, body := range []string{`{"status":"OK"}`, "{\"status\":\"OK\"}\n"} {
+		if _, err := decodeUpstreamJSON([]byte(body), true); err != nil {
+			t.Errorf("strict decode of %q: %v", body, err)
+		}
+	}
+	const corrupt = `{"status":"OK","data":{}}garbage`
+	if _, err := decodeUpstreamJSON([]byte(corrupt), true); !errors.Is(err, errTrailingData) {
+		t.Errorf("strict decode error = %v, want errTrailingData", err)
+	}
+	v, err := decodeUpstreamJSON([]byte(corrupt), false)
+	if err != nil {
+		t.Fatalf("lenient decode: %v", err)
+	}
+	if m, _ := v.(map[string]interface{}); m["status"] != "OK" {
+		t.Fatalf("lenient decode = %v, want the first value", v)
+	}
+}
+
+func TestStrictJSONRejectsTrailingGarbage(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0}}garbage`))
+	for strict, want := range map[bool]int{true: http.StatusBadGateway, false: http.StatusOK} {
+		cfg := newTestConfig(t)
+		cfg.StrictJSON = strict
+		rec := serve(buildRouter(newTestDeps(t, cfg, dora.URL, "")), http.MethodGet, "/api/v1/slot/5", "")
+		if rec.Code != want {
+			t.Errorf("strict=%v: status = %d, want %d: %s", strict, rec.Code, want, rec.Body.String())
+		}
+	}
+}
+
+// Integers above 2^53 pass through the transform paths without losing
+// precision.
+func TestLargeIntegersRoundTrip(t *testing.T) {
+	const big = "9007199254740993" // 2^53 + 1, not representable as float64
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v1/slot/5":
+			jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0,"exec_base_fee_per_gas":`+big+`}}`)(w, req)
+		case "/api/v1/validator":
+			jsonHandler(http.StatusOK, `{"status":"OK","data":[{"validatorindex":1,"balance":`+big+`,"status":"active_ongoing"}]}`)(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, ""))
+
+	for _, r := range []struct{ method, target, body, want string }{
+		{http.MethodGet, "/api/v1/slot/5", "", `"exec_base_fee_per_gas":` + big},
+		{http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"1"}`, `"balance":` + big},
+	} {
+		rec := serve(h, r.method, r.target, r.body)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), r.want) {
+			t.Errorf("%s %s: status %d, body %s; want it to contain %s", r.method, r.target, rec.Code, rec.Body.String(), r.want)
+		}
+	}
+}
+
+func TestParseUint64FromInterface(t *testing.T) {
+	tests := []struct {
+		in     interface{}
+		want   uint64
+		wantOK bool
+	}{
+		{json.Number("18446744073709551615"), 18446744073709551615, true},
+		{json.Number("1.5"), 0, false},
+		{"9007199254740993", 9007199254740993, true},
+		{"", 0, false},
+		{float64(42), 42, true},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseUint64FromInterface(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseUint64FromInterface(%#v) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestBeaconStatusSlashed(t *testing.T) {
+	tests := []struct {
+		status   string
+		slashed  bool
+		collapse bool
+		want     string
+	}{
+		{"active_slashed", true, false, "slashing_online"},
+		{"active_slashed", false, false, "slashing_online"}, // status string alone
+		{"active_slashed", true, true, "slashed"},
+		{"exited_slashed", true, false, "slashed"},
+		{"exited_slashed", false, false, "slashed"},
+		{"exited_slashed", true, true, "slashed"},
+		{"active_exiting", true, false, "slashing_online"},
+		{"active_exiting", true, true, "slashed"},
+		{"withdrawal_possible", true, false, "slashed"},
+		{"exited_unslashed", false, true, "exited"},
+	}
+	for _, tt := range tests {
+		if got := beaconStatus(tt.status, tt.slashed, nil, tt.collapse); got != tt.want {
+			t.Errorf("beaconStatus(%q, slashed=%v, collapse=%v) = %q, want %q", tt.status, tt.slashed, tt.collapse, got, tt.want)
+		}
+	}
+}
+
+func TestValidatorCollapseSlashed(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":[{"validatorindex":1,"status":"active_slashed","slashed":true}]}`))
+	t.Setenv("PROXY_COLLAPSE_SLASHED", "true")
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, ""))
+	rec := serve(h, http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"1"}`)
+	if !strings.Contains(rec.Body.String(), `"status":"slashed"`) {
+		t.Fatalf("body = %s, want the validator reported as slashed", rec.Body.String())
+	}
+}
+
+// Without a transform the client's Accept goes upstream and an SSZ body
+// comes back byte for byte with its own Content-Type.
+func TestPassthroughOctetStream(t *testing.T) {
+	ssz := []byte{0x00, 0x01, 0xfe, 0xff, '{', 0x7f}
+	var accept string
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		accept = req.Header.Get("Accept")
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(ssz)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, ""))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil)
+	req.Header.Set("Accept", "application/octet-stream")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if accept != "application/octet-stream" {
+		t.Errorf("upstream Accept = %q, want the client's", accept)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("Content-Type = %q, want upstream's", ct)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), ssz) {
+		t.Fatalf("body = %x, want %x", rec.Body.Bytes(), ssz)
+	}
+}
+
+// Transforming routes need JSON whatever the client accepts.
+func TestTransformRequestsJSON(t *testing.T) {
+	var accept string
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		accept = req.Header.Get("Accept")
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0}}`)(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, ""))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/slot/5", nil)
+	req.Header.Set("Accept", "application/octet-stream")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if accept != "application/json" {
+		t.Fatalf("upstream Accept = %q, want application/json", accept)
+	}
+}
+
+func htmlHandler(status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(status)
+		io.WriteString(w, "<html><body>Bad Gateway</body></html>")
+	}
+}
+
+// An HTML page with a success status on a transformed route is answered
+// with a JSON 502 instead of being passed on as JSON.
+func TestHTMLUpstreamBody(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.UpstreamMaxAttempts = 1
+	h := buildRouter(newTestDeps(t, cfg, newTestServer(t, htmlHandler(http.StatusOK)).URL, ""))
+	for _, r := range []struct{ method, target, body string }{
+		{http.MethodGet, "/api/v1/slot/5", ""},
+		{http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"1"}`},
+	} {
+		rec := serve(h, r.method, r.target, r.body)
+		if rec.Code != http.StatusBadGateway {
+			t.Errorf("%s: status = %d, want 502", r.target, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q, want application/json", r.target, ct)
+		}
+		if m := decodeJSON(t, rec); m["message"] != "upstream returned a non-JSON response" {
+			t.Errorf("%s: body = %s", r.target, rec.Body.String())
+		}
+	}
+}
+
+// An upstream error page keeps its status and Content-Type.
+func TestHTMLUpstreamErrorPassedThrough(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.UpstreamMaxAttempts = 1
+	h := buildRouter(newTestDeps(t, cfg, newTestServer(t, htmlHandler(http.StatusNotFound)).URL, ""))
+	rec := serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "text/html" {
+		t.Fatalf("status %d, Content-Type %q; want the upstream 404 page", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestHeaderFilter(t *testing.T) {
+	src := http.Header{
+		"Cookie":      {"session=1"},
+		"Traceparent": {"00-abc-def-01"},
+		"X-Other":     {"x"},
+		"Connection":  {"keep-alive"},
+	}
+
+	dst := http.Header{}
+	newHeaderFilter([]string{"cookie"}, nil).copy(dst, src)
+	if dst.Get("Cookie") != "" {
+		t.Errorf("stripped Cookie forwarded: %q", dst.Get("Cookie"))
+	}
+	if dst.Get("Traceparent") == "" || dst.Get("X-Other") == "" {
+		t.Errorf("unlisted headers dropped without a forward list: %v", dst)
+	}
+	if dst.Get("Connection") != "" {
+		t.Errorf("hop-by-hop header forwarded: %v", dst)
+	}
+
+	// with a forward list only those headers pass, still minus the stripped ones
+	dst = http.Header{}
+	newHeaderFilter([]string{"Cookie"}, []string{"traceparent", "cookie", "connection"}).copy(dst, src)
+	if len(dst) != 1 || dst.Get("Traceparent") != "00-abc-def-01" {
+		t.Errorf("forwarded headers = %v, want only Traceparent", dst)
+	}
+}
+
+func TestStripHeadersUpstream(t *testing.T) {
+	var got http.Header
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		got = req.Header.Clone()
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{}}`)(w, req)
+	})
+	cfg := newTestConfig(t)
+	cfg.StripHeaders = []string{"Cookie"}
+	h := buildRouter(newTestDeps(t, cfg, dora.URL, ""))
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil)
+	req.Header.Set("Cookie", "session=1")
+	req.Header.Set("Traceparent", "00-abc-def-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.Get("Cookie") != "" {
+		t.Errorf("upstream got Cookie %q, want it stripped", got.Get("Cookie"))
+	}
+	if got.Get("Traceparent") != "00-abc-def-01" {
+		t.Errorf("upstream Traceparent = %q, want it preserved", got.Get("Traceparent"))
+	}
+}
+
+func TestForwardedHeadersUpstream(t *testing.T) {
+	var got http.Header
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		got = req.Header.Clone()
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{}}`)(w, req)
+	})
+	cfg := newTestConfig(t)
+	h := buildRouter(newTestDeps(t, cfg, dora.URL, ""))
+	req := httptest.NewRequest(http.MethodGet, "http://dora.example/api/v1/epoch/latest", nil)
+	req.RemoteAddr = "192.0.2.7:4321"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if xff := got.Get("X-Forwarded-For"); xff != "198.51.100.1, 192.0.2.7" {
+		t.Errorf("X-Forwarded-For = %q, want the client appended", xff)
+	}
+	if proto := got.Get("X-Forwarded-Proto"); proto != "http" {
+		t.Errorf("X-Forwarded-Proto = %q, want http", proto)
+	}
+	if host := got.Get("X-Forwarded-Host"); host != "dora.example" {
+		t.Errorf("X-Forwarded-Host = %q, want dora.example", host)
+	}
+	if req.Header.Get("X-Forwarded-For") != "198.51.100.1" {
+		t.Errorf("client request header modified: %q", req.Header.Get("X-Forwarded-For"))
+	}
+
+	cfg = newTestConfig(t)
+	cfg.ForwardedHeaders = false
+	h = buildRouter(newTestDeps(t, cfg, dora.URL, ""))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil))
+	for _, k := range []string{"X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host"} {
+		if v := got.Get(k); v != "" {
+			t.Errorf("%s = %q with forwarded headers disabled", k, v)
+		}
+	}
+}
+
+func TestTruncatedUpstreamBody(t *testing.T) {
+	const partial = `{"status":"OK","data":{"slot":5,"ep`
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"connection closed mid-body", func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Length", "4096")
+			io.WriteString(w, partial)
+			w.(http.Flusher).Flush()
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		}},
+		{"complete body with cut JSON", jsonHandler(http.StatusOK, partial)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := buildRouter(newTestDeps(t, newTestConfig(t), newTestServer(t, tt.handler).URL, ""))
+			rec := serve(h, http.MethodGet, "/api/v1/slot/5", "")
+			if rec.Code != http.StatusBadGateway {
+				t.Fatalf("status = %d, want 502: %s", rec.Code, rec.Body.String())
+			}
+			m := decodeJSON(t, rec)
+			if m["status"] != "error" || m["message"] != "upstream response was truncated" {
+				t.Errorf("body = %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestBeaconStatusOverrides(t *testing.T) {
+	overrides := map[string]string{
+		"active_ongoing":  "active",          // replaces a built-in mapping
+		"exited_slashed":  "exited_slashed",  // wins over the slashed rule
+		"pending_builder": "pending_builder", // a status Dora may add later
+	}
+	tests := []struct {
+		status  string
+		slashed bool
+		want    string
+	}{
+		{"active_ongoing", false, "active"},
+		{"active_ongoing", true, "active"},
+		{"exited_slashed", false, "exited_slashed"},
+		{"pending_builder", false, "pending_builder"},
+		{"active_exiting", false, "exiting_online"}, // not overridden
+	}
+	for _, tt := range tests {
+		if got := beaconStatus(tt.status, tt.slashed, overrides, false); got != tt.want {
+			t.Errorf("beaconStatus(%q, slashed=%v) = %q, want %q", tt.status, tt.slashed, got, tt.want)
+		}
+	}
+}
+
+func TestValidatorStatusMapFromConfig(t *testing.T) {
+	t.Setenv("PROXY_STATUS_MAP", `{"active_ongoing":"active"}`)
+	cfg, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SlotsPerEpoch, cfg.SecondsPerSlot = defaultSlotsPerEpoch, defaultSecondsPerSlot
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":[`+
+		`{"validatorindex":1,"status":"active_ongoing"},{"validatorindex":2,"status":"exited_unslashed"}]}`))
+	rec := serve(buildRouter(newTestDeps(t, cfg, dora.URL, "")), http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"1,2"}`)
+	data, _ := decodeJSON(t, rec)["data"].([]interface{})
+	if len(data) != 2 {
+		t.Fatalf("body = %s", rec.Body.String())
+	}
+	for i, want := range []string{"active", "exited"} {
+		if v, _ := data[i].(map[string]interface{}); v["status"] != want {
+			t.Errorf("validator %d status = %v, want %s", i+1, v["status"], want)
+		}
+	}
+
+	t.Setenv("PROXY_STATUS_MAP", `["active"]`)
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "PROXY_STATUS_MAP") {
+		t.Errorf("loadConfig error = %v, want one naming PROXY_STATUS_MAP", err)
+	}
+}
+
+func TestResponseOverSizeLimit(t *testing.T) {
+	big := `{"status":"OK","data":{"slot":5,"epoch":0,"graffiti":"` + strings.Repeat("a", 4096) + `"}}`
+	cfg := newTestConfig(t)
+	cfg.MaxResponseBytes = 1024
+	h := buildRouter(newTestDeps(t, cfg, newTestServer(t, jsonHandler(http.StatusOK, big)).URL, ""))
+	rec := serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", rec.Code)
+	}
+	if m := decodeJSON(t, rec); m["message"] != "upstream response too large" {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+
+	// within the limit the same route works
+	cfg = newTestConfig(t)
+	cfg.MaxResponseBytes = 1 << 20
+	h = buildRouter(newTestDeps(t, cfg, newTestServer(t, jsonHandler(http.StatusOK, big)).URL, ""))
+	if rec := serve(h, http.MethodGet, "/api/v1/slot/5", ""); rec.Code != http.StatusOK {
+		t.Fatalf("status under the limit = %d", rec.Code)
+	}
+}
+
+// A streamed response has its status sent already; over the limit it is
+// cut short rather than read on.
+func TestStreamedResponseOverSizeLimit(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`{"status":"OK","data":[`)
+	for i := 0; i < 200; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"validatorindex":%d,"status":"active_ongoing"}`, i)
+	}
+	b.WriteString(`]}`)
+	cfg := newTestConfig(t)
+	cfg.MaxResponseBytes = 1024
+	h := buildRouter(newTestDeps(t, cfg, newTestServer(t, jsonHandler(http.StatusOK, b.String())).URL, ""))
+	rec := serve(h, http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"1"}`)
+	if rec.Body.Len() >= b.Len() {
+		t.Fatalf("streamed %d bytes of a %d byte body with a 1024 byte limit", rec.Body.Len(), b.Len())
+	}
+	if json.Valid(rec.Body.Bytes()) {
+		t.Error("cut short body is valid JSON")
+	}
+}
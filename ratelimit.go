@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitEvictInterval = time.Minute
+	rateLimitIdleTTL       = 5 * time.Minute
+)
+
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	lastSeen time.Time
+}
+
+// RateLimiter is a per-client token bucket limiter keyed on client IP.
+type RateLimiter struct {
+	rps      float64
+	burst    float64
+	trustXFF bool
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter creates a limiter and starts a background goroutine that
+// evicts buckets idle for longer than rateLimitIdleTTL.
+func NewRateLimiter(rps float64, burst int, trustXFF bool) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &RateLimiter{
+		rps:      rps,
+		burst:    float64(burst),
+		trustXFF: trustXFF,
+		buckets:  make(map[string]*tokenBucket),
+	}
+	go func() {
+		ticker := time.NewTicker(rateLimitEvictInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			l.evictIdle(now)
+		}
+	}()
+	return l
+}
+
+// allow takes a token for key. When none is available it returns false and
+// how long until the next token.
+func (l *RateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rps)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.rps * float64(time.Second))
+	return false, wait
+}
+
+func (l *RateLimiter) evictIdle(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, b := range l.buckets {
+		if now.Sub(b.lastSeen) > rateLimitIdleTTL {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// Middleware rejects requests over the client's rate with 429.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ok, wait := l.allow(clientIP(req, l.trustXFF), time.Now())
+		if !ok {
+			secs := int(math.Ceil(wait.Seconds()))
+			if secs < 1 {
+				secs = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	const burst = 3
+	calls := 0
+	h := NewRateLimiter(0.1, burst, false).Middleware(okHandler(&calls))
+	for i := 0; i <= burst; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil)
+		req.RemoteAddr = "192.0.2.1:40000"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if i < burst {
+			if rec.Code != http.StatusOK {
+				t.Fatalf("request %d: status %d, want 200", i+1, rec.Code)
+			}
+			continue
+		}
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("request %d: status %d, want 429", i+1, rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "10" {
+			t.Errorf("Retry-After = %q, want 10", got)
+		}
+	}
+	if calls != burst {
+		t.Fatalf("handler ran %d times, want %d", calls, burst)
+	}
+
+	// Another client has its own bucket
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil)
+	req.RemoteAddr = "192.0.2.2:40000"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("other client: status %d, want 200", rec.Code)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l := NewRateLimiter(2, 1, false)
+	now := time.Unix(1700000000, 0)
+	if ok, _ := l.allow("a", now); !ok {
+		t.Fatal("first request refused")
+	}
+	ok, wait := l.allow("a", now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("allow = %v, wait %v; want refused for 500ms", ok, wait)
+	}
+	if ok, _ := l.allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Fatal("request refused after the bucket refilled")
+	}
+}
+
+func TestRateLimiterForwardedFor(t *testing.T) {
+	l := NewRateLimiter(0.1, 1, true)
+	calls := 0
+	h := l.Middleware(okHandler(&calls))
+	for _, xff := range []string{"198.51.100.1", "198.51.100.2, 10.0.0.1"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234" // the same load balancer
+		req.Header.Set("X-Forwarded-For", xff)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 2 {
+		t.Fatalf("handler ran %d times, want clients told apart by X-Forwarded-For", calls)
+	}
+}
+
+func TestRateLimiterEvictIdle(t *testing.T) {
+	l := NewRateLimiter(1, 1, false)
+	now := time.Unix(1700000000, 0)
+	l.allow("a", now)
+	l.allow("b", now.Add(rateLimitIdleTTL))
+	l.evictIdle(now.Add(rateLimitIdleTTL + time.Second))
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("idle bucket kept")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Error("recently used bucket evicted")
+	}
+}
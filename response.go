@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// envelope is Dora's response wrapper: {"status":"OK","data":...}.
+type envelope struct {
+	Status string      `json:"status"`
+	Data   interface{} `json:"data"`
+}
+
+// ensureEnvelope makes sure a decoded upstream body carries Dora's envelope
+// fields. Bodies that are not objects are left untouched.
+func ensureEnvelope(body interface{}) {
+	root, ok := body.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if s, _ := root["status"].(string); s == "" {
+		root["status"] = "OK"
+	}
+	if _, has := root["data"]; !has {
+		root["data"] = nil
+	}
+}
+
+// errorResponse is the body of every error returned by the proxy.
+type errorResponse struct {
+	Status    string `json:"status"`
+	Code      int    `json:"code"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Message   string `json:"message"`
+}
+
+// writeError writes a JSON error envelope with the given HTTP status code.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeCodedError(w, code, "", msg)
+}
+
+// writeCodedError is writeError with a machine-readable error code for
+// clients that need to tell failure causes apart.
+func writeCodedError(w http.ResponseWriter, code int, errCode, msg string) {
+	b, _ := json.Marshal(errorResponse{Status: "error", Code: code, ErrorCode: errCode, Message: msg})
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// writeJSON writes v as a JSON response for endpoints served by the proxy
+// itself (no upstream call). When wrap is set, v is placed inside Dora's
+// envelope so every route returns the same shape.
+func writeJSON(w http.ResponseWriter, code int, wrap bool, v interface{}) {
+	if wrap {
+		v = envelope{Status: "OK", Data: v}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to marshal response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
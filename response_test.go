@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnsureEnvelope(t *testing.T) {
+	body := map[string]interface{}{"data": []interface{}{}}
+	ensureEnvelope(body)
+	if body["status"] != "OK" {
+		t.Fatalf("status = %v, want OK", body["status"])
+	}
+
+	body = map[string]interface{}{"status": "ERROR: not found"}
+	ensureEnvelope(body)
+	if body["status"] != "ERROR: not found" {
+		t.Fatalf("status = %v, want upstream status kept", body["status"])
+	}
+	if data, has := body["data"]; !has || data != nil {
+		t.Fatalf("data = %v (present %v), want null", data, has)
+	}
+}
+
+// Every route answers with Dora's {"status","data"} envelope when wrapping is
+// on, whether upstream sent one or not.
+func TestEnvelopeShapeAcrossRoutes(t *testing.T) {
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v1/slot/5":
+			// no envelope status from upstream
+			jsonHandler(http.StatusOK, `{"data":{"slot":5,"epoch":0,"status":"Proposed"}}`)(w, req)
+		case "/api/v1/validator":
+			jsonHandler(http.StatusOK, `{"data":[{"validatorindex":1,"status":"active_ongoing"}]}`)(w, req)
+		case "/api/v1/validator/1":
+			jsonHandler(http.StatusOK, `{"status":"OK","data":[{"validatorindex":1,"status":"active_ongoing"}]}`)(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	cfg := newTestConfig(t)
+	cfg.WrapEnvelope = true
+	d := newTestDeps(t, cfg, dora.URL, "")
+	setHeadSlot(d.tracker, 70)
+	d.cache.SetIfGreater(1, 64)
+	h := buildRouter(d)
+
+	tests := []struct {
+		method, target, body string
+		wantData             string // JSON type of data
+	}{
+		{http.MethodGet, "/api/v1/slot/5", "", "object"},
+		{http.MethodPost, "/api/v1/validator", `{"indicesOrPubkey":"1"}`, "array"},
+		{http.MethodGet, "/api/v1/validator/1", "", "object"},
+		{http.MethodGet, "/api/v1/epoch/current", "", "object"},
+		{http.MethodGet, "/api/v1/attestation/1", "", "object"},
+		{http.MethodPost, "/api/v1/attestations", `[1,2]`, "object"},
+		{http.MethodGet, "/api/v1/config", "", "object"},
+		{http.MethodGet, "/api/v1/internal/status", "", "object"},
+		{http.MethodGet, "/api/v1/spec", "", "object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			rec := serve(h, tt.method, tt.target, tt.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			m := decodeJSON(t, rec)
+			if m["status"] != "OK" {
+				t.Errorf("status = %v, want OK", m["status"])
+			}
+			switch data := m["data"].(type) {
+			case map[string]interface{}:
+				if tt.wantData != "object" {
+					t.Errorf("data is an object, want %s", tt.wantData)
+				}
+			case []interface{}:
+				if tt.wantData != "array" {
+					t.Errorf("data is an array, want %s", tt.wantData)
+				}
+			default:
+				t.Errorf("data = %v, want %s", data, tt.wantData)
+			}
+		})
+	}
+}
+
+// Without wrapping, proxy-served routes answer with the bare object while
+// upstream-derived ones keep the envelope.
+func TestEnvelopeWrapDisabled(t *testing.T) {
+	cfg := newTestConfig(t)
+	d := newTestDeps(t, cfg, newTestServer(t, http.NotFound).URL, "")
+	setHeadSlot(d.tracker, 70)
+	rec := serve(buildRouter(d), http.MethodGet, "/api/v1/epoch/current", "")
+	m := decodeJSON(t, rec)
+	if _, has := m["status"]; has {
+		t.Fatalf("unwrapped response has a status member: %s", rec.Body.String())
+	}
+	if m["epoch"] != float64(2) {
+		t.Fatalf("epoch = %v, want 2", m["epoch"])
+	}
+}
+
+func TestErrorEnvelopeOn502(t *testing.T) {
+	dead := newTestServer(t, http.NotFound)
+	dead.Close()
+	d := newTestDeps(t, newTestConfig(t), dead.URL, "")
+	rec := serve(buildRouter(d), http.MethodGet, "/api/v1/epoch/latest", "")
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	m := decodeJSON(t, rec)
+	if m["status"] != "error" || m["code"] != float64(http.StatusBadGateway) || m["message"] != "upstream unreachable" {
+		t.Fatalf("body = %s, want the error envelope", rec.Body.String())
+	}
+	if len(m) != 3 {
+		t.Errorf("body = %s, want only status, code and message", rec.Body.String())
+	}
+}
+
+func TestWriteCodedError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Length", "999") // left over from upstream
+	writeCodedError(rec, http.StatusBadRequest, "MISSING_FROM", "from is required")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("stale Content-Length kept")
+	}
+	want := `{"status":"error","code":400,"error_code":"MISSING_FROM","message":"from is required"}`
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("body = %s, want %s", got, want)
+	}
+}
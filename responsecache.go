@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cachedResponse is a captured upstream-derived response.
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+	size    int64
+}
+
+type responseCacheEntry struct {
+	key  string
+	resp *cachedResponse
+}
+
+// ResponseCache is an LRU cache of rendered responses bounded by entry count
+// and by total body size. Either limit may be zero to disable it.
+type ResponseCache struct {
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int64
+
+	mu       sync.Mutex
+	ll       *list.List // front = most recently used
+	items    map[string]*list.Element
+	curBytes int64
+
+	hits, misses atomic.Uint64
+}
+
+func NewResponseCache(ttl time.Duration, maxEntries int, maxBytes int64) *ResponseCache {
+	return &ResponseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *ResponseCache) Get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	e := el.Value.(*responseCacheEntry)
+	if time.Now().After(e.resp.expires) {
+		c.removeElement(el)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	c.hits.Add(1)
+	return e.resp, true
+}
+
+// Set stores resp under key, evicting least recently used entries until both
+// the entry and byte budgets are respected. Responses larger than the whole
+// byte budget are not cached.
+func (c *ResponseCache) Set(key string, resp *cachedResponse) {
+	if c.maxBytes > 0 && resp.size > c.maxBytes {
+		return
+	}
+	resp.stored = time.Now()
+	resp.expires = resp.stored.Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	c.items[key] = c.ll.PushFront(&responseCacheEntry{key: key, resp: resp})
+	c.curBytes += resp.size
+	for c.overBudget() {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.removeElement(oldest)
+	}
+}
+
+// Len returns the number of entries and their total size in bytes.
+func (c *ResponseCache) Len() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len(), c.curBytes
+}
+
+// Stats reports the cache's configuration, contents and hit rate.
+func (c *ResponseCache) Stats() cacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := cacheStats{
+		Name:       "response",
+		TTLSeconds: c.ttl.Seconds(),
+		Entries:    c.ll.Len(),
+		Bytes:      c.curBytes,
+		Hits:       c.hits.Load(),
+		Misses:     c.misses.Load(),
+	}
+	var oldest, newest time.Time
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		stored := el.Value.(*responseCacheEntry).resp.stored
+		if oldest.IsZero() || stored.Before(oldest) {
+			oldest = stored
+		}
+		if stored.After(newest) {
+			newest = stored
+		}
+	}
+	st.setAges(time.Now(), oldest, newest)
+	return st
+}
+
+func (c *ResponseCache) overBudget() bool {
+	if c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		return true
+	}
+	return c.maxBytes > 0 && c.curBytes > c.maxBytes
+}
+
+func (c *ResponseCache) removeElement(el *list.Element) {
+	e := el.Value.(*responseCacheEntry)
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	c.curBytes -= e.resp.size
+}
+
+// responseSize approximates the memory held by a cached response.
+func responseSize(header http.Header, body []byte) int64 {
+	n := int64(len(body))
+	for k, vv := range header {
+		for _, v := range vv {
+			n += int64(len(k) + len(v))
+		}
+	}
+	return n
+}
+
+// captureWriter writes through to the client while keeping a copy of the
+// response, giving up on the copy once it exceeds limit bytes. Headers set
+// by the wrapped handler go to a header map of its own, so the copy holds
+// only those and not the ones outer middleware (e.g. CORS) sets per request;
+// they reach the client when the status is written.
+type captureWriter struct {
+	http.ResponseWriter
+	header   http.Header
+	status   int
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func newCaptureWriter(w http.ResponseWriter, limit int64) *captureWriter {
+	return &captureWriter{ResponseWriter: w, header: make(http.Header), limit: limit}
+}
+
+func (cw *captureWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if cw.status != 0 {
+		return
+	}
+	cw.status = code
+	copyHeader(cw.ResponseWriter.Header(), cw.header)
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.overflow {
+		if cw.limit > 0 && int64(cw.buf.Len()+len(b)) > cw.limit {
+			cw.overflow = true
+			cw.buf.Reset()
+		} else {
+			cw.buf.Write(b)
+		}
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// copyHeader sets every header of src on dst, copying the value slices so
+// the two never share them.
+func copyHeader(dst, src http.Header) {
+	for k, vv := range src {
+		dst[k] = append([]string(nil), vv...)
+	}
+}
+
+// responseCacheKey identifies a request for caching: method, path, the query
+// string with params sorted by name, and the headers that select a
+// representation. Requests differing in any of them never share an entry.
+func responseCacheKey(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.URL.EscapedPath())
+	if q := req.URL.Query(); len(q) > 0 {
+		b.WriteByte('?')
+		b.WriteString(q.Encode())
+	}
+	for _, h := range []string{"Accept", "Accept-Encoding"} {
+		b.WriteString("\n")
+		b.WriteString(h)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(req.Header.Values(h), ","))
+	}
+	return b.String()
+}
+
+// cacheResponses serves GET requests from c when possible and stores
+// successful responses. A nil cache disables caching.
+func cacheResponses(c *ResponseCache, next http.HandlerFunc) http.HandlerFunc {
+	if c == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			next(w, req)
+			return
+		}
+		key := responseCacheKey(req)
+		if cached, ok := c.Get(key); ok {
+			markCacheHit(req)
+			copyHeader(w.Header(), cached.header)
+			w.WriteHeader(cached.status)
+			w.Write(cached.body)
+			return
+		}
+
+		cw := newCaptureWriter(w, c.maxBytes)
+		next(cw, req)
+		if cw.status == 0 {
+			// Nothing written: pass on the headers for the implicit 200
+			copyHeader(w.Header(), cw.header)
+			return
+		}
+		if cw.status != http.StatusOK || cw.overflow {
+			return
+		}
+		header := cw.header.Clone()
+		body := bytes.Clone(cw.buf.Bytes())
+		c.Set(key, &cachedResponse{
+			status: cw.status,
+			header: header,
+			body:   body,
+			size:   responseSize(header, body),
+		})
+	}
+}
+
+// cacheHitKey carries a *bool in the request context that cacheResponses
+// sets when it answers from the cache.
+type cacheHitKey struct{}
+
+// withCacheHitFlag returns req with a cache-hit flag for cacheResponses to
+// set, and the flag.
+func withCacheHitFlag(req *http.Request) (*http.Request, *bool) {
+	hit := new(bool)
+	return req.WithContext(context.WithValue(req.Context(), cacheHitKey{}, hit)), hit
+}
+
+func markCacheHit(req *http.Request) {
+	if hit, ok := req.Context().Value(cacheHitKey{}).(*bool); ok {
+		*hit = true
+	}
+}
+
+// handlerLatency is the end-to-end time of instrumented handlers, including
+// enrichment and marshaling, by route and whether the response cache
+// answered.
+var handlerLatency = defaultRegistry.NewHistogramVec(
+	"dora_proxy_handler_duration_seconds",
+	"End-to-end handler time by route and response cache outcome.",
+	[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	"route", "cache",
+)
+
+// timeHandler records next's duration in handlerLatency under route. Wrap
+// it around cacheResponses so cache hits are told apart.
+func timeHandler(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		req, hit := withCacheHitFlag(req)
+		next(w, req)
+		outcome := "miss"
+		if *hit {
+			outcome = "hit"
+		}
+		handlerLatency.With(route, outcome).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cachedBody(n int) *cachedResponse {
+	body := bytes.Repeat([]byte("x"), n)
+	return &cachedResponse{status: http.StatusOK, body: body, size: responseSize(nil, body)}
+}
+
+func TestResponseCacheEvictsByBytes(t *testing.T) {
+	c := NewResponseCache(time.Minute, 0, 100)
+	c.Set("a", cachedBody(40))
+	c.Set("b", cachedBody(40))
+	c.Get("a") // a is now the most recently used
+	c.Set("c", cachedBody(40))
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry b was kept over the byte budget")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("entry %s evicted", k)
+		}
+	}
+	if n, size := c.Len(); n != 2 || size != 80 {
+		t.Fatalf("Len = %d entries, %d bytes; want 2, 80", n, size)
+	}
+}
+
+func TestResponseCacheSkipsOversized(t *testing.T) {
+	c := NewResponseCache(time.Minute, 0, 100)
+	c.Set("small", cachedBody(10))
+	c.Set("huge", cachedBody(101))
+	if _, ok := c.Get("huge"); ok {
+		t.Error("response larger than the byte budget was cached")
+	}
+	if _, ok := c.Get("small"); !ok {
+		t.Error("caching an oversized response evicted others")
+	}
+}
+
+func TestResponseCacheEntryLimit(t *testing.T) {
+	c := NewResponseCache(time.Minute, 2, 0)
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, cachedBody(1000))
+	}
+	if n, _ := c.Len(); n != 2 {
+		t.Fatalf("Len = %d, want 2", n)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("oldest entry kept over the entry limit")
+	}
+}
+
+func TestResponseCacheReplacesKey(t *testing.T) {
+	c := NewResponseCache(time.Minute, 0, 100)
+	c.Set("a", cachedBody(60))
+	c.Set("a", cachedBody(30))
+	if n, size := c.Len(); n != 1 || size != 30 {
+		t.Fatalf("Len = %d entries, %d bytes; want 1, 30", n, size)
+	}
+}
+
+// The cache keeps only the headers its handler set: per-request headers of
+// outer middleware (here CORS) are not replayed to other clients.
+func TestCacheResponsesKeepsHandlerHeadersOnly(t *testing.T) {
+	calls := 0
+	c := NewResponseCache(time.Minute, 0, 1<<20)
+	h := corsMiddleware([]string{"https://a.example", "https://b.example"})(cacheResponses(c, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.Header().Set("X-Handler", "v")
+		w.Write([]byte(`{}`))
+	}))
+
+	for _, origin := range []string{"https://a.example", "https://b.example"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Values("Access-Control-Allow-Origin"); len(got) != 1 || got[0] != origin {
+			t.Errorf("origin %s: Access-Control-Allow-Origin = %q", origin, got)
+		}
+		if got := rec.Header().Get("X-Handler"); got != "v" {
+			t.Errorf("origin %s: X-Handler = %q, want v", origin, got)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want the second request served from cache", calls)
+	}
+}
+
+// Changing a replayed response's headers leaves the cached entry alone.
+func TestCacheResponsesReplayCopiesHeaders(t *testing.T) {
+	c := NewResponseCache(time.Minute, 0, 1<<20)
+	cached := cacheResponses(c, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Handler", "v")
+		w.Write([]byte(`{}`))
+	})
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cached(w, req)
+		w.Header()["X-Handler"][0] = "changed"
+	})
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+	}
+	rec := httptest.NewRecorder()
+	cached(rec, httptest.NewRequest(http.MethodGet, "/x", nil))
+	if got := rec.Header().Get("X-Handler"); got != "v" {
+		t.Fatalf("replayed X-Handler = %q, want v", got)
+	}
+}
+
+func TestCacheResponsesSkipsErrors(t *testing.T) {
+	calls := 0
+	c := NewResponseCache(time.Minute, 0, 0)
+	h := cacheResponses(c, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		writeError(w, http.StatusBadGateway, "upstream unreachable")
+	})
+	for i := 0; i < 2; i++ {
+		h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+	}
+	if calls != 2 {
+		t.Fatalf("handler ran %d times, want error responses never cached", calls)
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	key := func(target string, header ...string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		return responseCacheKey(req)
+	}
+	if key("/api/v1/slots?from=1&to=2") != key("/api/v1/slots?to=2&from=1") {
+		t.Error("query parameter order changes the key")
+	}
+	for _, pair := range [][2]string{
+		{key("/api/v1/slots?from=1&to=2"), key("/api/v1/slots?from=1&to=3")},
+		{key("/api/v1/slots"), key("/api/v1/slots?from=1")},
+		{key("/x"), key("/x", "Accept", "application/octet-stream")},
+		{key("/x"), key("/x", "Accept-Encoding", "gzip")},
+	} {
+		if pair[0] == pair[1] {
+			t.Errorf("keys collide: %q", pair[0])
+		}
+	}
+}
+
+// Requests to the same path that differ only in their query are cached
+// separately.
+func TestCacheResponsesKeyedByQuery(t *testing.T) {
+	calls := 0
+	c := NewResponseCache(time.Minute, 0, 1<<20)
+	h := cacheResponses(c, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.Write([]byte(req.URL.RawQuery))
+	})
+	for _, target := range []string{"/api/v1/slots?from=1", "/api/v1/slots?from=2", "/api/v1/slots?from=1"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		h(rec, req)
+		if got := rec.Body.String(); got != req.URL.RawQuery {
+			t.Errorf("%s: body = %q, want the response for its own query", target, got)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", calls)
+	}
+}
+
+func TestSlotHandlerLatencyObserved(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0}}`))
+	cfg := newTestConfig(t)
+	cfg.ResponseCacheTTL = time.Minute
+	h := buildRouter(newTestDeps(t, cfg, dora.URL, ""))
+
+	miss, hit := handlerLatency.With(routeSlot, "miss"), handlerLatency.With(routeSlot, "hit")
+	missBefore, hitBefore := miss.Count(), hit.Count()
+	serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	if got := miss.Count() - missBefore; got != 1 {
+		t.Errorf("%d miss samples after the first request, want 1", got)
+	}
+	serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	if got := hit.Count() - hitBefore; got != 1 {
+		t.Errorf("%d hit samples after the repeated request, want 1", got)
+	}
+
+	metrics := serve(h, http.MethodGet, "/metrics", "").Body.String()
+	if !strings.Contains(metrics, `dora_proxy_handler_duration_seconds_count{route="SLOT",cache="miss"}`) {
+		t.Errorf("/metrics lacks the slot handler histogram:\n%s", metrics)
+	}
+}
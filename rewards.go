@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	attestationSourceBitlist = "bitlist"
+	attestationSourceRewards = "rewards"
+)
+
+// rewardsLagEpochs is how far behind head the newest epoch with attestation
+// rewards is: rewards for epoch N are only computable once N+1 has completed.
+const rewardsLagEpochs = 2
+
+// scanRewardsTick attributes attestations for every epoch that became
+// available since the previous tick using the rewards endpoint.
+func (t *AttestationTracker) scanRewardsTick() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	headSlot, err := t.getHeadSlot(ctx)
+	cancel()
+	if err != nil {
+		t.log.WithError(err).Warn("failed to get head slot for rewards scan")
+		t.recordTick(false)
+		return
+	}
+	headEpoch := headSlot / t.slotsPerEpoch
+	if headEpoch < rewardsLagEpochs {
+		return
+	}
+	target := headEpoch - rewardsLagEpochs
+
+	t.mu.Lock()
+	start := t.lastScannedEpoch + 1
+	if t.lastScannedEpoch == 0 { // first run: only the newest available epoch
+		start = target
+	}
+	t.mu.Unlock()
+	if start > target {
+		t.recordTick(true)
+		return
+	}
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 90*time.Second)
+	defer cancel2()
+	for e := start; e <= target; e++ {
+		updates, err := t.processRewardsEpoch(ctx2, e)
+		if err != nil {
+			t.log.WithError(err).WithField("epoch", e).Warn("rewards epoch scan failed")
+			t.recordTick(false)
+			return
+		}
+		t.mu.Lock()
+		t.lastScannedEpoch = e
+		t.mu.Unlock()
+		t.log.WithFields(logrus.Fields{"epoch": e, "updates": updates}).Info("rewards epoch scan finished")
+	}
+	t.recordTick(true)
+}
+
+// backfillRewards covers the 3 most recent epochs with available rewards.
+func (t *AttestationTracker) backfillRewards(ctx context.Context, headSlot uint64) error {
+	headEpoch := headSlot / t.slotsPerEpoch
+	if headEpoch < rewardsLagEpochs {
+		return nil
+	}
+	newest := headEpoch - rewardsLagEpochs
+	var oldest uint64
+	if newest >= 2 {
+		oldest = newest - 2
+	}
+	t.log.WithFields(logrus.Fields{"from": newest, "to": oldest}).Info("backfill scanning rewards epochs range")
+	var updates uint64
+	for e := newest; ; e-- {
+		u, err := t.processRewardsEpoch(ctx, e)
+		if err != nil {
+			t.log.WithError(err).Warn("backfill encountered error")
+			return err
+		}
+		updates += u
+		if e == oldest {
+			break
+		}
+	}
+	t.log.WithFields(logrus.Fields{"epochs": newest - oldest + 1, "updates": updates}).Info("backfill completed")
+	return nil
+}
+
+// processRewardsEpoch marks every validator with a positive attestation reward
+// component in epoch as having attested in the epoch's last slot. The rewards
+// endpoint does not report the inclusion slot, so this is an upper bound.
+func (t *AttestationTracker) processRewardsEpoch(ctx context.Context, epoch uint64) (uint64, error) {
+	path := "/eth/v1/beacon/rewards/attestations/" + strconv.FormatUint(epoch, 10)
+	header := http.Header{"Accept": []string{"application/json"}, "Content-Type": []string{"application/json"}}
+	// An empty list asks for rewards of all validators
+	resp, err := t.consensus.Do(ctx, t.client, http.MethodPost, path, header, []byte("[]"))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("attestation rewards request returned status %d", resp.StatusCode)
+	}
+	var payload struct {
+		Data struct {
+			TotalRewards []struct {
+				ValidatorIndex string `json:"validator_index"`
+				Head           string `json:"head"`
+				Target         string `json:"target"`
+				Source         string `json:"source"`
+			} `json:"total_rewards"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return 0, err
+	}
+
+	slot := (epoch+1)*t.slotsPerEpoch - 1
+	var updated uint64
+	for _, r := range payload.Data.TotalRewards {
+		if !positiveReward(r.Source) && !positiveReward(r.Target) && !positiveReward(r.Head) {
+			continue
+		}
+		vi, err := strconv.ParseUint(r.ValidatorIndex, 10, 64)
+		if err != nil {
+			continue
+		}
+		if t.cache.SetIfGreater(vi, slot) {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+func positiveReward(s string) bool {
+	n, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && n > 0
+}
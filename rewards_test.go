@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestProcessRewardsEpoch(t *testing.T) {
+	var gotMethod, gotBody string
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/eth/v1/beacon/rewards/attestations/10" {
+			http.NotFound(w, req)
+			return
+		}
+		gotMethod = req.Method
+		b, _ := io.ReadAll(req.Body)
+		gotBody = string(b)
+		jsonHandler(http.StatusOK, `{"data":{"ideal_rewards":[],"total_rewards":[
+			{"validator_index":"1","head":"2000","target":"0","source":"0"},
+			{"validator_index":"2","head":"0","target":"-5000","source":"-3000"},
+			{"validator_index":"3","head":"0","target":"0","source":"1500"},
+			{"validator_index":"bogus","head":"1","target":"1","source":"1"}
+		]}}`)(w, req)
+	})
+	cfg := newTestConfig(t)
+	cfg.AttestationSource = attestationSourceRewards
+	tr := newTestTracker(t, cfg, consensus.URL)
+	tr.cache.SetIfGreater(3, 400) // newer than the epoch: kept
+
+	updates, err := tr.processRewardsEpoch(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotMethod != http.MethodPost || gotBody != "[]" {
+		t.Errorf("request = %s %q, want POST []", gotMethod, gotBody)
+	}
+	if updates != 1 {
+		t.Errorf("updates = %d, want 1", updates)
+	}
+	lastSlot := uint64(11*32 - 1)
+	if slot, ok := tr.cache.GetOK(1); !ok || slot != lastSlot {
+		t.Errorf("validator 1 = %d (known %v), want %d", slot, ok, lastSlot)
+	}
+	if _, ok := tr.cache.GetOK(2); ok {
+		t.Error("validator 2 without a positive reward was marked as attesting")
+	}
+	if slot := tr.cache.Get(3); slot != 400 {
+		t.Errorf("validator 3 = %d, want the newer 400 kept", slot)
+	}
+}
+
+func TestProcessRewardsEpochError(t *testing.T) {
+	consensus := newTestServer(t, jsonHandler(http.StatusBadRequest, `{"code":400,"message":"epoch not finalized"}`))
+	tr := newTestTracker(t, newTestConfig(t), consensus.URL)
+	if _, err := tr.processRewardsEpoch(context.Background(), 10); err == nil {
+		t.Fatal("want an error for a non-200 rewards response")
+	}
+}
+
+func TestPositiveReward(t *testing.T) {
+	for s, want := range map[string]bool{"1": true, "0": false, "-1": false, "": false, "x": false} {
+		if got := positiveReward(s); got != want {
+			t.Errorf("positiveReward(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -1,65 +1,668 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 )
 
-func buildRouter(cfg *proxyConfig, client *http.Client, upstream *url.URL, cache *LastAttestCache) http.Handler {
+// Route names used by the PROXY_ROUTE_<NAME>_ENABLED switches.
+const (
+	routeValidator      = "VALIDATOR"
+	routeValidatorOne   = "VALIDATOR_ONE"
+	routeEpochLatest    = "EPOCH_LATEST"
+	routeEpochCurrent   = "EPOCH_CURRENT"
+	routeEpochSlots     = "EPOCH_SLOTS"
+	routeEventsHead     = "EVENTS_HEAD"
+	routeSlot           = "SLOT"
+	routeSlots          = "SLOTS"
+	routeAttestation    = "ATTESTATION"
+	routeAttestations   = "ATTESTATIONS"
+	routeSlotAttest     = "SLOT_ATTESTATIONS"
+	routeSlotByBlock    = "SLOT_BYBLOCK"
+	routeSlotCommittees = "SLOT_COMMITTEES"
+	routeConfig         = "CONFIG"
+	routeInternalStatus = "INTERNAL_STATUS"
+	routeInternalCaches = "INTERNAL_CACHES"
+	routeInternalCache  = "INTERNAL_CACHE"
+	routeMetrics        = "METRICS"
+	routeSpec           = "SPEC"
+)
+
+var routeNames = []string{
+	routeValidator,
+	routeValidatorOne,
+	routeEpochLatest,
+	routeEpochCurrent,
+	routeEpochSlots,
+	routeEventsHead,
+	routeSlot,
+	routeSlots,
+	routeAttestation,
+	routeAttestations,
+	routeSlotAttest,
+	routeSlotByBlock,
+	routeSlotCommittees,
+	routeConfig,
+	routeInternalStatus,
+	routeInternalCaches,
+	routeInternalCache,
+	routeMetrics,
+	routeSpec,
+}
+
+// routerDeps is the shared state the route handlers work with.
+type routerDeps struct {
+	cfg       *proxyConfig
+	client    *http.Client
+	consensus *ConsensusNodes
+	upstream  *url.URL
+	cache     *LastAttestCache
+	tracker   *AttestationTracker
+	network   *NetworkInfo
+	alerter   *OfflineAlerter // nil when alerting is disabled
+	pubkeys   *PubkeyResolver // nil unless pubkey resolution is enabled
+	startedAt time.Time
+	log       *logrus.Logger
+	shutdown  context.Context // done once the server starts shutting down
+}
+
+func buildRouter(d *routerDeps) http.Handler {
+	cfg := d.cfg
 	r := mux.NewRouter()
+	proxy := NewUpstreamProxy(d.client, d.upstream, cfg, d.log)
+
+	// handle registers a route, or a stub answering with the configured
+	// status when the route is disabled. Routes are recorded for /api/v1/spec.
+	var registered []registeredRoute
+	handle := func(name, path string, h http.HandlerFunc) *mux.Route {
+		if cfg.DisabledRoutes[name] {
+			h = func(w http.ResponseWriter, req *http.Request) {
+				writeError(w, cfg.DisabledRouteStatus, "route disabled")
+			}
+		}
+		route := r.HandleFunc(path, h)
+		registered = append(registered, registeredRoute{name: name, route: route})
+		return route
+	}
+
+	var respCache *ResponseCache
+	if cfg.ResponseCacheTTL > 0 {
+		respCache = NewResponseCache(cfg.ResponseCacheTTL, cfg.ResponseCacheEntries, cfg.ResponseCacheBytes)
+	}
+
+	head := NewHeadResolver(d.client, d.consensus, cfg.HeadRootTTL)
+	var finality *FinalityCache
+	if cfg.FinalityTTL > 0 {
+		finality = NewFinalityCache(d.client, d.consensus, cfg.FinalityTTL)
+	}
+	balances := NewBalanceCache()
+
+	var batcher *ValidatorBatcher
+	if cfg.ValidatorBatchWindow > 0 {
+		batcher = NewValidatorBatcher(proxy, cfg.PathValidator, cfg.ValidatorBatchWindow)
+	}
+
+	// validatorTransform returns the rewrite applied to each validator object:
+	// status mapping, last attestation slot, balance change and
+	// recent-activation flag.
+	validatorTransform := func(ctx context.Context) func(interface{}) {
+		var resolve func(string) (uint64, bool)
+		if d.pubkeys != nil {
+			resolve = func(pubkey string) (uint64, bool) {
+				return d.pubkeys.Resolve(ctx, pubkey)
+			}
+		}
+		headSlot, headKnown := d.tracker.HeadSlot()
+		return func(validator interface{}) {
+			// remap status
+			mapValidatorStatus(validator, cfg.StatusMap, cfg.CollapseSlashed)
+			// inject lastattestslot using cache
+			attachLastAttestSlot(validator, d.cache, resolve)
+			if m, ok := validator.(map[string]interface{}); ok {
+				attachBalanceChange(m, balances, resolve)
+			}
+			if headKnown && cfg.RecentActivationEpochs > 0 {
+				if m, ok := validator.(map[string]interface{}); ok {
+					markRecentlyActivated(m, headSlot/cfg.SlotsPerEpoch, cfg.RecentActivationEpochs)
+				}
+			}
+			if d.alerter != nil {
+				if m, ok := validator.(map[string]interface{}); ok {
+					if idx, ok := validatorIndexOf(m, resolve); ok {
+						d.alerter.Watch(idx)
+					}
+				}
+			}
+		}
+	}
 
 	// POST /api/v1/validator (with status mapping)
-	r.HandleFunc("/api/v1/validator", func(w http.ResponseWriter, req *http.Request) {
+	handle(routeValidator, "/api/v1/validator", func(w http.ResponseWriter, req *http.Request) {
 		if req.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 			return
 		}
-		transform := func(body interface{}) {
-			// remap status
-			mapValidatorStatus(body)
-			// inject lastattestslot using cache
-			attachLastAttestSlot(body, cache)
+		page, perr := parseDataPage(req.URL.Query(), cfg.ValidatorPageMax)
+		if perr != nil {
+			writeCodedError(w, http.StatusBadRequest, perr.Code, perr.Message)
+			return
 		}
-		proxyJSON(w, req, client, upstream, "/v1/validator", transform)
+		if page != nil {
+			// paging is done here; don't pass the params on to Dora
+			q := req.URL.Query()
+			q.Del("limit")
+			q.Del("offset")
+			req.URL.RawQuery = q.Encode()
+		}
+		var batchKeys []string
+		if batcher != nil {
+			body, ok := proxy.readBody(w, req)
+			if !ok {
+				return
+			}
+			batchKeys, _ = validatorBatchKeys(body)
+		}
+		if cfg.DedupeValidators && batchKeys == nil {
+			body, ok := proxy.readBody(w, req)
+			if !ok {
+				return
+			}
+			body, _ = dedupeValidatorRequest(body)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		// Applied per validator while streaming the upstream "data" array
+		transform := validatorTransform(req.Context())
+		if batchKeys != nil {
+			batch, err := batcher.Fetch(req, batchKeys)
+			if err != nil {
+				writeUpstreamError(w, err)
+				return
+			}
+			batch.Respond(w, batchKeys, transform, page)
+			return
+		}
+		proxy.proxyJSONStream(w, req, cfg.PathValidator, transform, page)
 	}).Methods(http.MethodPost)
 
+	// GET /api/v1/validator/{index} (one validator, as an object)
+	handle(routeValidatorOne, "/api/v1/validator/{index}", func(w http.ResponseWriter, req *http.Request) {
+		index, err := strconv.ParseUint(mux.Vars(req)["index"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "index must be a validator index")
+			return
+		}
+		id := strconv.FormatUint(index, 10)
+		body, status, err := proxy.fetchJSON(req.Context(), cfg.PathValidator+"/"+id)
+		if err != nil {
+			writeUpstreamError(w, err)
+			return
+		}
+		if status == http.StatusNotFound {
+			writeError(w, http.StatusNotFound, "validator not found")
+			return
+		}
+		if status != http.StatusOK {
+			writeError(w, http.StatusBadGateway, "upstream returned status "+strconv.Itoa(status))
+			return
+		}
+		// Dora may answer with a one-element array; unknown validators come
+		// back as null or an empty array
+		validator := body["data"]
+		if list, ok := validator.([]interface{}); ok {
+			validator = nil
+			if len(list) > 0 {
+				validator = list[0]
+			}
+		}
+		if _, ok := validator.(map[string]interface{}); !ok {
+			writeError(w, http.StatusNotFound, "validator not found")
+			return
+		}
+		validatorTransform(req.Context())(validator)
+		body["data"] = validator
+		ensureEnvelope(body)
+		writeJSON(w, http.StatusOK, false, body)
+	}).Methods(http.MethodGet)
+
 	// GET /api/v1/epoch/latest
-	r.HandleFunc("/api/v1/epoch/latest", func(w http.ResponseWriter, req *http.Request) {
-		proxyJSON(w, req, client, upstream, "/v1/epoch/latest", nil)
+	handle(routeEpochLatest, "/api/v1/epoch/latest", cacheResponses(respCache, func(w http.ResponseWriter, req *http.Request) {
+		proxy.proxyJSON(w, req, cfg.PathEpochLatest, nil)
+	})).Methods(http.MethodGet)
+
+	// GET /api/v1/epoch/current (derived from the scanner's head slot, no upstream call)
+	handle(routeEpochCurrent, "/api/v1/epoch/current", func(w http.ResponseWriter, req *http.Request) {
+		head, ok := d.tracker.HeadSlot()
+		if !ok {
+			writeError(w, http.StatusServiceUnavailable, "head slot not known yet")
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, currentEpochAt(head, cfg.SlotsPerEpoch))
+	}).Methods(http.MethodGet)
+
+	// GET /api/v1/epoch/{epoch}/slots (present/missed per slot, probed on the consensus node)
+	handle(routeEpochSlots, "/api/v1/epoch/{epoch}/slots", cacheResponses(respCache, func(w http.ResponseWriter, req *http.Request) {
+		epoch, err := strconv.ParseUint(mux.Vars(req)["epoch"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "epoch must be a non-negative integer")
+			return
+		}
+		if epoch > math.MaxUint64/cfg.SlotsPerEpoch {
+			writeError(w, http.StatusBadRequest, "epoch is out of range")
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, d.tracker.EpochSlots(req.Context(), d.client, epoch))
+	})).Methods(http.MethodGet)
+
+	// GET /api/v1/events/head (consensus head events relayed as SSE)
+	handle(routeEventsHead, "/api/v1/events/head", func(w http.ResponseWriter, req *http.Request) {
+		streamHeadEvents(w, req, d.shutdown, d.client, d.consensus, d.log)
 	}).Methods(http.MethodGet)
 
+	// enrichSlot fills Beacon-missing fields from the consensus node and
+	// projects Dora's slot data into the response shape.
+	enrichSlot := func(ctx context.Context, blockID string, data map[string]interface{}) SlotResponse {
+		if cfg.EnrichTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, cfg.EnrichTimeout)
+			defer cancel()
+		}
+		enrichErr := enrichSlotConsensus(ctx, d.client, d.consensus, d.tracker.Blocks(), blockID, data)
+		slot := buildSlotResponseFromMap(data, cfg.FloatPrecision)
+		slot.Fork = d.network.ForkAt(slot.Epoch)
+		if d.network.LoadGenesis(ctx, d.client, d.consensus) == nil {
+			genesis, _ := d.network.Genesis()
+			slot.SlotTime = uint64(slotToTime(genesis, slot.Slot, cfg.SecondsPerSlot).Unix())
+		}
+		if finality != nil {
+			if epoch, err := finality.FinalizedEpoch(ctx); err == nil {
+				markFinalized(&slot, epoch, cfg.SlotsPerEpoch)
+			}
+		}
+		slot.Enriched = enrichErr == nil
+		if enrichErr != nil {
+			slot.EnrichmentError = enrichErr.Error()
+		}
+		return slot
+	}
+
+	// withBudget bounds req by the request budget: upstream retries stop
+	// once another round would not fit, and enrichment left without time is
+	// skipped, reported as enriched=false.
+	withBudget := func(req *http.Request) (*http.Request, context.CancelFunc) {
+		if cfg.RequestBudget <= 0 {
+			return req, func() {}
+		}
+		ctx, cancel := context.WithTimeout(req.Context(), cfg.RequestBudget)
+		return req.WithContext(ctx), cancel
+	}
+
+	// fetchSlot proxies one slot from Dora, enriched and projected into the
+	// slot response shape. id is a slot number or block root.
+	fetchSlot := func(w http.ResponseWriter, req *http.Request, id string) {
+		req, cancel := withBudget(req)
+		defer cancel()
+		path := cfg.PathSlot + "/" + id
+		// Enrich and then project into Dora base fields + Beacon-missing fields
+		transform := func(body interface{}) {
+			root, ok := body.(map[string]interface{})
+			if !ok {
+				return
+			}
+			data, _ := root["data"].(map[string]interface{})
+			if data == nil {
+				return
+			}
+			slot := enrichSlot(req.Context(), id, data)
+			// Finalized slots can't change; an upstream ETag describes the
+			// untransformed body, so it is never passed on
+			if etag := slotETag(slot); etag != "" {
+				w.Header().Set("ETag", etag)
+			} else {
+				w.Header().Del("ETag")
+			}
+			root["data"] = slot
+			ensureEnvelope(root)
+		}
+		proxy.proxyJSON(w, req, path, transform)
+	}
+
 	// GET /api/v1/slot/{slotOrHash}
-	r.HandleFunc("/api/v1/slot/{slotOrHash}", func(w http.ResponseWriter, req *http.Request) {
+	slotFlights := &sharedResponses{}
+	handle(routeSlot, "/api/v1/slot/{slotOrHash}", conditionalResponses(timeHandler(routeSlot, cacheResponses(respCache, func(w http.ResponseWriter, req *http.Request) {
 		vars := mux.Vars(req)
 		id := vars["slotOrHash"]
 
 		if id == "head" {
-			root, err := resolveHeadRoot(req.Context(), client, cfg.ConsensusAPIURL)
+			root, err := head.Resolve(req.Context())
 			if err != nil {
-				http.Error(w, `{"status":"ERROR: failed to resolve head"}`, http.StatusBadGateway)
+				writeError(w, http.StatusBadGateway, "failed to resolve head")
 				return
 			}
 			id = root
 		}
 
-		path := "/v1/slot/" + id
-		// Enrich and then project into Dora base fields + Beacon-missing fields
-		transform := func(body interface{}) {
-			root, ok := body.(map[string]interface{})
-			if !ok {
+		// Concurrent requests for the same slot share one upstream fetch
+		// and enrichment
+		slotFlights.serve(w, req, id+"?"+req.URL.RawQuery, func(w http.ResponseWriter, req *http.Request) {
+			fetchSlot(w, req, id)
+		})
+	})))).Methods(http.MethodGet)
+
+	// GET /api/v1/slot/byblock/{execBlockNumber} (the slot route, looked up by
+	// execution block number)
+	handle(routeSlotByBlock, "/api/v1/slot/byblock/{execBlockNumber}", cacheResponses(respCache, func(w http.ResponseWriter, req *http.Request) {
+		number, err := strconv.ParseUint(mux.Vars(req)["execBlockNumber"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "execBlockNumber must be a block number")
+			return
+		}
+		slot, found, err := d.tracker.SlotForExecBlock(req.Context(), d.client, number)
+		if errors.Is(err, errExecBlockProbes) {
+			writeError(w, http.StatusServiceUnavailable, "execution block lookup gave up, too many blocks to fetch")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "failed to fetch blocks from consensus node")
+			return
+		}
+		if !found {
+			writeError(w, http.StatusNotFound, "execution block not found")
+			return
+		}
+		fetchSlot(w, req, strconv.FormatUint(slot, 10))
+	})).Methods(http.MethodGet)
+
+	// GET /api/v1/slot/{slotOrHash}/attestations (decoded from the consensus block)
+	handle(routeSlotAttest, "/api/v1/slot/{slotOrHash}/attestations", cacheResponses(respCache, func(w http.ResponseWriter, req *http.Request) {
+		id := mux.Vars(req)["slotOrHash"]
+		if id == "head" {
+			root, err := head.Resolve(req.Context())
+			if err != nil {
+				writeError(w, http.StatusBadGateway, "failed to resolve head")
 				return
 			}
-			data, _ := root["data"].(map[string]interface{})
-			if data == nil {
+			id = root
+		}
+		res, err := d.tracker.BlockAttestations(req.Context(), d.client, id)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "failed to fetch block from consensus node")
+			return
+		}
+		if res == nil {
+			writeError(w, http.StatusNotFound, "block not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, res)
+	})).Methods(http.MethodGet)
+
+	// GET /api/v1/slot/{slotOrHash}/committees (committee index -> validators)
+	handle(routeSlotCommittees, "/api/v1/slot/{slotOrHash}/committees", cacheResponses(respCache, func(w http.ResponseWriter, req *http.Request) {
+		id := mux.Vars(req)["slotOrHash"]
+		slot, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			// head or a block root: the block tells the slot
+			var found bool
+			slot, found, err = d.tracker.BlockSlot(req.Context(), d.client, id)
+			if err != nil {
+				writeError(w, http.StatusBadGateway, "failed to fetch block from consensus node")
+				return
+			}
+			if !found {
+				writeError(w, http.StatusNotFound, "block not found")
 				return
 			}
-			enrichSlotConsensus(req.Context(), client, cfg.ConsensusAPIURL, id, data)
-			root["data"] = buildSlotResponseFromMap(data)
 		}
-		proxyJSON(w, req, client, upstream, path, transform)
+		committees := d.tracker.SlotCommittees(req.Context(), d.client, slot)
+		if len(committees) == 0 {
+			writeError(w, http.StatusNotFound, "no committees for slot")
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, map[string]interface{}{"slot": slot, "committees": committees})
+	})).Methods(http.MethodGet)
+
+	// GET /api/v1/slots?from=X&to=Y (inclusive, enriched like the single slot route)
+	handle(routeSlots, "/api/v1/slots", timeHandler(routeSlots, cacheResponses(respCache, func(w http.ResponseWriter, req *http.Request) {
+		from, to, rerr := parseSlotRange(req.URL.Query(), cfg.SlotsRangeMax)
+		if rerr != nil {
+			writeCodedError(w, http.StatusBadRequest, rerr.Code, rerr.Message)
+			return
+		}
+		req, cancel := withBudget(req)
+		defer cancel()
+
+		const maxConcurrency = 8
+		results := make([]*SlotResponse, to-from+1)
+		sem := make(chan struct{}, maxConcurrency)
+		var wg sync.WaitGroup
+		var upstreamErrs atomic.Int64
+		// Walk by offset: from+i can't wrap, unlike s++ when to is MaxUint64
+		for i := uint64(0); i <= to-from; i++ {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(i uint64) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				id := strconv.FormatUint(from+i, 10)
+				body, status, err := proxy.fetchJSON(req.Context(), cfg.PathSlot+"/"+id)
+				if err != nil || (status != http.StatusOK && status != http.StatusNotFound) {
+					upstreamErrs.Add(1)
+					return
+				}
+				data, _ := body["data"].(map[string]interface{})
+				if data == nil {
+					return
+				}
+				slot := enrichSlot(req.Context(), id, data)
+				results[i] = &slot
+			}(i)
+		}
+		wg.Wait()
+		if upstreamErrs.Load() > 0 {
+			writeError(w, http.StatusBadGateway, "upstream failed for one or more slots")
+			return
+		}
+
+		slots := make([]SlotResponse, 0, len(results))
+		for _, s := range results {
+			if s != nil {
+				slots = append(slots, *s)
+			}
+		}
+		writeJSON(w, http.StatusOK, true, slots)
+	}))).Methods(http.MethodGet)
+
+	// GET /api/v1/attestation/{index} (straight from the last attestation cache)
+	handle(routeAttestation, "/api/v1/attestation/{index}", func(w http.ResponseWriter, req *http.Request) {
+		index, err := strconv.ParseUint(mux.Vars(req)["index"], 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "index must be a validator index")
+			return
+		}
+		slot, known := d.cache.GetOK(index)
+		if !known {
+			writeError(w, http.StatusNotFound, "no attestation known for validator")
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, map[string]interface{}{
+			"index":               index,
+			"lastattestationslot": slot,
+			"known":               true,
+		})
+	}).Methods(http.MethodGet)
+
+	// POST /api/v1/attestations (bulk lookup in the last attestation cache)
+	handle(routeAttestations, "/api/v1/attestations", func(w http.ResponseWriter, req *http.Request) {
+		body, ok := proxy.readBody(w, req)
+		if !ok {
+			return
+		}
+		var indices []uint64
+		if err := json.Unmarshal(body, &indices); err != nil {
+			writeError(w, http.StatusBadRequest, "body must be a JSON array of validator indices")
+			return
+		}
+		if len(indices) > cfg.AttestationsBatchMax {
+			writeError(w, http.StatusBadRequest, "at most "+strconv.Itoa(cfg.AttestationsBatchMax)+" indices per request")
+			return
+		}
+		slots := make(map[string]uint64, len(indices))
+		unknown := []uint64{}
+		for _, index := range indices {
+			if slot, known := d.cache.GetOK(index); known {
+				slots[strconv.FormatUint(index, 10)] = slot
+			} else {
+				unknown = append(unknown, index)
+			}
+		}
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, map[string]interface{}{
+			"lastattestationslots": slots,
+			"unknown":              unknown,
+		})
+	}).Methods(http.MethodPost)
+
+	// GET /api/v1/config (network parameters detected at startup)
+	handle(routeConfig, "/api/v1/config", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, map[string]interface{}{
+			"fork_schedule": d.network.ForkSchedule(),
+		})
+	}).Methods(http.MethodGet)
+
+	// GET /metrics (Prometheus text format)
+	handle(routeMetrics, "/metrics", defaultRegistry.Handler().ServeHTTP).Methods(http.MethodGet)
+
+	// GET /api/v1/internal/status (proxy process information)
+	handle(routeInternalStatus, "/api/v1/internal/status", func(w http.ResponseWriter, req *http.Request) {
+		status := map[string]interface{}{
+			"started_at":     d.startedAt.UTC().Format(time.RFC3339),
+			"uptime_seconds": int64(time.Since(d.startedAt).Seconds()),
+			"scan_lag_slots": nil,
+			"consensus":      d.consensus.Status(),
+		}
+		if lag, ok := d.tracker.ScanLag(); ok {
+			status["scan_lag_slots"] = lag
+		}
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, status)
+	}).Methods(http.MethodGet)
+
+	// GET /api/v1/internal/caches (size, TTL, entry ages and hit rate per cache)
+	handle(routeInternalCaches, "/api/v1/internal/caches", func(w http.ResponseWriter, req *http.Request) {
+		stats := []cacheStats{d.cache.Stats(), head.Stats(), balances.Stats()}
+		if respCache != nil {
+			stats = append(stats, respCache.Stats())
+		}
+		if d.pubkeys != nil {
+			stats = append(stats, d.pubkeys.Stats())
+		}
+		if committees := d.tracker.Committees(); committees != nil {
+			stats = append(stats, committees.Stats())
+		}
+		if blocks := d.tracker.Blocks(); blocks != nil {
+			stats = append(stats, blocks.Stats())
+		}
+		if finality != nil {
+			stats = append(stats, finality.Stats())
+		}
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, stats)
+	}).Methods(http.MethodGet)
+
+	// GET /api/v1/internal/cache?from=X&to=Y dumps the last attestation cache
+	// (optionally for a validator index range); DELETE clears it and starts a
+	// new backfill. Both need PROXY_API_KEY to be set.
+	var rebackfilling atomic.Bool
+	handle(routeInternalCache, "/api/v1/internal/cache", func(w http.ResponseWriter, req *http.Request) {
+		if cfg.APIKey == "" {
+			writeError(w, http.StatusForbidden, "requires PROXY_API_KEY to be set")
+			return
+		}
+		if req.Method == http.MethodDelete {
+			cleared := d.cache.Reset()
+			started := rebackfilling.CompareAndSwap(false, true)
+			if started {
+				go func() {
+					defer rebackfilling.Store(false)
+					ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+					defer cancel()
+					if err := d.tracker.Backfill(ctx); err != nil {
+						d.log.WithError(err).Warn("backfill after cache reset returned with error")
+					}
+				}()
+			}
+			d.log.WithField("cleared", cleared).Info("last attestation cache reset")
+			writeJSON(w, http.StatusAccepted, cfg.WrapEnvelope, map[string]interface{}{
+				"cleared":          cleared,
+				"backfill_started": started,
+			})
+			return
+		}
+		q := req.URL.Query()
+		from, to := uint64(0), uint64(math.MaxUint64)
+		for _, p := range []struct {
+			name string
+			dst  *uint64
+		}{{"from", &from}, {"to", &to}} {
+			if v := q.Get(p.name); v != "" {
+				n, err := strconv.ParseUint(v, 10, 64)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, p.name+" must be a validator index")
+					return
+				}
+				*p.dst = n
+			}
+		}
+		validators := d.cache.Snapshot(from, to)
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, map[string]interface{}{
+			"entries":    len(validators),
+			"validators": validators,
+		})
+	}).Methods(http.MethodGet, http.MethodDelete)
+
+	// GET /api/v1/spec (routes and enabled features of this proxy)
+	handle(routeSpec, "/api/v1/spec", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, cfg.WrapEnvelope, buildSpec(registered, cfg))
+	}).Methods(http.MethodGet)
+
+	// GET /healthz (liveness) and /readyz (scanner health); always enabled
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, false, map[string]string{"status": "ok"})
+	}).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", func(w http.ResponseWriter, req *http.Request) {
+		inGrace := time.Since(d.startedAt) < cfg.ScannerStartupGrace
+		health := d.tracker.Health(cfg.ScannerMaxFailedTicks, inGrace)
+		status, code := "ready", http.StatusOK
+		if !health.Healthy {
+			status, code = "degraded", http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, false, map[string]interface{}{"status": status, "scanner": health})
+	}).Methods(http.MethodGet)
+
+	// GET /version (build information); always enabled
+	r.HandleFunc("/version", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, false, buildInfo())
 	}).Methods(http.MethodGet)
 
-	return r
+	var h http.Handler = r
+	if cfg.ReadOnly {
+		h = readOnlyMiddleware(h)
+	}
+	h = apiKeyMiddleware(cfg.APIKey)(h)
+	if cfg.ClientRPS > 0 {
+		h = NewRateLimiter(cfg.ClientRPS, cfg.ClientBurst, cfg.TrustForwardedFor).Middleware(h)
+	}
+	h = corsMiddleware(cfg.CORSOrigins)(h)
+	h = loggingMiddleware(d.log)(h)
+	return h
 }
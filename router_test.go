@@ -0,0 +1,376 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInternalStatusUptime(t *testing.T) {
+	d := newTestDeps(t, newTestConfig(t), newTestServer(t, http.NotFound).URL, "")
+	d.startedAt = time.Now().Add(-time.Minute)
+	h := buildRouter(d)
+
+	first := decodeJSON(t, serve(h, http.MethodGet, "/api/v1/internal/status", ""))
+	time.Sleep(1100 * time.Millisecond)
+	second := decodeJSON(t, serve(h, http.MethodGet, "/api/v1/internal/status", ""))
+
+	if first["started_at"] != d.startedAt.UTC().Format(time.RFC3339) || second["started_at"] != first["started_at"] {
+		t.Errorf("started_at = %v then %v, want %s both times", first["started_at"], second["started_at"], d.startedAt.UTC().Format(time.RFC3339))
+	}
+	up1, _ := first["uptime_seconds"].(float64)
+	up2, _ := second["uptime_seconds"].(float64)
+	if up1 < 60 || up2 <= up1 {
+		t.Fatalf("uptime_seconds = %v then %v, want at least 60 and increasing", up1, up2)
+	}
+}
+
+func TestDisabledRoutes(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusForbidden} {
+		calls := 0
+		dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+			calls++
+			jsonHandler(http.StatusOK, `{"status":"OK","data":{"epoch":3}}`)(w, req)
+		})
+		t.Setenv("PROXY_ROUTE_SLOT_ENABLED", "false")
+		t.Setenv("PROXY_DISABLED_ROUTE_STATUS", strconv.Itoa(status))
+		d := newTestDeps(t, newTestConfig(t), dora.URL, "")
+		h := buildRouter(d)
+
+		rec := serve(h, http.MethodGet, "/api/v1/slot/5", "")
+		if rec.Code != status || calls != 0 {
+			t.Errorf("disabled route: status = %d after %d upstream calls, want %d and none", rec.Code, calls, status)
+		}
+		if rec := serve(h, http.MethodGet, "/api/v1/epoch/latest", ""); rec.Code != http.StatusOK {
+			t.Errorf("enabled route: status = %d, want 200", rec.Code)
+		}
+	}
+}
+
+func TestDisabledRouteStatusValidated(t *testing.T) {
+	t.Setenv("PROXY_DISABLED_ROUTE_STATUS", "500")
+	if _, err := loadConfig(); err == nil {
+		t.Fatal("loadConfig accepted a disabled route status of 500")
+	}
+}
+
+// A consensus node that never answers cannot hold up a slot response past
+// the enrichment deadline, and the abandoned calls are cancelled.
+func TestSlotEnrichmentDeadline(t *testing.T) {
+	cancelled := make(chan struct{}, 16)
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+			cancelled <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	})
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0,"proposer":7}}`))
+	cfg := newTestConfig(t)
+	cfg.EnrichTimeout = 50 * time.Millisecond
+	h := buildRouter(newTestDeps(t, cfg, dora.URL, consensus.URL))
+
+	start := time.Now()
+	rec := serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("slot response took %v", elapsed)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := decodeJSON(t, rec)["data"].(map[string]interface{})
+	if data["enriched"] != false || data["proposer"] != float64(7) {
+		t.Fatalf("data = %v, want Dora's slot unenriched", data)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("consensus call not cancelled")
+	}
+}
+
+func TestRequestBudgetWithSlowConsensus(t *testing.T) {
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0,"proposer":7}}`))
+	cfg := newTestConfig(t)
+	cfg.EnrichTimeout = 0
+	cfg.RequestBudget = 200 * time.Millisecond
+	h := buildRouter(newTestDeps(t, cfg, dora.URL, consensus.URL))
+
+	start := time.Now()
+	rec := serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("slot response took %v with a 200ms budget", elapsed)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := decodeJSON(t, rec)["data"].(map[string]interface{})
+	if data["enriched"] != false || data["proposer"] != float64(7) {
+		t.Fatalf("data = %v, want what Dora gave, unenriched", data)
+	}
+}
+
+// Upstream retries stop once another round would not fit in the budget.
+func TestRequestBudgetStopsRetries(t *testing.T) {
+	calls := 0
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	cfg := newTestConfig(t)
+	cfg.UpstreamMaxAttempts = 10
+	cfg.UpstreamRetryBackoff = 100 * time.Millisecond
+	cfg.RequestBudget = 250 * time.Millisecond
+	h := buildRouter(newTestDeps(t, cfg, dora.URL, ""))
+
+	start := time.Now()
+	serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("slot response took %v with a 250ms budget", elapsed)
+	}
+	if calls >= 10 {
+		t.Fatalf("%d upstream attempts, want retries cut short by the budget", calls)
+	}
+}
+
+func TestSlotEnrichmentFailureReported(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0,"proposer":7,"blockroot":"0xroot"}}`))
+	dead := newTestServer(t, http.NotFound)
+	dead.Close()
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, dead.URL))
+
+	rec := serve(h, http.MethodGet, "/api/v1/slot/5", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want Dora's slot despite the consensus failure", rec.Code)
+	}
+	data, _ := decodeJSON(t, rec)["data"].(map[string]interface{})
+	if data["enriched"] != false {
+		t.Errorf("enriched = %v, want false", data["enriched"])
+	}
+	if msg, _ := data["enrichment_error"].(string); msg == "" {
+		t.Error("enrichment_error missing")
+	}
+	if data["blockroot"] != "0xroot" || data["proposer"] != float64(7) {
+		t.Errorf("data = %v, want Dora's fields kept", data)
+	}
+}
+
+func TestSlotEnrichedHasNoError(t *testing.T) {
+	dora := newTestServer(t, jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0}}`))
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/eth/v2/beacon/blocks/5" {
+			jsonHandler(http.StatusOK, blockJSON(5, ""))(w, req)
+			return
+		}
+		http.NotFound(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, consensus.URL))
+
+	data, _ := decodeJSON(t, serve(h, http.MethodGet, "/api/v1/slot/5", ""))["data"].(map[string]interface{})
+	if data["enriched"] != true {
+		t.Errorf("enriched = %v, want true", data["enriched"])
+	}
+	if _, has := data["enrichment_error"]; has {
+		t.Errorf("enrichment_error = %v on success", data["enrichment_error"])
+	}
+}
+
+func TestReadyzStartupGrace(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.ScannerMaxFailedTicks = 3
+	cfg.ScannerStartupGrace = time.Minute
+	d := newTestDeps(t, cfg, newTestServer(t, http.NotFound).URL, "")
+	for i := 0; i < 5; i++ {
+		d.tracker.recordTick(false)
+	}
+	h := buildRouter(d)
+
+	d.startedAt = time.Now()
+	rec := serve(h, http.MethodGet, "/readyz", "")
+	m := decodeJSON(t, rec)
+	scanner, _ := m["scanner"].(map[string]interface{})
+	if rec.Code != http.StatusOK || m["status"] != "ready" || scanner["in_grace"] != true || scanner["failed_ticks"] != float64(5) {
+		t.Fatalf("in grace: status %d, body %s; want ready with the failures reported", rec.Code, rec.Body.String())
+	}
+
+	d.startedAt = time.Now().Add(-2 * time.Minute)
+	rec = serve(h, http.MethodGet, "/readyz", "")
+	if rec.Code != http.StatusServiceUnavailable || decodeJSON(t, rec)["status"] != "degraded" {
+		t.Fatalf("after grace: status %d, body %s; want degraded", rec.Code, rec.Body.String())
+	}
+
+	d.tracker.recordTick(true)
+	if rec := serve(h, http.MethodGet, "/readyz", ""); rec.Code != http.StatusOK {
+		t.Fatalf("after a good tick: status %d, want ready", rec.Code)
+	}
+}
+
+func TestScannerHealth(t *testing.T) {
+	tr := newTestTracker(t, newTestConfig(t), newTestServer(t, http.NotFound).URL)
+	tr.recordTick(false)
+	tr.recordTick(false)
+	tests := []struct {
+		max     int
+		inGrace bool
+		want    bool
+	}{
+		{3, false, true},
+		{2, false, false},
+		{2, true, true},
+		{0, false, true}, // health checks disabled
+	}
+	for _, tt := range tests {
+		if got := tr.Health(tt.max, tt.inGrace).Healthy; got != tt.want {
+			t.Errorf("Health(%d, %v) healthy = %v, want %v", tt.max, tt.inGrace, got, tt.want)
+		}
+	}
+}
+
+func TestAttestationRoute(t *testing.T) {
+	calls := 0
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) { calls++ })
+	d := newTestDeps(t, newTestConfig(t), dora.URL, "")
+	d.cache.SetIfGreater(1, 640)
+	d.cache.SetIfGreater(2, 0)
+	h := buildRouter(d)
+
+	tests := []struct {
+		index    string
+		wantCode int
+		wantSlot interface{}
+	}{
+		{"1", http.StatusOK, float64(640)},
+		{"2", http.StatusOK, float64(0)}, // known, attested in slot 0
+		{"3", http.StatusNotFound, nil},
+		{"x", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := serve(h, http.MethodGet, "/api/v1/attestation/"+tt.index, "")
+		if rec.Code != tt.wantCode {
+			t.Errorf("index %s: status %d, want %d", tt.index, rec.Code, tt.wantCode)
+			continue
+		}
+		m := decodeJSON(t, rec)
+		if tt.wantCode == http.StatusOK && (m["lastattestationslot"] != tt.wantSlot || m["known"] != true) {
+			t.Errorf("index %s: body %s, want slot %v", tt.index, rec.Body.String(), tt.wantSlot)
+		}
+		if tt.wantCode != http.StatusOK && m["status"] != "error" {
+			t.Errorf("index %s: body %s, want a JSON error", tt.index, rec.Body.String())
+		}
+	}
+	if calls != 0 {
+		t.Fatalf("upstream called %d times, want none", calls)
+	}
+}
+
+func TestAttestationsBulkRoute(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.AttestationsBatchMax = 4
+	d := newTestDeps(t, cfg, newTestServer(t, http.NotFound).URL, "")
+	d.cache.SetIfGreater(1, 640)
+	d.cache.SetIfGreater(3, 700)
+	h := buildRouter(d)
+
+	rec := serve(h, http.MethodPost, "/api/v1/attestations", `[1,2,3,4]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	want := `{"lastattestationslots":{"1":640,"3":700},"unknown":[2,4]}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Fatalf("body = %s, want %s", got, want)
+	}
+
+	for body, wantCode := range map[string]int{
+		`[1,2,3,4,5]`: http.StatusBadRequest, // over the batch max
+		`{"1":true}`:  http.StatusBadRequest,
+		`[-1]`:        http.StatusBadRequest,
+		`[]`:          http.StatusOK,
+	} {
+		if rec := serve(h, http.MethodPost, "/api/v1/attestations", body); rec.Code != wantCode {
+			t.Errorf("body %s: status %d, want %d", body, rec.Code, wantCode)
+		}
+	}
+}
+
+func TestInternalCacheRoute(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.APIKey = "secret"
+	d := newTestDeps(t, cfg, "http://127.0.0.1:1", "")
+	d.cache.SetIfGreater(1, 100)
+	d.cache.SetIfGreater(5, 500)
+	h := buildRouter(d)
+	send := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-API-Key", "secret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(h, http.MethodGet, "/api/v1/internal/cache", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without the key = %d, want 401", rec.Code)
+	}
+
+	m := decodeJSON(t, send(http.MethodGet, "/api/v1/internal/cache"))
+	if m["entries"] != float64(2) || !reflect.DeepEqual(m["validators"], map[string]interface{}{"1": float64(100), "5": float64(500)}) {
+		t.Errorf("dump = %v", m)
+	}
+	m = decodeJSON(t, send(http.MethodGet, "/api/v1/internal/cache?from=2&to=10"))
+	if m["entries"] != float64(1) {
+		t.Errorf("dump of 2..10 = %v, want validator 5 only", m)
+	}
+	if rec := send(http.MethodGet, "/api/v1/internal/cache?from=x"); rec.Code != http.StatusBadRequest {
+		t.Errorf("status with a bad from = %d, want 400", rec.Code)
+	}
+
+	rec := send(http.MethodDelete, "/api/v1/internal/cache")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("reset status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if m := decodeJSON(t, rec); m["cleared"] != float64(2) || m["backfill_started"] != true {
+		t.Errorf("reset = %v", m)
+	}
+	if m := decodeJSON(t, send(http.MethodGet, "/api/v1/internal/cache")); m["entries"] != float64(0) {
+		t.Errorf("dump after reset = %v, want no entries", m)
+	}
+}
+
+func TestInternalCacheRouteNeedsAPIKey(t *testing.T) {
+	h := buildRouter(newTestDeps(t, newTestConfig(t), "http://127.0.0.1:1", ""))
+	if rec := serve(h, http.MethodGet, "/api/v1/internal/cache", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("status without PROXY_API_KEY = %d, want 403", rec.Code)
+	}
+}
+
+func TestSlotRouteOverriddenPath(t *testing.T) {
+	var got []string
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		got = append(got, req.URL.Path)
+		if !strings.HasSuffix(req.URL.Path, "/v2/slots/5") {
+			http.NotFound(w, req)
+			return
+		}
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":5,"epoch":0,"blockroot":"0xroot"}}`)(w, req)
+	})
+	cfg := newTestConfig(t)
+	cfg.PathSlot = "/v2/slots"
+
+	rec := serve(buildRouter(newTestDeps(t, cfg, dora.URL, "")), http.MethodGet, "/api/v1/slot/5", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, upstream paths %v", rec.Code, got)
+	}
+	data, _ := decodeJSON(t, rec)["data"].(map[string]interface{})
+	if data["blockroot"] != "0xroot" {
+		t.Errorf("data = %v, want the slot from the overridden path", data)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// sharedResponses lets concurrent identical requests share one run of a
+// handler: the first caller for a key runs it, and every caller waiting on
+// the same key gets a copy of its response.
+type sharedResponses struct {
+	g singleflight.Group
+}
+
+// recordedResponse is a handler response captured for replay.
+type recordedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recordedResponse) Header() http.Header { return r.header }
+
+func (r *recordedResponse) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *recordedResponse) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+// serve answers req with h's response, sharing one run of h among
+// concurrent requests with the same key. h runs detached from the caller's
+// cancellation, so one client going away does not fail the others.
+func (s *sharedResponses) serve(w http.ResponseWriter, req *http.Request, key string, h http.HandlerFunc) {
+	v, _, _ := s.g.Do(key, func() (interface{}, error) {
+		rec := &recordedResponse{header: make(http.Header)}
+		h(rec, req.WithContext(context.WithoutCancel(req.Context())))
+		return rec, nil
+	})
+	rec := v.(*recordedResponse)
+	for k, vv := range rec.header {
+		w.Header()[k] = append([]string(nil), vv...)
+	}
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	w.WriteHeader(rec.status)
+	w.Write(rec.body.Bytes())
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrentSlotHeadRequestsShareFetch(t *testing.T) {
+	var fetches atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if fetches.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":10,"epoch":0}}`)(w, req)
+	})
+	consensus := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/eth/v1/beacon/headers/head" {
+			http.NotFound(w, req)
+			return
+		}
+		jsonHandler(http.StatusOK, `{"data":{"root":"0xhead"}}`)(w, req)
+	})
+	h := buildRouter(newTestDeps(t, newTestConfig(t), dora.URL, consensus.URL))
+
+	const clients = 10
+	bodies := make([]string, clients)
+	codes := make([]int, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := serve(h, http.MethodGet, "/api/v1/slot/head", "")
+			codes[i], bodies[i] = rec.Code, rec.Body.String()
+		}(i)
+	}
+	<-started
+	time.Sleep(100 * time.Millisecond) // let the other requests join the flight
+	close(release)
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("%d upstream fetches for %d concurrent requests, want 1", n, clients)
+	}
+	for i := range codes {
+		if codes[i] != http.StatusOK || bodies[i] != bodies[0] {
+			t.Errorf("client %d: status %d, body %s", i, codes[i], bodies[i])
+		}
+	}
+}
@@ -1,34 +1,37 @@
 package main
 
-import "strconv"
+import (
+	"encoding/json"
+	"strconv"
+)
 
 // DoraSlotData represents fields returned by the original Dora upstream.
 type DoraSlotData struct {
-	AttestationsCount          uint64  `json:"attestationscount"`
-	AttesterSlashingsCount     uint64  `json:"attesterslashingscount"`
-	BlockRoot                  string  `json:"blockroot"`
-	DepositsCount              uint64  `json:"depositscount"`
-	Epoch                      uint64  `json:"epoch"`
-	ExecBaseFeePerGas          uint64  `json:"exec_base_fee_per_gas"`
-	ExecBlockHash              string  `json:"exec_block_hash"`
-	ExecBlockNumber            uint64  `json:"exec_block_number"`
-	ExecExtraData              string  `json:"exec_extra_data"`
-	ExecFeeRecipient           string  `json:"exec_fee_recipient"`
-	ExecGasLimit               uint64  `json:"exec_gas_limit"`
-	ExecGasUsed                uint64  `json:"exec_gas_used"`
-	ExecTransactionsCount      uint64  `json:"exec_transactions_count"`
-	Graffiti                   string  `json:"graffiti"`
-	GraffitiText               string  `json:"graffiti_text"`
-	ParentRoot                 string  `json:"parentroot"`
-	Proposer                   uint64  `json:"proposer"`
-	ProposerSlashingsCount     uint64  `json:"proposerslashingscount"`
-	Slot                       uint64  `json:"slot"`
-	StateRoot                  string  `json:"stateroot"`
-	Status                     string  `json:"status"`
-	SyncAggregateParticipation float64 `json:"syncaggregate_participation"`
-	VoluntaryExitsCount        uint64  `json:"voluntaryexitscount"`
-	WithdrawalCount            uint64  `json:"withdrawalcount"`
-	BlobCount                  uint64  `json:"blob_count"`
+	AttestationsCount          uint64         `json:"attestationscount"`
+	AttesterSlashingsCount     uint64         `json:"attesterslashingscount"`
+	BlockRoot                  string         `json:"blockroot"`
+	DepositsCount              uint64         `json:"depositscount"`
+	Epoch                      uint64         `json:"epoch"`
+	ExecBaseFeePerGas          uint64         `json:"exec_base_fee_per_gas"`
+	ExecBlockHash              string         `json:"exec_block_hash"`
+	ExecBlockNumber            uint64         `json:"exec_block_number"`
+	ExecExtraData              string         `json:"exec_extra_data"`
+	ExecFeeRecipient           string         `json:"exec_fee_recipient"`
+	ExecGasLimit               uint64         `json:"exec_gas_limit"`
+	ExecGasUsed                uint64         `json:"exec_gas_used"`
+	ExecTransactionsCount      uint64         `json:"exec_transactions_count"`
+	Graffiti                   string         `json:"graffiti"`
+	GraffitiText               string         `json:"graffiti_text"`
+	ParentRoot                 string         `json:"parentroot"`
+	Proposer                   uint64         `json:"proposer"`
+	ProposerSlashingsCount     uint64         `json:"proposerslashingscount"`
+	Slot                       uint64         `json:"slot"`
+	StateRoot                  string         `json:"stateroot"`
+	Status                     string         `json:"status"`
+	SyncAggregateParticipation precisionFloat `json:"syncaggregate_participation"`
+	VoluntaryExitsCount        uint64         `json:"voluntaryexitscount"`
+	WithdrawalCount            uint64         `json:"withdrawalcount"`
+	BlobCount                  uint64         `json:"blob_count"`
 }
 
 // BeaconMissingFields represents fields that Beacon has but Dora does not.
@@ -53,9 +56,70 @@ type BeaconMissingFields struct {
 type SlotResponse struct {
 	DoraSlotData
 	BeaconMissingFields
+
+	// Fork is the fork active at the slot's epoch, when the schedule is known.
+	Fork string `json:"fork,omitempty"`
+
+	// SlotTime is the slot's start (unix seconds), when genesis is known.
+	SlotTime uint64 `json:"slot_time,omitempty"`
+
+	// WithdrawalTotalAmount is the sum of the block's withdrawal amounts in gwei.
+	WithdrawalTotalAmount uint64 `json:"withdrawal_total_amount"`
+
+	AttesterSlashings []attesterSlashing `json:"attester_slashings"`
+	ProposerSlashings []proposerSlashing `json:"proposer_slashings"`
+	VoluntaryExits    []voluntaryExit    `json:"voluntary_exits"`
+	Deposits          []deposit          `json:"deposits"`
+
+	// Enriched reports whether the consensus block was fetched; when false,
+	// EnrichmentError says why the Beacon-missing fields may be absent.
+	Enriched        bool   `json:"enriched"`
+	EnrichmentError string `json:"enrichment_error,omitempty"`
+}
+
+// attesterSlashing lists the validators slashed by one attester slashing:
+// those attesting in both conflicting attestations.
+type attesterSlashing struct {
+	Validators []uint64 `json:"validators"`
+}
+
+// proposerSlashing is a proposer slashed for signing two block headers.
+type proposerSlashing struct {
+	ProposerIndex uint64 `json:"proposer_index"`
+	Header1Slot   uint64 `json:"header_1_slot"`
+	Header2Slot   uint64 `json:"header_2_slot"`
+}
+
+// voluntaryExit is a validator's signed request to exit at Epoch.
+type voluntaryExit struct {
+	ValidatorIndex uint64 `json:"validator_index"`
+	Epoch          uint64 `json:"epoch"`
+}
+
+// deposit is a deposit included in the block; Amount is in gwei.
+type deposit struct {
+	Pubkey                string `json:"pubkey"`
+	Amount                uint64 `json:"amount"`
+	WithdrawalCredentials string `json:"withdrawal_credentials"`
+}
+
+// precisionFloat marshals with a fixed number of decimals, or with Go's
+// default formatting when precision is negative.
+type precisionFloat struct {
+	Value     float64
+	Precision int
+}
+
+func (f precisionFloat) MarshalJSON() ([]byte, error) {
+	if f.Precision < 0 {
+		return json.Marshal(f.Value)
+	}
+	return []byte(strconv.FormatFloat(f.Value, 'f', f.Precision, 64)), nil
 }
 
-func buildSlotResponseFromMap(m map[string]interface{}) SlotResponse {
+// buildSlotResponseFromMap projects slot data into SlotResponse. floatPrecision
+// is the number of decimals float fields are rendered with (negative for raw).
+func buildSlotResponseFromMap(m map[string]interface{}, floatPrecision int) SlotResponse {
 	return SlotResponse{
 		DoraSlotData: DoraSlotData{
 			AttestationsCount:          asUint(m["attestationscount"]),
@@ -79,7 +143,7 @@ func buildSlotResponseFromMap(m map[string]interface{}) SlotResponse {
 			Slot:                       asUint(m["slot"]),
 			StateRoot:                  asString(m["stateroot"]),
 			Status:                     asString(m["status"]),
-			SyncAggregateParticipation: asFloat(m["syncaggregate_participation"]),
+			SyncAggregateParticipation: precisionFloat{Value: asFloat(m["syncaggregate_participation"]), Precision: floatPrecision},
 			VoluntaryExitsCount:        asUint(m["voluntaryexitscount"]),
 			WithdrawalCount:            asUint(m["withdrawalcount"]),
 			BlobCount:                  asUint(m["blob_count"]),
@@ -99,13 +163,68 @@ func buildSlotResponseFromMap(m map[string]interface{}) SlotResponse {
 			SyncaggregateBits:      asString(m["syncaggregate_bits"]),
 			SyncaggregateSignature: asString(m["syncaggregate_signature"]),
 		},
+		WithdrawalTotalAmount: asUint(m["withdrawal_total_amount"]),
+		AttesterSlashings:     asAttesterSlashings(m["attester_slashings"]),
+		ProposerSlashings:     asProposerSlashings(m["proposer_slashings"]),
+		VoluntaryExits:        asVoluntaryExits(m["voluntary_exits"]),
+		Deposits:              asDeposits(m["deposits"]),
+	}
+}
+
+func asAttesterSlashings(v interface{}) []attesterSlashing {
+	if s, ok := v.([]attesterSlashing); ok {
+		return s
+	}
+	return []attesterSlashing{}
+}
+
+func asProposerSlashings(v interface{}) []proposerSlashing {
+	if s, ok := v.([]proposerSlashing); ok {
+		return s
+	}
+	return []proposerSlashing{}
+}
+
+func asVoluntaryExits(v interface{}) []voluntaryExit {
+	if s, ok := v.([]voluntaryExit); ok {
+		return s
 	}
+	return []voluntaryExit{}
+}
+
+func asDeposits(v interface{}) []deposit {
+	if s, ok := v.([]deposit); ok {
+		return s
+	}
+	return []deposit{}
 }
 
 func asUint(v interface{}) uint64 {
 	switch t := v.(type) {
 	case float64:
 		return uint64(t)
+	case json.Number:
+		if n, err := strconv.ParseUint(t.String(), 10, 64); err == nil {
+			return n
+		}
+		// e.g. "1e3" or "12.0"
+		f, err := t.Float64()
+		if err != nil || f < 0 {
+			return 0
+		}
+		return uint64(f)
+	case int:
+		if t < 0 {
+			return 0
+		}
+		return uint64(t)
+	case int64:
+		if t < 0 {
+			return 0
+		}
+		return uint64(t)
+	case uint64:
+		return t
 	case string:
 		if t == "" {
 			return 0
@@ -124,6 +243,18 @@ func asFloat(v interface{}) float64 {
 	switch t := v.(type) {
 	case float64:
 		return t
+	case json.Number:
+		f, err := t.Float64()
+		if err != nil {
+			return 0
+		}
+		return f
+	case int:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint64:
+		return float64(t)
 	case string:
 		if t == "" {
 			return 0
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPrecisionFloat(t *testing.T) {
+	tests := []struct {
+		value     float64
+		precision int
+		want      string
+	}{
+		{0.9765625, 4, "0.9766"},
+		{1, 4, "1.0000"},
+		{0.00001, 4, "0.0000"},
+		{1e-7, -1, "1e-7"},
+		{0.9765625, -1, "0.9765625"},
+		{0.5, 0, "0"},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(precisionFloat{Value: tt.value, Precision: tt.precision})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("precisionFloat{%v, %d} = %s, want %s", tt.value, tt.precision, b, tt.want)
+		}
+	}
+}
+
+func TestSlotResponseParticipationPrecision(t *testing.T) {
+	m := map[string]interface{}{"slot": json.Number("5"), "syncaggregate_participation": json.Number("0.98046875")}
+	for precision, want := range map[int]string{4: `"syncaggregate_participation":0.9805`, -1: `"syncaggregate_participation":0.98046875`} {
+		b, err := json.Marshal(buildSlotResponseFromMap(m, precision))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), want) {
+			t.Errorf("precision %d: %s does not contain %s", precision, b, want)
+		}
+	}
+}
+
+func TestAsUint(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want uint64
+	}{
+		{float64(12), 12},
+		{json.Number("18446744073709551615"), 18446744073709551615},
+		{json.Number("1e3"), 1000},
+		{json.Number("-1"), 0},
+		{int(7), 7},
+		{int(-7), 0},
+		{int64(8), 8},
+		{int64(-8), 0},
+		{uint64(9), 9},
+		{"10", 10},
+		{"", 0},
+		{"x", 0},
+		{true, 0}, // unsupported
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := asUint(tt.in); got != tt.want {
+			t.Errorf("asUint(%#v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAsFloat(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want float64
+	}{
+		{float64(0.5), 0.5},
+		{json.Number("0.25"), 0.25},
+		{json.Number("x"), 0},
+		{int(2), 2},
+		{int64(3), 3},
+		{uint64(4), 4},
+		{"0.75", 0.75},
+		{"", 0},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		if got := asFloat(tt.in); got != tt.want {
+			t.Errorf("asFloat(%#v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Counts decoded with UseNumber survive the projection into SlotResponse.
+func TestBuildSlotResponseFromNumbers(t *testing.T) {
+	var m map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(`{"slot":100,"epoch":3,"attestationscount":128,"depositscount":"2"}`))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	r := buildSlotResponseFromMap(m, -1)
+	if r.Slot != 100 || r.Epoch != 3 || r.AttestationsCount != 128 || r.DepositsCount != 2 {
+		t.Fatalf("got slot %d epoch %d attestations %d deposits %d", r.Slot, r.Epoch, r.AttestationsCount, r.DepositsCount)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Error codes returned by the slots range endpoint.
+const (
+	rangeErrMissingFrom   = "MISSING_FROM"
+	rangeErrMissingTo     = "MISSING_TO"
+	rangeErrInvalidFrom   = "INVALID_FROM"
+	rangeErrInvalidTo     = "INVALID_TO"
+	rangeErrNegativeFrom  = "NEGATIVE_FROM"
+	rangeErrNegativeTo    = "NEGATIVE_TO"
+	rangeErrInverted      = "RANGE_INVERTED"
+	rangeErrRangeTooLarge = "RANGE_TOO_LARGE"
+)
+
+// rangeError describes why a slots range was rejected.
+type rangeError struct {
+	Code    string
+	Message string
+}
+
+func (e *rangeError) Error() string { return e.Message }
+
+// parseSlotRange validates the from/to query params of /api/v1/slots. Both
+// bounds are inclusive and the range may span at most maxSlots slots.
+func parseSlotRange(q url.Values, maxSlots uint64) (uint64, uint64, *rangeError) {
+	from, rerr := parseSlotBound(q, "from", rangeErrMissingFrom, rangeErrNegativeFrom, rangeErrInvalidFrom)
+	if rerr != nil {
+		return 0, 0, rerr
+	}
+	to, rerr := parseSlotBound(q, "to", rangeErrMissingTo, rangeErrNegativeTo, rangeErrInvalidTo)
+	if rerr != nil {
+		return 0, 0, rerr
+	}
+	if from > to {
+		return 0, 0, &rangeError{Code: rangeErrInverted, Message: "from must not be greater than to"}
+	}
+	if to-from >= maxSlots {
+		return 0, 0, &rangeError{Code: rangeErrRangeTooLarge, Message: "range must not span more than " + strconv.FormatUint(maxSlots, 10) + " slots"}
+	}
+	return from, to, nil
+}
+
+func parseSlotBound(q url.Values, name, missing, negative, invalid string) (uint64, *rangeError) {
+	raw := strings.TrimSpace(q.Get(name))
+	if raw == "" {
+		return 0, &rangeError{Code: missing, Message: name + " is required"}
+	}
+	if strings.HasPrefix(raw, "-") {
+		if _, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return 0, &rangeError{Code: negative, Message: name + " must not be negative"}
+		}
+	}
+	n, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return 0, &rangeError{Code: invalid, Message: name + " must be a slot number"}
+	}
+	return n, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestParseSlotRange(t *testing.T) {
+	tests := []struct {
+		query    string
+		from, to uint64
+		wantCode string
+	}{
+		{"from=10&to=20", 10, 20, ""},
+		{"from=7&to=7", 7, 7, ""},
+		{"from=0&to=31", 0, 31, ""},
+		{"from=18446744073709551615&to=18446744073709551615", math.MaxUint64, math.MaxUint64, ""},
+		{"from=18446744073709551584&to=18446744073709551615", math.MaxUint64 - 31, math.MaxUint64, ""},
+		{"from=%2010%20&to=12", 10, 12, ""},
+		{"to=5", 0, 0, rangeErrMissingFrom},
+		{"from=&to=5", 0, 0, rangeErrMissingFrom},
+		{"from=5", 0, 0, rangeErrMissingTo},
+		{"from=abc&to=5", 0, 0, rangeErrInvalidFrom},
+		{"from=5&to=1.5", 0, 0, rangeErrInvalidTo},
+		{"from=99999999999999999999&to=5", 0, 0, rangeErrInvalidFrom},
+		{"from=-1&to=5", 0, 0, rangeErrNegativeFrom},
+		{"from=1&to=-5", 0, 0, rangeErrNegativeTo},
+		{"from=-x&to=5", 0, 0, rangeErrInvalidFrom},
+		{"from=20&to=10", 0, 0, rangeErrInverted},
+		{"from=0&to=32", 0, 0, rangeErrRangeTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, to, rerr := parseSlotRange(q, 32)
+			if tt.wantCode == "" {
+				if rerr != nil {
+					t.Fatalf("error %s: %s, want none", rerr.Code, rerr.Message)
+				}
+				if from != tt.from || to != tt.to {
+					t.Fatalf("range = %d..%d, want %d..%d", from, to, tt.from, tt.to)
+				}
+				return
+			}
+			if rerr == nil {
+				t.Fatalf("range %d..%d accepted, want %s", from, to, tt.wantCode)
+			}
+			if rerr.Code != tt.wantCode {
+				t.Fatalf("code = %s, want %s", rerr.Code, tt.wantCode)
+			}
+			if rerr.Message == "" {
+				t.Fatal("empty error message")
+			}
+		})
+	}
+}
+
+func TestSlotsRangeErrorResponse(t *testing.T) {
+	d := newTestDeps(t, newTestConfig(t), newTestServer(t, http.NotFound).URL, "")
+	rec := serve(buildRouter(d), http.MethodGet, "/api/v1/slots?from=9&to=3", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	if got := decodeJSON(t, rec)["error_code"]; got != rangeErrInverted {
+		t.Fatalf("error_code = %v, want %s", got, rangeErrInverted)
+	}
+}
+
+func TestSlotsRangeEndsAtMaxUint64(t *testing.T) {
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		jsonHandler(http.StatusOK, `{"status":"OK","data":{"slot":18446744073709551615,"epoch":0}}`)(w, req)
+	})
+	d := newTestDeps(t, newTestConfig(t), dora.URL, "")
+	rec := serve(buildRouter(d), http.MethodGet, "/api/v1/slots?from=18446744073709551615&to=18446744073709551615", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	data, _ := decodeJSON(t, rec)["data"].([]interface{})
+	if len(data) != 1 {
+		t.Fatalf("%d slots, want 1: %s", len(data), rec.Body.String())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import "github.com/gorilla/mux"
+
+// routeQueryParams lists the query params each route accepts.
+var routeQueryParams = map[string][]string{
+	routeValidator:     {"limit", "offset"},
+	routeSlots:         {"from", "to"},
+	routeInternalCache: {"from", "to"},
+}
+
+// registeredRoute is a route added through buildRouter's handle.
+type registeredRoute struct {
+	name  string
+	route *mux.Route
+}
+
+// specRoute describes one route in the /api/v1/spec response.
+type specRoute struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Methods     []string `json:"methods"`
+	QueryParams []string `json:"query_params"`
+	Enabled     bool     `json:"enabled"`
+}
+
+// buildSpec describes the registered routes and the transforms and
+// enrichments enabled by cfg.
+func buildSpec(routes []registeredRoute, cfg *proxyConfig) map[string]interface{} {
+	out := make([]specRoute, 0, len(routes))
+	for _, rr := range routes {
+		path, _ := rr.route.GetPathTemplate()
+		methods, _ := rr.route.GetMethods()
+		params := routeQueryParams[rr.name]
+		if params == nil {
+			params = []string{}
+		}
+		out = append(out, specRoute{
+			Name:        rr.name,
+			Path:        path,
+			Methods:     methods,
+			QueryParams: params,
+			Enabled:     !cfg.DisabledRoutes[rr.name],
+		})
+	}
+	return map[string]interface{}{
+		"routes": out,
+		"features": map[string]interface{}{
+			"attestation_source":        cfg.AttestationSource,
+			"validator_status_mapping":  true,
+			"collapse_slashed":          cfg.CollapseSlashed,
+			"status_overrides":          len(cfg.StatusMap),
+			"dedupe_validators":         cfg.DedupeValidators,
+			"validator_batching":        cfg.ValidatorBatchWindow > 0,
+			"pubkey_resolution":         cfg.ResolvePubkeys,
+			"recently_activated_flag":   cfg.RecentActivationEpochs > 0,
+			"slot_consensus_enrichment": true,
+			"slot_finality_status":      cfg.FinalityTTL > 0,
+			"response_cache":            cfg.ResponseCacheTTL > 0,
+			"wrap_envelope":             cfg.WrapEnvelope,
+			"strict_json":               cfg.StrictJSON,
+			"offline_alerts":            cfg.AlertWebhookURL != "",
+			"read_only":                 cfg.ReadOnly,
+		},
+	}
+}
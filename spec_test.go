@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSpecListsRoutesAndFeatures(t *testing.T) {
+	t.Setenv("PROXY_ROUTE_SLOTS_ENABLED", "false")
+	cfg := newTestConfig(t)
+	cfg.ResponseCacheTTL = 0
+	cfg.ValidatorBatchWindow = 50 * time.Millisecond
+	h := buildRouter(newTestDeps(t, cfg, newTestServer(t, http.NotFound).URL, ""))
+	m := decodeJSON(t, serve(h, http.MethodGet, "/api/v1/spec", ""))
+
+	routes, _ := m["routes"].([]interface{})
+	byName := make(map[string]map[string]interface{})
+	for _, r := range routes {
+		route, _ := r.(map[string]interface{})
+		name, _ := route["name"].(string)
+		byName[name] = route
+	}
+	for _, name := range routeNames {
+		if _, ok := byName[name]; !ok {
+			t.Errorf("route %s missing from the spec", name)
+		}
+	}
+	validator := byName[routeValidator]
+	if validator["path"] != "/api/v1/validator" || !containsAll(validator["methods"], "POST") || !containsAll(validator["query_params"], "limit", "offset") {
+		t.Errorf("validator route = %v", validator)
+	}
+	if byName[routeSlots]["enabled"] != false || byName[routeSlot]["enabled"] != true {
+		t.Errorf("enabled flags: slots %v, slot %v; want false, true", byName[routeSlots]["enabled"], byName[routeSlot]["enabled"])
+	}
+
+	features, _ := m["features"].(map[string]interface{})
+	if features["validator_batching"] != true || features["response_cache"] != false || features["slot_consensus_enrichment"] != true {
+		t.Errorf("features = %v", features)
+	}
+}
+
+// containsAll reports whether the JSON array v holds every one of want.
+func containsAll(v interface{}, want ...string) bool {
+	arr, _ := v.([]interface{})
+	have := make(map[interface{}]bool, len(arr))
+	for _, x := range arr {
+		have[x] = true
+	}
+	for _, w := range want {
+		if !have[w] {
+			return false
+		}
+	}
+	return true
+}
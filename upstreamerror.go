@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Upstream failures are returned as one of the error types below, so a
+// handler can pick its response with writeUpstreamError and
+// dora_proxy_upstream_errors_total can count them by kind.
+
+// ErrUpstreamUnreachable reports that upstream gave no response: a transport
+// error, a cancelled request or an open circuit breaker (errCircuitOpen).
+type ErrUpstreamUnreachable struct {
+	Err error
+}
+
+func (e *ErrUpstreamUnreachable) Error() string { return "upstream unreachable: " + e.Err.Error() }
+func (e *ErrUpstreamUnreachable) Unwrap() error { return e.Err }
+
+// ErrUpstreamStatus reports an upstream response the proxy can't use: a body
+// over the size limit, cut short, malformed, or not JSON on a success status.
+// Reason is the message sent to the client.
+type ErrUpstreamStatus struct {
+	Status int // upstream status code
+	Reason string
+	Err    error // underlying cause, may be nil
+}
+
+func (e *ErrUpstreamStatus) Error() string {
+	if e.Err != nil {
+		return e.Reason + ": " + e.Err.Error()
+	}
+	return e.Reason
+}
+
+func (e *ErrUpstreamStatus) Unwrap() error { return e.Err }
+
+// ErrTransformFailed reports that an upstream response could not be encoded
+// again after a route's transform.
+type ErrTransformFailed struct {
+	Err error
+}
+
+func (e *ErrTransformFailed) Error() string { return "transform failed: " + e.Err.Error() }
+func (e *ErrTransformFailed) Unwrap() error { return e.Err }
+
+var upstreamErrors = defaultRegistry.NewCounterVec(
+	"dora_proxy_upstream_errors_total",
+	"Failed upstream requests by kind (unreachable, status, transform).",
+	"kind",
+)
+
+// upstreamErrorKind names err's kind for the upstream errors metric.
+func upstreamErrorKind(err error) string {
+	var unreachable *ErrUpstreamUnreachable
+	var status *ErrUpstreamStatus
+	var transform *ErrTransformFailed
+	switch {
+	case errors.As(err, &unreachable):
+		return "unreachable"
+	case errors.As(err, &status):
+		return "status"
+	case errors.As(err, &transform):
+		return "transform"
+	default:
+		return "other"
+	}
+}
+
+// countUpstreamError records err in the upstream errors metric.
+func countUpstreamError(err error) {
+	upstreamErrors.With(upstreamErrorKind(err)).Inc()
+}
+
+// writeUpstreamError counts err and answers with the matching error
+// response: 503 while the circuit breaker is open, 502 for other upstream
+// failures and 500 when the transform failed.
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	countUpstreamError(err)
+	var status *ErrUpstreamStatus
+	var transform *ErrTransformFailed
+	switch {
+	case errors.Is(err, errCircuitOpen):
+		writeError(w, http.StatusServiceUnavailable, "upstream temporarily unavailable")
+	case errors.As(err, &status):
+		writeError(w, http.StatusBadGateway, status.Reason)
+	case errors.As(err, &transform):
+		writeError(w, http.StatusInternalServerError, "failed to marshal response")
+	default:
+		writeError(w, http.StatusBadGateway, "upstream unreachable")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeJSONErrorTypes(t *testing.T) {
+	ok := jsonHandler(http.StatusOK, `{"status":"OK","data":{"epoch":3}}`)
+	tests := []struct {
+		name      string
+		upstream  string // empty: a server running handler
+		handler   http.HandlerFunc
+		maxBytes  int64
+		transform func(interface{})
+		check     func(error) bool
+		kind      string
+	}{
+		{"unreachable", deadURL(t), nil, 0, nil, func(err error) bool {
+			var e *ErrUpstreamUnreachable
+			return errors.As(err, &e)
+		}, "unreachable"},
+		{"html on success", "", htmlHandler(http.StatusOK), 0, func(interface{}) {}, func(err error) bool {
+			var e *ErrUpstreamStatus
+			return errors.As(err, &e) && e.Status == http.StatusOK
+		}, "status"},
+		{"over the size limit", "", ok, 8, func(interface{}) {}, func(err error) bool {
+			var e *ErrUpstreamStatus
+			return errors.As(err, &e) && errors.Is(err, errResponseTooLarge)
+		}, "status"},
+		{"truncated JSON", "", jsonHandler(http.StatusOK, `{"data":{`), 0, func(interface{}) {}, func(err error) bool {
+			var e *ErrUpstreamStatus
+			return errors.As(err, &e) && e.Reason == "upstream response was truncated"
+		}, "status"},
+		{"transform not encodable", "", ok, 0, func(v interface{}) {
+			v.(map[string]interface{})["bad"] = math.Inf(1)
+		}, func(err error) bool {
+			var e *ErrTransformFailed
+			return errors.As(err, &e)
+		}, "transform"},
+		{"success", "", ok, 0, func(interface{}) {}, func(err error) bool { return err == nil }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := tt.upstream
+			if upstream == "" {
+				upstream = newTestServer(t, tt.handler).URL
+			}
+			cfg := newTestConfig(t)
+			cfg.MaxResponseBytes = tt.maxBytes
+			d := newTestDeps(t, cfg, upstream, "")
+			p := NewUpstreamProxy(d.client, d.upstream, cfg, d.log)
+
+			err := p.serveJSON(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/epoch/latest", nil), cfg.PathEpochLatest, tt.transform)
+			if !tt.check(err) {
+				t.Fatalf("serveJSON error = %T %v", err, err)
+			}
+			if tt.kind != "" {
+				if kind := upstreamErrorKind(err); kind != tt.kind {
+					t.Errorf("kind = %q, want %q", kind, tt.kind)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteUpstreamError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		kind   string
+	}{
+		{&ErrUpstreamUnreachable{Err: errCircuitOpen}, http.StatusServiceUnavailable, "unreachable"},
+		{&ErrUpstreamUnreachable{Err: errors.New("connection refused")}, http.StatusBadGateway, "unreachable"},
+		{&ErrUpstreamStatus{Status: 200, Reason: "upstream returned malformed JSON"}, http.StatusBadGateway, "status"},
+		{&ErrTransformFailed{Err: errors.New("bad value")}, http.StatusInternalServerError, "transform"},
+	}
+	for _, tt := range tests {
+		before := upstreamErrors.With(tt.kind).Value()
+		rec := httptest.NewRecorder()
+		writeUpstreamError(rec, tt.err)
+		if rec.Code != tt.status {
+			t.Errorf("%v: status = %d, want %d", tt.err, rec.Code, tt.status)
+		}
+		if got := upstreamErrors.With(tt.kind).Value() - before; got != 1 {
+			t.Errorf("%v: %s errors counter advanced by %v, want 1", tt.err, tt.kind, got)
+		}
+		// internal causes stay out of the response
+		if strings.Contains(rec.Body.String(), "connection refused") || strings.Contains(rec.Body.String(), "bad value") {
+			t.Errorf("%v: body leaks the cause: %s", tt.err, rec.Body.String())
+		}
+	}
+}
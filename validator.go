@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// dedupeValidatorRequest removes repeated entries from the comma-separated
+// indicesOrPubkey list Dora accepts on POST /api/v1/validator, keeping the
+// first occurrence of each. It returns the rewritten body and whether
+// anything changed; bodies it cannot parse are left untouched.
+func dedupeValidatorRequest(body []byte) ([]byte, bool) {
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return body, false
+	}
+	list, ok := m["indicesOrPubkey"].(string)
+	if !ok || list == "" {
+		return body, false
+	}
+	parts := strings.Split(list, ",")
+	seen := make(map[string]struct{}, len(parts))
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		key := strings.ToLower(p)
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, p)
+	}
+	if len(out) == len(parts) {
+		return body, false
+	}
+	m["indicesOrPubkey"] = strings.Join(out, ",")
+	rewritten, err := json.Marshal(m)
+	if err != nil {
+		return body, false
+	}
+	return rewritten, true
+}
+
+// startsWithObject reports whether the next non-whitespace byte of br opens a
+// JSON object, without consuming it.
+func startsWithObject(br *bufio.Reader) bool {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return false
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		default:
+			return b[0] == '{'
+		}
+	}
+}
+
+// Error codes for the validator paging params.
+const (
+	pageErrInvalidLimit  = "INVALID_LIMIT"
+	pageErrLimitTooLarge = "LIMIT_TOO_LARGE"
+	pageErrInvalidOffset = "INVALID_OFFSET"
+)
+
+// dataPage selects a window of a response's "data" array.
+type dataPage struct {
+	offset, limit int
+}
+
+// parseDataPage reads the limit/offset query params. It returns nil when
+// neither is given; a lone offset pages with maxLimit.
+func parseDataPage(q url.Values, maxLimit int) (*dataPage, *rangeError) {
+	rawLimit, rawOffset := strings.TrimSpace(q.Get("limit")), strings.TrimSpace(q.Get("offset"))
+	if rawLimit == "" && rawOffset == "" {
+		return nil, nil
+	}
+	page := &dataPage{limit: maxLimit}
+	if rawLimit != "" {
+		n, err := strconv.Atoi(rawLimit)
+		if err != nil || n < 1 {
+			return nil, &rangeError{Code: pageErrInvalidLimit, Message: "limit must be a positive integer"}
+		}
+		if n > maxLimit {
+			return nil, &rangeError{Code: pageErrLimitTooLarge, Message: "limit must not exceed " + strconv.Itoa(maxLimit)}
+		}
+		page.limit = n
+	}
+	if rawOffset != "" {
+		n, err := strconv.Atoi(rawOffset)
+		if err != nil || n < 0 {
+			return nil, &rangeError{Code: pageErrInvalidOffset, Message: "offset must be a non-negative integer"}
+		}
+		page.offset = n
+	}
+	return page, nil
+}
+
+// includes reports whether element i is on the page.
+func (p *dataPage) includes(i int) bool {
+	return p == nil || (i >= p.offset && i < p.offset+p.limit)
+}
+
+// writeTotals writes the "total" and "next_offset" envelope members, the
+// latter null on the last page.
+func (p *dataPage) writeTotals(dst io.Writer, total int) {
+	next := "null"
+	if p.offset+p.limit < total {
+		next = strconv.Itoa(p.offset + p.limit)
+	}
+	io.WriteString(dst, `,"total":`+strconv.Itoa(total)+`,"next_offset":`+next)
+}
+
+// streamTransformData re-encodes a JSON object from src to dst. Elements of
+// the top-level "data" array are decoded, transformed and written one at a
+// time; other members are copied verbatim. A "status" member is added when
+// missing so the output keeps Dora's envelope. With a non-nil page only the
+// elements on it are transformed and written, and "total" and "next_offset"
+// are appended.
+func streamTransformData(dst io.Writer, src io.Reader, transform func(interface{}), page *dataPage) error {
+	dec := json.NewDecoder(src)
+	dec.UseNumber() // keep large uint64 values exact
+	if _, err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	io.WriteString(dst, "{")
+	first := true
+	hasStatus := false
+	total := -1 // length of the data array, once seen
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		if !first {
+			io.WriteString(dst, ",")
+		}
+		first = false
+		kb, _ := json.Marshal(key)
+		dst.Write(kb)
+		io.WriteString(dst, ":")
+
+		if key == "status" {
+			hasStatus = true
+		}
+		if key != "data" {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			dst.Write(raw)
+			continue
+		}
+		if total, err = streamDataValue(dec, dst, transform, page); err != nil {
+			return err
+		}
+	}
+	if _, err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	if !hasStatus {
+		if !first {
+			io.WriteString(dst, ",")
+		}
+		io.WriteString(dst, `"status":"OK"`)
+	}
+	if page != nil && total >= 0 {
+		page.writeTotals(dst, total)
+	}
+	_, err := io.WriteString(dst, "}")
+	return err
+}
+
+// streamDataValue handles the value of "data": arrays are streamed element by
+// element, objects are transformed as a whole, anything else is copied. It
+// returns the array length, or -1 when data is not an array.
+func streamDataValue(dec *json.Decoder, dst io.Writer, transform func(interface{}), page *dataPage) (int, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return -1, err
+	}
+	switch tok {
+	case json.Delim('['):
+		io.WriteString(dst, "[")
+		written := 0
+		i := 0
+		for ; dec.More(); i++ {
+			if !page.includes(i) {
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return -1, err
+				}
+				continue
+			}
+			var el interface{}
+			if err := dec.Decode(&el); err != nil {
+				return -1, err
+			}
+			transform(el)
+			if written > 0 {
+				io.WriteString(dst, ",")
+			}
+			written++
+			if err := writeJSONValue(dst, el); err != nil {
+				return -1, err
+			}
+		}
+		if _, err := dec.Token(); err != nil { // closing ]
+			return -1, err
+		}
+		_, err = io.WriteString(dst, "]")
+		return i, err
+	case json.Delim('{'):
+		obj := make(map[string]interface{})
+		for dec.More() {
+			kt, err := dec.Token()
+			if err != nil {
+				return -1, err
+			}
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return -1, err
+			}
+			k, _ := kt.(string)
+			obj[k] = v
+		}
+		if _, err := dec.Token(); err != nil { // closing }
+			return -1, err
+		}
+		transform(obj)
+		return -1, writeJSONValue(dst, obj)
+	default:
+		// scalar or null
+		return -1, writeJSONValue(dst, tok)
+	}
+}
+
+func writeJSONValue(dst io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(b)
+	return err
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) (json.Delim, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, err
+	}
+	d, ok := tok.(json.Delim)
+	if !ok || d != want {
+		return 0, fmt.Errorf("expected %q, got %v", want, tok)
+	}
+	return d, nil
+}
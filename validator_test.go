@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDedupeValidatorRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		want        string // indicesOrPubkey after dedup
+		wantChanged bool
+	}{
+		{"duplicates", `{"indicesOrPubkey":"5,1,5,2,1"}`, "5,1,2", true},
+		{"pubkey case", `{"indicesOrPubkey":"0xAB,7,0xab"}`, "0xAB,7", true},
+		{"spaces and empties", `{"indicesOrPubkey":"3, 3,,4"}`, "3,4", true},
+		{"no duplicates", `{"indicesOrPubkey":"1,2,3"}`, "1,2,3", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, changed := dedupeValidatorRequest([]byte(tt.body))
+			if changed != tt.wantChanged {
+				t.Fatalf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			var m map[string]interface{}
+			if err := json.Unmarshal(out, &m); err != nil {
+				t.Fatal(err)
+			}
+			if got := m["indicesOrPubkey"]; got != tt.want {
+				t.Fatalf("indicesOrPubkey = %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDedupeValidatorRequestKeepsOtherFields(t *testing.T) {
+	out, changed := dedupeValidatorRequest([]byte(`{"indicesOrPubkey":"1,1","extra":12345678901234567890}`))
+	if !changed {
+		t.Fatal("duplicates not removed")
+	}
+	if got, want := string(out), `{"extra":12345678901234567890,"indicesOrPubkey":"1"}`; got != want {
+		t.Fatalf("body = %s, want %s", got, want)
+	}
+}
+
+func TestDedupeValidatorRequestUnparsable(t *testing.T) {
+	for _, body := range []string{`not json`, `{"indicesOrPubkey":[1,1]}`, `{"other":"1,1"}`} {
+		out, changed := dedupeValidatorRequest([]byte(body))
+		if changed || string(out) != body {
+			t.Errorf("dedupeValidatorRequest(%s) = %s, %v; want it untouched", body, out, changed)
+		}
+	}
+}
+
+func TestStreamTransformData(t *testing.T) {
+	src := `{"data":[{"validatorindex":1,"status":"active_ongoing","balance":18446744073709551615},{"validatorindex":2,"status":"withdrawal_possible"}],"extra":{"a":1}}`
+	var out bytes.Buffer
+	err := streamTransformData(&out, strings.NewReader(src), func(v interface{}) {
+		mapValidatorStatus(v, nil, false)
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"data":[{"balance":18446744073709551615,"status":"active_online","validatorindex":1},{"status":"exited","validatorindex":2}],"extra":{"a":1},"status":"OK"}`
+	if got := out.String(); got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestStreamTransformDataRejectsTruncated(t *testing.T) {
+	var out bytes.Buffer
+	if err := streamTransformData(&out, strings.NewReader(`{"data":[{"validatorindex":1},`), func(interface{}) {}, nil); err == nil {
+		t.Fatal("truncated body accepted")
+	}
+}
+
+// validatorSetJSON is a Dora validator response with n validators.
+func validatorSetJSON(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"status":"OK","data":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"validatorindex":%d,"pubkey":"0x%096x","balance":32000000000,"effectivebalance":32000000000,"status":"active_ongoing","slashed":false,"activationepoch":0,"exitepoch":18446744073709551615}`, i, i)
+	}
+	b.WriteString(`]}`)
+	return b.Bytes()
+}
+
+func benchmarkTransform(v interface{}) { mapValidatorStatus(v, nil, false) }
+
+// BenchmarkValidatorTransformBuffered is the former transform path: the whole
+// body is read and decoded before anything is written.
+func BenchmarkValidatorTransformBuffered(b *testing.B) {
+	body := validatorSetJSON(10000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		raw, err := io.ReadAll(bytes.NewReader(body))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var v map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			b.Fatal(err)
+		}
+		benchmarkTransform(v["data"])
+		if err := json.NewEncoder(io.Discard).Encode(v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValidatorTransformStreaming(b *testing.B) {
+	body := validatorSetJSON(10000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(body)))
+	for i := 0; i < b.N; i++ {
+		if err := streamTransformData(io.Discard, bytes.NewReader(body), benchmarkTransform, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseDataPage(t *testing.T) {
+	tests := []struct {
+		query    string
+		want     *dataPage
+		wantCode string
+	}{
+		{"", nil, ""},
+		{"limit=2", &dataPage{limit: 2}, ""},
+		{"limit=2&offset=4", &dataPage{offset: 4, limit: 2}, ""},
+		{"offset=3", &dataPage{offset: 3, limit: 100}, ""},
+		{"limit=100", &dataPage{limit: 100}, ""},
+		{"limit=101", nil, pageErrLimitTooLarge},
+		{"limit=0", nil, pageErrInvalidLimit},
+		{"limit=x", nil, pageErrInvalidLimit},
+		{"offset=-1", nil, pageErrInvalidOffset},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		page, rerr := parseDataPage(q, 100)
+		code := ""
+		if rerr != nil {
+			code = rerr.Code
+		}
+		if code != tt.wantCode || !reflect.DeepEqual(page, tt.want) {
+			t.Errorf("parseDataPage(%q) = %+v, %v; want %+v, %s", tt.query, page, rerr, tt.want, tt.wantCode)
+		}
+	}
+}
+
+// Paging a five validator response two at a time.
+func TestValidatorPaging(t *testing.T) {
+	dora := &validatorDora{}
+	h := buildRouter(newTestDeps(t, newTestConfig(t), newTestServer(t, dora.ServeHTTP).URL, ""))
+	for _, page := range []struct {
+		offset  string
+		indices string
+		next    interface{}
+	}{
+		{"0", "1,2", float64(2)},
+		{"2", "3,4", float64(4)},
+		{"4", "5", nil},
+	} {
+		rec := serve(h, http.MethodPost, "/api/v1/validator?limit=2&offset="+page.offset, `{"indicesOrPubkey":"1,2,3,4,5"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("offset %s: status %d: %s", page.offset, rec.Code, rec.Body.String())
+		}
+		if got := strings.Join(validatorIndices(t, rec), ","); got != page.indices {
+			t.Errorf("offset %s: validators %s, want %s", page.offset, got, page.indices)
+		}
+		m := decodeJSON(t, rec)
+		if m["total"] != float64(5) || m["next_offset"] != page.next {
+			t.Errorf("offset %s: total %v, next_offset %v; want 5, %v", page.offset, m["total"], m["next_offset"], page.next)
+		}
+	}
+	for _, req := range dora.requests {
+		if req.URL.RawQuery != "" {
+			t.Errorf("paging params forwarded upstream: %q", req.URL.RawQuery)
+		}
+	}
+
+	rec := serve(h, http.MethodPost, "/api/v1/validator?limit=100000", `{"indicesOrPubkey":"1"}`)
+	if rec.Code != http.StatusBadRequest || decodeJSON(t, rec)["error_code"] != pageErrLimitTooLarge {
+		t.Errorf("oversized limit: status %d, body %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestValidatorOneRoute(t *testing.T) {
+	var path string
+	dora := newTestServer(t, func(w http.ResponseWriter, req *http.Request) {
+		path = req.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/7"):
+			jsonHandler(http.StatusOK, `{"status":"OK","data":[{"validatorindex":7,"status":"active_ongoing","lastattestationslot":300}]}`)(w, req)
+		case strings.HasSuffix(path, "/8"):
+			jsonHandler(http.StatusOK, `{"status":"OK","data":[]}`)(w, req)
+		default:
+			http.NotFound(w, req)
+		}
+	})
+	d := newTestDeps(t, newTestConfig(t), dora.URL, "")
+	d.cache.SetIfGreater(7, 640)
+	h := buildRouter(d)
+
+	rec := serve(h, http.MethodGet, "/api/v1/validator/7", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.HasSuffix(path, d.cfg.PathValidator+"/7") {
+		t.Errorf("upstream path = %q", path)
+	}
+	data, ok := decodeJSON(t, rec)["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("data is not an object: %s", rec.Body.String())
+	}
+	if data["lastattestationslot"] != float64(640) {
+		t.Errorf("lastattestationslot = %v, want the cached 640", data["lastattestationslot"])
+	}
+	if data["status"] != "active_online" {
+		t.Errorf("status = %v, want it mapped to active_online", data["status"])
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/validator/8":  http.StatusNotFound, // empty list
+		"/api/v1/validator/9":  http.StatusNotFound, // upstream 404
+		"/api/v1/validator/x":  http.StatusBadRequest,
+		"/api/v1/validator/-1": http.StatusBadRequest,
+	} {
+		if rec := serve(h, http.MethodGet, target, ""); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X main.Version=v1.2.3 -X main.Commit=$(git rev-parse --short HEAD) -X main.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// and served on /version.
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// buildInfo is the /version response.
+func buildInfo() map[string]string {
+	return map[string]string{
+		"version":    Version,
+		"commit":     Commit,
+		"build_time": BuildTime,
+	}
+}
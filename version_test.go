@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestVersionRoute(t *testing.T) {
+	defer func(v, c, b string) { Version, Commit, BuildTime = v, c, b }(Version, Commit, BuildTime)
+	Version, Commit, BuildTime = "v1.2.3", "abc1234", "2024-05-01T12:00:00Z"
+
+	h := buildRouter(newTestDeps(t, newTestConfig(t), newTestServer(t, http.NotFound).URL, ""))
+	rec := serve(h, http.MethodGet, "/version", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	m := decodeJSON(t, rec)
+	for key, want := range map[string]string{"version": "v1.2.3", "commit": "abc1234", "build_time": "2024-05-01T12:00:00Z"} {
+		if m[key] != want {
+			t.Errorf("%s = %v, want %q", key, m[key], want)
+		}
+	}
+}